@@ -3,202 +3,646 @@ package main
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"flag"
 	"fmt"
+	"io/fs"
 	"log"
+	"os"
 	"path/filepath"
+	"qa-test-app/internal/assertions"
+	"qa-test-app/internal/awsclient"
+	"qa-test-app/internal/fakeaws"
+	"qa-test-app/internal/lifecycle"
 	"qa-test-app/internal/terraform"
 	"qa-test-app/internal/tests"
 	"qa-test-app/internal/yaml"
 	"strings"
+	"time"
 
 	"github.com/charmbracelet/lipgloss"
 )
 
 var (
-	tealStyle = lipgloss.NewStyle().Foreground(lipgloss.Color("14"))
+	tealStyle  = lipgloss.NewStyle().Foreground(lipgloss.Color("14"))
+	redStyle   = lipgloss.NewStyle().Foreground(lipgloss.Color("9"))
+	greenStyle = lipgloss.NewStyle().Foreground(lipgloss.Color("10"))
 )
 
+const defaultSuiteDir = "test-cases"
+
+// defaultExternalDir holds executable test functions; it is optional
+const defaultExternalDir = "tests"
+
+// caseSummary is the outcome of one test case in a suite run
+type caseSummary struct {
+	testCase *yaml.TestCase
+	results  []tests.TestResult
+	err      error
+}
+
+// errSuiteFailed is returned when the suite ran but a test case failed; the summary has the details
+var errSuiteFailed = errors.New("test suite failed")
+
+// errPlanTestsFailed stops a test case before apply when a plan test function fails
+var errPlanTestsFailed = errors.New("plan tests failed, not applying")
+
+// runner holds the settings shared by every test case in a run
+type runner struct {
+	workingDir    string
+	defaultFormat terraform.TfvarsFormat
+	gracePeriod   time.Duration
+	apply         bool
+	execution     tests.ExecuteOptions
+	aws           awsclient.Config
+	endpoint      string
+	// fromState gives test functions no AWS access, so network checks read the workspace state
+	fromState     bool
+	lifecycle     *lifecycle.Manager
+	testExecutor  *tests.TestExecutor
+}
+
 func main() {
+	if err := run(); err != nil {
+		if !errors.Is(err, errSuiteFailed) {
+			log.Print(err)
+		}
+		os.Exit(1)
+	}
+}
+
+// run does all the work of main and returns errors instead of exiting, so
+// deferred teardown always runs
+func run() error {
 	apply := flag.Bool("apply", false, "Apply terraform")
 	destroy := flag.Bool("destroy", false, "Destroy all test workspaces")
 	test := flag.Bool("test", false, "Run tests against existing infrastructure")
+	check := flag.Bool("check", false, "Exit non-zero if the committed tfvars file differs from what would be generated")
+	generate := flag.Bool("generate", false, "Write the committed tfvars file for the test case and exit")
+	gracePeriod := flag.Duration("grace-period", terraform.DefaultGracePeriod, "How long terraform may take to stop after an interrupt before it is killed")
+	mock := flag.Bool("mock", false, "Run test functions against an in-memory EC2 fake instead of provisioned infrastructure")
+	fixture := flag.String("fixture", "", "State JSON or fixture YAML to seed -mock with; defaults to the layout the tfvars describe")
+	planJSON := flag.String("plan-json", "", "Run plan test functions against a `terraform show -json` file without running terraform")
+	concurrency := flag.Int("concurrency", 4, "How many test functions of a test case run at once; execution.concurrency in a test case overrides it")
+	failFast := flag.Bool("fail-fast", false, "Stop running a test case's test functions after the first failure")
+	awsProfile := flag.String("aws-profile", "", "AWS shared config profile test functions use; defaults to AWS_PROFILE")
+	awsAssumeRole := flag.String("aws-assume-role", "", "ARN of a role test functions assume for AWS calls")
+	awsEndpoint := flag.String("aws-endpoint", "", "Custom AWS endpoint URL for test functions")
+	localEndpoint := flag.String("local-endpoint", "", "AWS emulator URL, e.g. http://localhost:4566, that terraform (through AWS_ENDPOINT_URL) and test functions both use")
+	externalDir := flag.String("tests-dir", defaultExternalDir, "Directory of executable test functions that read JSON on stdin and print a JSON result")
+	externalTimeout := flag.Duration("external-timeout", tests.DefaultExternalTimeout, "How long an external test function may run")
+	fromState := flag.Bool("from-state", false, "Check the network against the terraform state of the workspace instead of querying AWS")
+	formatName := flag.String("tfvars-format", string(terraform.TfvarsHCL), "Default tfvars format (hcl or json); terraform.tfvars_format in a test case overrides it")
+	flag.Usage = func() {
+		fmt.Fprintf(flag.CommandLine.Output(), "Usage: %s [flags] [dir|glob|file ...]\n\n", os.Args[0])
+		fmt.Fprintf(flag.CommandLine.Output(), "Test cases default to every *.yaml under %s/.\n\n", defaultSuiteDir)
+		flag.PrintDefaults()
+	}
 	flag.Parse()
 
 	fmt.Println(tealStyle.Render("QA Test App Starting..."))
 	
-	tc, err := yaml.ParseTestCase("test-cases/sample.yaml")
+	defaultFormat, err := terraform.ParseTfvarsFormat(*formatName)
 	if err != nil {
-		log.Fatal(err)
+		return err
+	}
+
+	r := &runner{
+		workingDir:    filepath.Join("terraform", "base"),
+		defaultFormat: defaultFormat,
+		gracePeriod:   *gracePeriod,
+		apply:         *apply,
+		execution:     tests.ExecuteOptions{Concurrency: *concurrency, FailFast: *failFast},
+		aws:           awsclient.Config{Profile: *awsProfile, AssumeRoleARN: *awsAssumeRole, Endpoint: *awsEndpoint},
+		endpoint:      *localEndpoint,
+		fromState:     *fromState,
+		lifecycle:     lifecycle.NewManager(lifecycle.DefaultRecoveryDir),
+		testExecutor:  tests.NewTestExecutor(),
+	}
+	r.useLocalEndpoint()
+
+	externals, err := r.testExecutor.RegisterExternal(*externalDir, *externalTimeout)
+	switch {
+	case errors.Is(err, fs.ErrNotExist) && *externalDir == defaultExternalDir:
+	case err != nil:
+		return fmt.Errorf("loading external test functions: %w", err)
+	case len(externals) > 0:
+		fmt.Printf(tealStyle.Render("Loaded %d external test function(s) from %s: %s\n"),
+			len(externals), *externalDir, strings.Join(externals, ", "))
 	}
-	fmt.Printf(tealStyle.Render("Loaded test: %s\n"), tc.Metadata.Name)
 	
-	workingDir := filepath.Join("terraform", "base")
-	tfvarsFile := "generated.tfvars"
-	executor := terraform.NewExecutor(workingDir, tfvarsFile)
+	// Ctrl-C or SIGTERM cancels ctx, which interrupts the running terraform
+	// command; teardown still runs afterwards
+	ctx, stop := r.lifecycle.Start(context.Background())
+	defer stop()
 	
+	targets := flag.Args()
+	if len(targets) == 0 {
+		targets = []string{defaultSuiteDir}
+	}
+	cases, err := yaml.LoadSuite(targets...)
+	if err != nil {
+		return err
+	}
+	fmt.Printf(tealStyle.Render("Loaded %d test case(s)\n"), len(cases))
+
+	// Check test function names, params and assertions before anything is provisioned
+	var invalid []error
+	for _, tc := range cases {
+		if err := r.testExecutor.Validate(tc); err != nil {
+			invalid = append(invalid, err)
+		}
+		if err := assertions.Validate(tc); err != nil {
+			invalid = append(invalid, err)
+		}
+	}
+	if err := errors.Join(invalid...); err != nil {
+		return err
+	}
+
+	if *check || *generate {
+		return r.checkOrGenerate(cases, *generate)
+	}
+
+	if *planJSON != "" {
+		return r.runPlanFile(ctx, cases, *planJSON)
+	}
+
+	if *mock {
+		return r.runMock(ctx, cases, *fixture)
+	}
+
+	// Finish tearing down workspaces left behind by interrupted runs
+	if err := r.lifecycle.Recover(ctx, func(rec lifecycle.Record) *terraform.Executor {
+		executor := r.newExecutor(nil, rec.TfvarsFile)
+		executor.WorkingDir = rec.WorkingDir
+		executor.RunsDir = rec.RunsDir
+		executor.Endpoint = rec.Endpoint
+		return executor
+	}); err != nil {
+		log.Printf("Recovery of interrupted runs incomplete: %v", err)
+	}
+
 	if *destroy {
 		fmt.Println(tealStyle.Render("Destroying all test workspaces..."))
-		if err := destroyAllTestWorkspaces(executor); err != nil {
+		if err := r.destroyAllTestWorkspaces(ctx); err != nil {
 			log.Printf("Destroy warning: %v", err)
 		}
 		fmt.Println(tealStyle.Render("✓ All test workspaces destroyed"))
-		return
+		return nil
 	}
-	
-	if *test {
-		// Find existing test workspace instead of creating new one
-		workspaces, err := executor.WorkspaceList()
-		if err != nil {
-			log.Fatalf("Failed to list workspaces: %v", err)
-		}
-		
-		var targetWorkspace string
-		for _, ws := range workspaces {
-			if strings.HasPrefix(ws, "test-") {
-				executor.CurrentWorkspace = ws
-				if _, err := executor.SelectWorkspace(ws); err != nil {
-					continue
-				}
-				if hasResources, _ := executor.HasResources(); hasResources {
-					targetWorkspace = ws
-					break
-				}
-			}
+
+	summaries := make([]caseSummary, 0, len(cases))
+	for _, tc := range cases {
+		if ctx.Err() != nil {
+			summaries = append(summaries, caseSummary{testCase: tc, err: fmt.Errorf("skipped: %w", context.Cause(ctx))})
+			continue
 		}
-		
-		if targetWorkspace == "" {
-			log.Fatal("No test workspace with resources found. Run 'make apply' first.")
+		fmt.Printf(tealStyle.Render("\n=== Test case: %s (%s) ===\n"), tc.Metadata.Name, tc.Source)
+
+		executor := r.newExecutor(tc, r.tfvarsFormat(tc).FileName())
+		var results []tests.TestResult
+		if *test {
+			results, err = r.runAgainstExistingWorkspace(ctx, executor, tc)
+		} else {
+			results, err = r.runCase(ctx, executor, tc)
 		}
-		
-		fmt.Printf(tealStyle.Render("Using existing workspace: %s\n"), targetWorkspace)
-		
-		tfOutputs, err := getTerraformOutputs(executor)
 		if err != nil {
-			log.Fatalf("Could not get terraform outputs: %v", err)
+			log.Printf("Test case %q failed: %v", tc.Metadata.Name, err)
 		}
-		runTests(tc.TestFunctions, tfOutputs)
-		return
+		summaries = append(summaries, caseSummary{testCase: tc, results: results, err: err})
 	}
-	
+
+	if !printSuiteSummary(summaries) {
+		return errSuiteFailed
+	}
+	return nil
+}
+
+// checkOrGenerate compares or writes the committed tfvars preview for a single test case
+func (r *runner) checkOrGenerate(cases []*yaml.TestCase, generate bool) error {
+	if len(cases) != 1 {
+		return fmt.Errorf("-check and -generate need exactly one test case, got %d", len(cases))
+	}
+	spec := tfvarsSpec(cases[0], "", r.tfvarsFormat(cases[0]))
+	outputPath := filepath.Join(r.workingDir, spec.Format.FileName())
+
+	if generate {
+		if err := terraform.GenerateTfvarsFile(spec, outputPath); err != nil {
+			return err
+		}
+		fmt.Printf(tealStyle.Render("✓ Generated %s\n"), outputPath)
+		return nil
+	}
+
+	if err := terraform.CheckTfvarsFile(spec, outputPath); err != nil {
+		return fmt.Errorf("%w (run with -generate to update it)", err)
+	}
+	fmt.Printf(tealStyle.Render("✓ %s is up to date\n"), outputPath)
+	return nil
+}
+
+// runCase provisions an isolated workspace for one test case, plans it and
+// runs the plan test functions. When apply is set and they pass, it applies the
+// plan and runs the remaining test functions. The workspace is torn down
+// afterwards unless the environment was applied successfully.
+func (r *runner) runCase(ctx context.Context, executor *terraform.Executor, tc *yaml.TestCase) (results []tests.TestResult, err error) {
+	format := r.tfvarsFormat(tc)
+	planTests, applyTests := r.testExecutor.Split(tc.TestFunctions)
+	applied := false
+
+	// Check tfvars before creating a workspace so a bad variable doesn't leave one behind
+	if err := executor.CheckTfvars(tc.Terraform.TfVars); err != nil {
+		return nil, err
+	}
+	fmt.Println(tealStyle.Render("✓ Tfvars match module variables"))
+
 	fmt.Printf(tealStyle.Render("Setting up test environment for: %s\n"), tc.Metadata.Name)
-	err = executor.SetupTestEnvironment(tc.Metadata.Name)
-	if err != nil {
-		log.Fatalf("Failed to setup test environment: %v", err)
+	if err := executor.SetupTestEnvironment(ctx, tc.Metadata.Name); err != nil {
+		return nil, fmt.Errorf("failed to setup test environment: %w", err)
 	}
 	fmt.Printf(tealStyle.Render("✓ Test workspace created: %s\n"), executor.CurrentWorkspace)
-	
-	outputPath := filepath.Join("terraform", "base", "generated.tfvars")
-	err = terraform.GenerateTfvarsFile(tc.Terraform.TfVars, tc.Metadata.Name, executor.CurrentWorkspace, outputPath)
-	if err != nil {
-		log.Fatal(err)
+
+	if err := r.lifecycle.Track(executor); err != nil {
+		log.Printf("Warning: %v", err)
 	}
-	fmt.Printf(tealStyle.Render("Generated tfvars with test tags: %s\n"), outputPath)
-	
 	defer func() {
-		if executor.CurrentWorkspace != "" && !*apply {
-			fmt.Println(tealStyle.Render("Cleaning up test environment..."))
-			if err := executor.CleanupTestEnvironment(); err != nil {
-				log.Printf("Cleanup failed: %v", err)
-				executor.ForceCleanup()
-			} else {
-				fmt.Println(tealStyle.Render("✓ Test environment cleaned up"))
-			}
+		if applied && err == nil {
+			// The environment was provisioned on purpose; -destroy removes it
+			r.lifecycle.Release(executor.CurrentWorkspace)
+			return
+		}
+		fmt.Println(tealStyle.Render("Cleaning up test environment..."))
+		if cleanupErr := r.lifecycle.Teardown(ctx, executor); cleanupErr != nil {
+			log.Printf("Cleanup failed: %v", cleanupErr)
+		} else {
+			fmt.Println(tealStyle.Render("✓ Test environment cleaned up"))
 		}
 	}()
-	
+
+	outputPath, err := executor.RunTfvarsPath(format)
+	if err != nil {
+		return nil, err
+	}
+	if err := terraform.GenerateTfvarsFile(tfvarsSpec(tc, executor.CurrentWorkspace, format), outputPath); err != nil {
+		return nil, err
+	}
+	fmt.Printf(tealStyle.Render("Generated tfvars with test tags: %s\n"), outputPath)
+
 	fmt.Println(tealStyle.Render("Validating state..."))
-	if err := executor.ValidateState(); err != nil {
-		log.Fatalf("State validation failed: %v", err)
+	if err := executor.ValidateState(ctx); err != nil {
+		return nil, fmt.Errorf("state validation failed: %w", err)
 	}
 	fmt.Println(tealStyle.Render("✓ State validated"))
-	
+
 	fmt.Println(tealStyle.Render("Planning deployment..."))
-	result, err := executor.Plan()
+	result, err := executor.Plan(ctx)
 	if err != nil {
-		log.Fatal(err)
+		return nil, err
 	}
 	if !result.Success {
-		log.Fatalf("Terraform plan failed: %s", result.Error)
+		return nil, fmt.Errorf("terraform plan failed: %s", result.Error)
 	}
-	fmt.Println(tealStyle.Render("✓ Plan completed"))
-	
-	if *apply {
-		fmt.Println(tealStyle.Render("Applying deployment..."))
-		result, err = executor.Apply()
-		if err != nil {
-			log.Fatal(err)
+	plan, err := executor.PlanJSON(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("could not read plan: %w", err)
+	}
+	printPlanSummary(plan)
+	fmt.Printf(tealStyle.Render("✓ Plan saved: %s\n"), executor.PlanFile)
+
+	env := &tests.Environment{
+		TestName:  tc.Metadata.Name,
+		Workspace: executor.CurrentWorkspace,
+		TfVars:    tc.Terraform.TfVars,
+		Plan:      plan,
+	}
+	ctx = tests.WithEnvironment(ctx, env)
+
+	if len(planTests) > 0 {
+		results = r.runPlanTests(ctx, planTests, plan)
+		if !allPassed(results) && r.apply {
+			return results, errPlanTestsFailed
 		}
-		if !result.Success {
-			log.Fatalf("Terraform apply failed: %s", result.Error)
+	}
+
+	if !r.apply {
+		fmt.Println(tealStyle.Render("Ready for development"))
+		fmt.Printf(tealStyle.Render("Active workspace: %s\n"), executor.CurrentWorkspace)
+		return results, nil
+	}
+	if len(applyTests) == 0 && len(tc.Assertions) == 0 {
+		// Applying would provision infrastructure that nothing checks
+		log.Printf("Warning: not applying %q despite -apply: it has only plan test functions and no assertions", tc.Metadata.Name)
+		fmt.Printf(tealStyle.Render("Plan checked, workspace %s not applied\n"), executor.CurrentWorkspace)
+		return results, nil
+	}
+
+	fmt.Println(tealStyle.Render("Applying deployment..."))
+	result, err = executor.Apply(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if !result.Success {
+		return nil, fmt.Errorf("terraform apply failed: %s", result.Error)
+	}
+	applied = true
+	fmt.Println(tealStyle.Render("✓ Environment provisioned"))
+
+	if env.Outputs, err = executor.GetOutputs(ctx); err != nil {
+		return results, fmt.Errorf("could not get terraform outputs: %w", err)
+	}
+	if env.State, err = executor.GetState(ctx); err != nil {
+		return results, fmt.Errorf("could not read state: %w", err)
+	}
+	env.AWS = r.awsProvider(tc, env.Outputs)
+	results = append(results, r.runTests(ctx, tc, applyTests, env.Outputs)...)
+	results = append(results, r.runAssertions(env, tc.Assertions)...)
+
+	info, _ := executor.GetWorkspaceInfo(ctx)
+	fmt.Printf(tealStyle.Render("Workspace: %s (has resources: %v)\n"),
+		info["current_workspace"], info["has_resources"])
+	return results, nil
+}
+
+// newExecutor creates an executor with the test case's phase timeouts, if any
+func (r *runner) newExecutor(tc *yaml.TestCase, tfvarsFile string) *terraform.Executor {
+	executor := terraform.NewExecutor(r.workingDir, tfvarsFile)
+	executor.GracePeriod = r.gracePeriod
+	executor.Endpoint = r.endpoint
+	if tc != nil {
+		timeouts := tc.Terraform.Timeouts
+		executor.Timeouts = terraform.Timeouts{
+			Init:    timeouts.Init,
+			Plan:    timeouts.Plan,
+			Apply:   timeouts.Apply,
+			Destroy: timeouts.Destroy,
 		}
-		fmt.Println(tealStyle.Render("✓ Environment provisioned"))
+	}
+	return executor
+}
+
+// useLocalEndpoint points test functions at the emulator terraform applies to,
+// unless -aws-endpoint sends them elsewhere. Without a profile or credentials
+// in the environment they get the dummy credentials terraform is given.
+func (r *runner) useLocalEndpoint() {
+	if r.endpoint == "" {
+		return
+	}
+	fmt.Printf(tealStyle.Render("Using local AWS endpoint %s\n"), r.endpoint)
+	if r.aws.Endpoint == "" {
+		r.aws.Endpoint = r.endpoint
+	}
+	if r.aws.Profile == "" && os.Getenv("AWS_ACCESS_KEY_ID") == "" {
+		r.aws.AccessKeyID = terraform.LocalCredential
+		r.aws.SecretAccessKey = terraform.LocalCredential
+	}
+}
+
+// tfvarsSpec describes the tfvars for a test case; an empty workspace leaves out run-specific tags
+func tfvarsSpec(tc *yaml.TestCase, workspace string, format terraform.TfvarsFormat) terraform.TfvarsSpec {
+	return terraform.TfvarsSpec{
+		TfVars:    tc.Terraform.TfVars,
+		TestName:  tc.Metadata.Name,
+		Source:    tc.Source,
+		Workspace: workspace,
+		Format:    format,
+	}
+}
+
+// tfvarsFormat returns the test case's tfvars format, falling back to the global default
+func (r *runner) tfvarsFormat(tc *yaml.TestCase) terraform.TfvarsFormat {
+	if tc.Terraform.TfvarsFormat == "" {
+		return r.defaultFormat
+	}
+	// The value was checked against the allowed formats when the test case was parsed
+	format, _ := terraform.ParseTfvarsFormat(tc.Terraform.TfvarsFormat)
+	return format
+}
+
+// runAgainstExistingWorkspace finds the applied workspace for a test case and runs its tests
+func (r *runner) runAgainstExistingWorkspace(ctx context.Context, executor *terraform.Executor, tc *yaml.TestCase) ([]tests.TestResult, error) {
+	workspaces, err := executor.WorkspaceList(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list workspaces: %w", err)
+	}
 		
-		// Get terraform outputs
-		tfOutputs, err := getTerraformOutputs(executor)
-		if err != nil {
-			log.Printf("Warning: Could not get terraform outputs: %v", err)
-		} else {
-			// Run tests
-			runTests(tc.TestFunctions, tfOutputs)
+	prefix := terraform.WorkspacePrefix(tc.Metadata.Name)
+	var targetWorkspace string
+	var state *terraform.State
+	for _, ws := range workspaces {
+		if terraform.IsTestWorkspace(tc.Metadata.Name, ws) {
+			if _, err := executor.SelectWorkspace(ctx, ws); err != nil {
+				continue
+			}
+			if wsState, err := executor.GetState(ctx); err == nil && !wsState.Empty() {
+				targetWorkspace = ws
+				state = wsState
+				break
+			}
 		}
+	}
 		
-		info, _ := executor.GetWorkspaceInfo()
-		fmt.Printf(tealStyle.Render("Workspace: %s (has resources: %v)\n"), 
-			info["current_workspace"], info["has_resources"])
-		return
+	if targetWorkspace == "" {
+		return nil, fmt.Errorf("no %s<timestamp> workspace with resources found, run 'make apply' first", prefix)
 	}
-	
-	fmt.Println(tealStyle.Render("Ready for development"))
-	fmt.Printf(tealStyle.Render("Active workspace: %s\n"), executor.CurrentWorkspace)
+		
+	fmt.Printf(tealStyle.Render("Using existing workspace: %s\n"), targetWorkspace)
+		
+	outputs, err := executor.GetOutputs(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("could not get terraform outputs: %w", err)
+	}
+	env := &tests.Environment{
+		TestName:  tc.Metadata.Name,
+		Workspace: targetWorkspace,
+		TfVars:    tc.Terraform.TfVars,
+		Outputs:   outputs,
+		State:     state,
+		AWS:       r.awsProvider(tc, outputs),
+	}
+	// Plan test functions need a plan, which an existing workspace doesn't have
+	_, applyTests := r.testExecutor.Split(tc.TestFunctions)
+	results := r.runTests(tests.WithEnvironment(ctx, env), tc, applyTests, outputs)
+	return append(results, r.runAssertions(env, tc.Assertions)...), nil
 }
 
-// getTerraformOutputs extracts outputs from terraform
-func getTerraformOutputs(executor *terraform.Executor) (map[string]interface{}, error) {
-	result, err := executor.GetOutputs()
+// runPlanFile runs the plan test functions of a single test case against a
+// saved `terraform show -json` file, without terraform or AWS credentials
+func (r *runner) runPlanFile(ctx context.Context, cases []*yaml.TestCase, path string) error {
+	if len(cases) != 1 {
+		return fmt.Errorf("-plan-json needs exactly one test case, got %d", len(cases))
+	}
+	tc := cases[0]
+
+	data, err := os.ReadFile(path)
 	if err != nil {
-		return nil, err
+		return err
 	}
-	
-	// Parse terraform outputs JSON
-	var outputs map[string]interface{}
-	if err := json.Unmarshal([]byte(result.Output), &outputs); err != nil {
-		// If JSON parsing fails, create mock outputs for testing
-		return map[string]interface{}{
-			"vpc_id": "vpc-mock123",
-			"vpc_cidr_block": "10.0.0.0/16",
-		}, nil
+	plan, err := terraform.ParsePlan(data)
+	if err != nil {
+		return fmt.Errorf("%s: %w", path, err)
 	}
-	
-	// Extract values from output structure
-	finalOutputs := make(map[string]interface{})
-	for key, output := range outputs {
-		if outputMap, ok := output.(map[string]interface{}); ok {
-			if value, exists := outputMap["value"]; exists {
-				finalOutputs[key] = value
+
+	fmt.Printf(tealStyle.Render("\n=== Test case: %s (%s) ===\n"), tc.Metadata.Name, tc.Source)
+	printPlanSummary(plan)
+
+	planTests, applyTests := r.testExecutor.Split(tc.TestFunctions)
+	if len(applyTests) > 0 {
+		fmt.Printf(tealStyle.Render("Skipping test functions that need applied infrastructure: %v\n"), applyTests)
+	}
+	if len(tc.Assertions) > 0 {
+		fmt.Printf(tealStyle.Render("Skipping %d assertion(s) that need applied infrastructure\n"), len(tc.Assertions))
+	}
+
+	env := &tests.Environment{
+		TestName: tc.Metadata.Name,
+		TfVars:   tc.Terraform.TfVars,
+		Plan:     plan,
+	}
+	results := r.runPlanTests(tests.WithEnvironment(ctx, env), planTests, plan)
+
+	if !printSuiteSummary([]caseSummary{{testCase: tc, results: results}}) {
+		return errSuiteFailed
+	}
+	return nil
+}
+
+// runMock runs the test functions of every test case against an in-memory
+// EC2 fake, without terraform or AWS. The fake is seeded from fixturePath, or
+// from the layout terraform/base would create for the test case's tfvars.
+// Results say nothing about real infrastructure; the mode exists to exercise
+// test functions locally.
+func (r *runner) runMock(ctx context.Context, cases []*yaml.TestCase, fixturePath string) error {
+	fmt.Println(redStyle.Render("MOCK MODE: AWS is an in-memory fake, no infrastructure is provisioned"))
+
+	var fixtureState *terraform.State
+	if fixturePath != "" {
+		var err error
+		if fixtureState, err = fakeaws.LoadState(fixturePath); err != nil {
+			return err
+		}
+	}
+
+	summaries := make([]caseSummary, 0, len(cases))
+	for _, tc := range cases {
+		fmt.Printf(tealStyle.Render("\n=== Test case: %s (%s) ===\n"), tc.Metadata.Name, tc.Source)
+
+		state := fixtureState
+		if state == nil {
+			var err error
+			if state, err = fakeaws.FixtureFromTfvars(tc.Terraform.TfVars).State(); err != nil {
+				summaries = append(summaries, caseSummary{testCase: tc, err: err})
+				continue
 			}
 		}
+		fake, err := fakeaws.FromState(state)
+		if err != nil {
+			summaries = append(summaries, caseSummary{testCase: tc, err: err})
+			continue
+		}
+
+		// State is left unset so test functions query the fake like they would AWS
+		env := &tests.Environment{
+			TestName: tc.Metadata.Name,
+			TfVars:   tc.Terraform.TfVars,
+			Outputs:  state.Outputs(),
+			AWS:      fakeaws.Provider{Fake: fake},
+		}
+		// Plan test functions need a plan, which mock mode doesn't have
+		_, applyTests := r.testExecutor.Split(tc.TestFunctions)
+		results := r.runTests(tests.WithEnvironment(ctx, env), tc, applyTests, env.Outputs)
+		results = append(results, r.runAssertions(env, tc.Assertions)...)
+		summaries = append(summaries, caseSummary{testCase: tc, results: results})
 	}
 	
-	return finalOutputs, nil
+	if !printSuiteSummary(summaries) {
+		return errSuiteFailed
+	}
+	return nil
 }
 
-// runTests executes the test functions
-func runTests(testFunctions []string, tfOutputs map[string]interface{}) {
+// printPlanSummary lists the resources a plan changes and the totals
+func printPlanSummary(plan *terraform.Plan) {
+	summary := plan.Summary()
+	if summary.Empty() {
+		fmt.Println(tealStyle.Render("No changes. Infrastructure matches the configuration."))
+		return
+	}
+
+	for _, rc := range plan.Changes() {
+		fmt.Printf("  %-3s %s\n", rc.Change.Actions.Symbol(), rc.Address)
+	}
+	fmt.Printf(tealStyle.Render("Plan: %s\n"), summary)
+}
+
+// runTests executes the test functions and prints their results
+func (r *runner) runTests(ctx context.Context, tc *yaml.TestCase, testFunctions []yaml.TestFunctionRef, outputs terraform.Outputs) []tests.TestResult {
+	if len(testFunctions) == 0 {
+		return nil
+	}
 	fmt.Println(tealStyle.Render("\n=== Running Tests ==="))
 	
-	testExecutor := tests.NewTestExecutor()
-	ctx := context.Background()
-	
-	results := testExecutor.ExecuteAll(ctx, testFunctions, tfOutputs)
+	results := r.testExecutor.ExecuteAll(ctx, testFunctions, outputs.Values(), r.executeOptions(tc))
+	printTestResults(results)
+	return results
+}
+
+// runAssertions evaluates the assertions of a test case and prints their results
+func (r *runner) runAssertions(env *tests.Environment, list []yaml.Assertion) []tests.TestResult {
+	if len(list) == 0 {
+		return nil
+	}
+	fmt.Println(tealStyle.Render("\n=== Running Assertions ==="))
+
+	results := assertions.Run(env, list)
+	printTestResults(results)
+	return results
+}
+
+// awsProvider returns the AWS clients for a test case, in the region its tfvars
+// or outputs name. With -from-state there are none.
+func (r *runner) awsProvider(tc *yaml.TestCase, outputs terraform.Outputs) tests.ClientProvider {
+	if r.fromState {
+		return nil
+	}
+	cfg := r.aws
+	cfg.Region = awsclient.Region(tc.Terraform.TfVars, outputs.Values())
+	return awsclient.NewProvider(cfg)
+}
+
+// executeOptions applies the execution section of a test case to the command line defaults
+func (r *runner) executeOptions(tc *yaml.TestCase) tests.ExecuteOptions {
+	opts := r.execution
+	if tc.Execution.Concurrency > 0 {
+		opts.Concurrency = tc.Execution.Concurrency
+	}
+	if tc.Execution.TestTimeout > 0 {
+		opts.Timeout = tc.Execution.TestTimeout
+	}
+	if tc.Execution.FailFast != nil {
+		opts.FailFast = *tc.Execution.FailFast
+	}
+	return opts
+}
 	
+// runPlanTests executes the plan test functions with the tfvars the plan was made with
+func (r *runner) runPlanTests(ctx context.Context, testFunctions []yaml.TestFunctionRef, plan *terraform.Plan) []tests.TestResult {
+	fmt.Println(tealStyle.Render("\n=== Running Plan Tests ==="))
+
+	results := r.testExecutor.ExecutePlanAll(ctx, testFunctions, plan, plan.VariableValues())
+	printTestResults(results)
+	return results
+}
+
+// printTestResults prints each result, with details for failures, and the totals
+func printTestResults(results []tests.TestResult) {
 	successCount := 0
 	for _, result := range results {
 		status := "✗ FAIL"
-		style := lipgloss.NewStyle().Foreground(lipgloss.Color("9")) // Red
+		style := redStyle
 		if result.Success {
 			status = "✓ PASS"
-			style = lipgloss.NewStyle().Foreground(lipgloss.Color("10")) // Green
+			style = greenStyle
 			successCount++
 		}
 		
@@ -218,8 +662,54 @@ func runTests(testFunctions []string, tfOutputs map[string]interface{}) {
 	fmt.Printf(tealStyle.Render("\nTest Summary: %d/%d passed\n"), successCount, len(results))
 }
 
-func destroyAllTestWorkspaces(executor *terraform.Executor) error {
-	workspaces, err := executor.WorkspaceList()
+// allPassed reports whether every result succeeded
+func allPassed(results []tests.TestResult) bool {
+	for _, result := range results {
+		if !result.Success {
+			return false
+		}
+	}
+	return true
+}
+
+// printSuiteSummary prints one line per test case and the suite totals.
+// It returns false if any test case errored or had a failing test.
+func printSuiteSummary(summaries []caseSummary) bool {
+	fmt.Println(tealStyle.Render("\n=== Suite Summary ==="))
+
+	passedCases, passedTests, totalTests := 0, 0, 0
+	for _, s := range summaries {
+		passed := 0
+		for _, r := range s.results {
+			if r.Success {
+				passed++
+			}
+		}
+		passedTests += passed
+		totalTests += len(s.results)
+
+		switch {
+		case s.err != nil:
+			fmt.Printf("%s %s: %v\n", redStyle.Render("✗ ERROR"), s.testCase.Metadata.Name, s.err)
+		case passed < len(s.results):
+			fmt.Printf("%s %s: %d/%d passed\n", redStyle.Render("✗ FAIL"), s.testCase.Metadata.Name, passed, len(s.results))
+		case len(s.results) == 0:
+			fmt.Printf("%s %s: no tests run\n", greenStyle.Render("✓ OK"), s.testCase.Metadata.Name)
+			passedCases++
+		default:
+			fmt.Printf("%s %s: %d/%d passed\n", greenStyle.Render("✓ PASS"), s.testCase.Metadata.Name, passed, len(s.results))
+			passedCases++
+		}
+	}
+
+	fmt.Printf(tealStyle.Render("\nSuite Summary: %d/%d test cases passed, %d/%d tests passed\n"),
+		passedCases, len(summaries), passedTests, totalTests)
+	return passedCases == len(summaries)
+}
+
+func (r *runner) destroyAllTestWorkspaces(ctx context.Context) error {
+	executor := r.newExecutor(nil, r.defaultFormat.FileName())
+	workspaces, err := executor.WorkspaceList(ctx)
 	if err != nil {
 		return err
 	}
@@ -229,15 +719,14 @@ func destroyAllTestWorkspaces(executor *terraform.Executor) error {
 			fmt.Printf(tealStyle.Render("Destroying workspace: %s\n"), ws)
 			
 			// Select workspace first
-			if _, err := executor.SelectWorkspace(ws); err != nil {
+			if _, err := executor.SelectWorkspace(ctx, ws); err != nil {
 				fmt.Printf("Failed to select workspace %s: %v\n", ws, err)
 				continue
 			}
 			
 			// Destroy resources then delete workspace
-			if err := executor.CleanupTestEnvironment(); err != nil {
-				fmt.Printf("Cleanup failed for %s, forcing deletion: %v\n", ws, err)
-				executor.ForceCleanup()
+			if err := r.lifecycle.Teardown(ctx, executor); err != nil {
+				fmt.Printf("Cleanup failed for %s: %v\n", ws, err)
 			}
 		}
 	}
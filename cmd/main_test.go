@@ -0,0 +1,134 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+
+	"qa-test-app/internal/fakeaws"
+	"qa-test-app/internal/lifecycle"
+	"qa-test-app/internal/terraform"
+	"qa-test-app/internal/tests"
+	"qa-test-app/internal/yaml"
+)
+
+const fromStateCase = `metadata:
+  name: "VPC from state"
+  type: "network"
+  priority: "high"
+  severity: "critical"
+  expected_result: "The applied VPC routes and connects its subnets"
+terraform:
+  tfvars:
+    vpc_cidr: "10.0.0.0/16"
+test_functions:
+  - verify_route_tables
+  - test_subnet_connectivity
+`
+
+// fakeTerraform puts testdata/terraform first on PATH, serving the workspace
+// described by fixtures/vpc.yaml, and returns a working directory for it
+func fakeTerraform(t *testing.T) string {
+	t.Helper()
+	if runtime.GOOS == "windows" {
+		t.Skip("the fake terraform is a shell script")
+	}
+	state, err := fakeaws.LoadState("../fixtures/vpc.yaml")
+	if err != nil {
+		t.Fatalf("LoadState: %v", err)
+	}
+
+	dir := t.TempDir()
+	writeJSON := func(name string, v interface{}) string {
+		data, err := json.Marshal(v)
+		if err != nil {
+			t.Fatal(err)
+		}
+		path := filepath.Join(dir, name)
+		if err := os.WriteFile(path, data, 0o644); err != nil {
+			t.Fatal(err)
+		}
+		return path
+	}
+	t.Setenv("FAKE_TF_STATE", writeJSON("state.json", state))
+	t.Setenv("FAKE_TF_OUTPUTS", writeJSON("outputs.json", state.Outputs()))
+	t.Setenv("FAKE_TF_PLAN", writeJSON("plan.json", map[string]string{"format_version": "1.2"}))
+
+	testdata, err := filepath.Abs("testdata")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("PATH", testdata+string(os.PathListSeparator)+os.Getenv("PATH"))
+
+	workingDir := filepath.Join(dir, "module")
+	if err := os.Mkdir(workingDir, 0o755); err != nil {
+		t.Fatal(err)
+	}
+	variables := []byte("variable \"vpc_cidr\" {\n  type = string\n}\n")
+	if err := os.WriteFile(filepath.Join(workingDir, "variables.tf"), variables, 0o644); err != nil {
+		t.Fatal(err)
+	}
+	return workingDir
+}
+
+// loadCase parses src as a test case and checks its test functions
+func loadCase(t *testing.T, r *runner, src string) *yaml.TestCase {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "case.yaml")
+	if err := os.WriteFile(path, []byte(src), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	tc, err := yaml.ParseTestCase(path)
+	if err != nil {
+		t.Fatalf("ParseTestCase: %v", err)
+	}
+	if err := r.testExecutor.Validate(tc); err != nil {
+		t.Fatalf("Validate: %v", err)
+	}
+	return tc
+}
+
+func TestRunCaseFromState(t *testing.T) {
+	r := &runner{
+		workingDir:    fakeTerraform(t),
+		defaultFormat: terraform.TfvarsHCL,
+		apply:         true,
+		execution:     tests.ExecuteOptions{Concurrency: 1},
+		fromState:     true,
+		lifecycle:     lifecycle.NewManager(t.TempDir()),
+		testExecutor:  tests.NewTestExecutor(),
+	}
+	tc := loadCase(t, r, fromStateCase)
+	executor := r.newExecutor(tc, r.tfvarsFormat(tc).FileName())
+	executor.RunsDir = t.TempDir()
+
+	results, err := r.runCase(context.Background(), executor, tc)
+	if err != nil {
+		t.Fatalf("runCase: %v", err)
+	}
+	if len(results) != 2 {
+		t.Fatalf("got %d results, want 2: %+v", len(results), results)
+	}
+	for _, result := range results {
+		if !result.Success {
+			t.Errorf("%s failed: %s %v", result.TestName, result.Message, result.Details["problems"])
+		}
+		if source := result.Details["source"]; source != "state" {
+			t.Errorf("%s: source = %v, want state", result.TestName, source)
+		}
+	}
+}
+
+func TestAWSProvider(t *testing.T) {
+	tc := &yaml.TestCase{}
+	if provider := (&runner{}).awsProvider(tc, nil); provider == nil {
+		t.Error("no AWS provider without -from-state")
+	}
+	// A typed nil would make the state fallback of the network checks unreachable
+	if provider := (&runner{fromState: true}).awsProvider(tc, nil); provider != nil {
+		t.Errorf("AWS provider = %#v with -from-state, want nil", provider)
+	}
+}
@@ -0,0 +1,80 @@
+// Package assertions evaluates the expressions of a test case's assertions
+// section against terraform outputs, state and tfvars
+package assertions
+
+import (
+	"fmt"
+	"time"
+
+	"qa-test-app/internal/tests"
+	"qa-test-app/internal/yaml"
+)
+
+// Validate parses every assertion of a test case so syntax errors are reported before anything is provisioned
+func Validate(tc *yaml.TestCase) error {
+	verr := &yaml.ValidationError{File: tc.Source}
+	for _, assertion := range tc.Assertions {
+		if _, err := Parse(assertion.Expr); err != nil {
+			verr.Errors = append(verr.Errors, assertion.Errorf("%v", err))
+		}
+	}
+	if len(verr.Errors) > 0 {
+		return verr
+	}
+	return nil
+}
+
+// Run evaluates the assertions against an environment, one result per assertion
+func Run(env *tests.Environment, list []yaml.Assertion) []tests.TestResult {
+	data := Data{Outputs: env.Outputs, State: env.State, TfVars: env.TfVars}
+
+	results := make([]tests.TestResult, 0, len(list))
+	for _, assertion := range list {
+		start := time.Now()
+		result := evaluate(assertion, data)
+		result.Duration = time.Since(start)
+		result.TestName = assertion.String()
+		result.Timestamp = time.Now()
+		results = append(results, result)
+	}
+	return results
+}
+
+func evaluate(assertion yaml.Assertion, data Data) tests.TestResult {
+	expr, err := Parse(assertion.Expr)
+	if err != nil {
+		return tests.TestResult{
+			Success: false,
+			Message: fmt.Sprintf("Invalid expression: %v", err),
+			Details: map[string]interface{}{"expression": assertion.Expr},
+		}
+	}
+
+	outcome, err := expr.Evaluate(data)
+	if err != nil {
+		return tests.TestResult{
+			Success: false,
+			Message: fmt.Sprintf("Could not evaluate: %v", err),
+			Details: map[string]interface{}{"expression": expr.String()},
+		}
+	}
+
+	details := map[string]interface{}{
+		"expression": expr.String(),
+		"expected":   outcome.Expected,
+		"actual":     outcome.Actual,
+	}
+	if len(outcome.Failures) > 0 {
+		details["failures"] = outcome.Failures
+	}
+
+	message := fmt.Sprintf("%s (actual: %s)", expr, jsonText(outcome.Actual))
+	if !outcome.Passed {
+		message = fmt.Sprintf("Expected %s, got %s", outcome.Expected, jsonText(outcome.Actual))
+	}
+	return tests.TestResult{
+		Success: outcome.Passed,
+		Message: message,
+		Details: details,
+	}
+}
@@ -0,0 +1,543 @@
+package assertions
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"regexp"
+	"sort"
+	"strings"
+
+	"qa-test-app/internal/terraform"
+)
+
+// Data is what expressions are evaluated against
+type Data struct {
+	Outputs terraform.Outputs
+	State   *terraform.State
+	TfVars  map[string]interface{}
+}
+
+// resource is a state resource instance; paths look up its attributes and
+// failures name it by address
+type resource struct {
+	address string
+	values  map[string]interface{}
+}
+
+// Outcome is the result of evaluating an expression
+type Outcome struct {
+	Passed   bool
+	Expected string
+	Actual   interface{}
+	// Failures lists the elements that broke a quantified expression
+	Failures []string
+}
+
+// Evaluate evaluates the expression against data
+func (e *Expression) Evaluate(data Data) (Outcome, error) {
+	ev := &evaluator{data: data}
+	if e.quantified != nil {
+		return ev.quantified(e.quantified)
+	}
+	return ev.comparison(e.comparison, nil)
+}
+
+type evaluator struct {
+	data Data
+}
+
+func (ev *evaluator) comparison(c *comparison, element interface{}) (Outcome, error) {
+	left, err := ev.value(c.left, element)
+	if err != nil {
+		return Outcome{}, err
+	}
+
+	if c.op == "" {
+		return Outcome{Passed: truthy(left), Expected: "truthy", Actual: display(left)}, nil
+	}
+
+	right, err := ev.value(c.right, element)
+	if err != nil {
+		return Outcome{}, err
+	}
+	passed, err := compare(left, c.op, right)
+	if err != nil {
+		return Outcome{}, err
+	}
+	return Outcome{
+		Passed:   passed,
+		Expected: fmt.Sprintf("%s %s", c.op, jsonText(display(right))),
+		Actual:   display(left),
+	}, nil
+}
+
+func (ev *evaluator) quantified(q *quantified) (Outcome, error) {
+	collection, err := ev.value(q.collection, nil)
+	if err != nil {
+		return Outcome{}, err
+	}
+	elements, err := elementsOf(collection)
+	if err != nil {
+		return Outcome{}, fmt.Errorf("%s: %w", q.collection.src, err)
+	}
+
+	var matched int
+	var matches, misses []string
+	for i, element := range elements {
+		ok, reason, err := ev.predicate(q, element)
+		if err != nil {
+			return Outcome{}, fmt.Errorf("%s: %w", label(element, i), err)
+		}
+		if ok {
+			matched++
+			matches = append(matches, fmt.Sprintf("%s: %s", label(element, i), reason))
+		} else {
+			misses = append(misses, fmt.Sprintf("%s: %s", label(element, i), reason))
+		}
+	}
+
+	// Actual is the number of matching elements
+	outcome := Outcome{Actual: float64(matched)}
+	switch q.quantifier {
+	case "every":
+		// An empty collection is a failure; it usually means the path is wrong
+		outcome.Passed = len(elements) > 0 && matched == len(elements)
+		outcome.Expected = fmt.Sprintf("all %d matching", len(elements))
+		outcome.Failures = misses
+		if len(elements) == 0 {
+			outcome.Expected = fmt.Sprintf("%s to be non-empty", q.collection.src)
+		}
+	case "some":
+		outcome.Passed = matched > 0
+		outcome.Expected = fmt.Sprintf("at least 1 of %d matching", len(elements))
+		outcome.Failures = misses
+	case "no":
+		outcome.Passed = matched == 0
+		outcome.Expected = fmt.Sprintf("none of %d matching", len(elements))
+		outcome.Failures = matches
+	}
+	return outcome, nil
+}
+
+// predicate reports whether an element matches and why, as the actual value
+func (ev *evaluator) predicate(q *quantified, element interface{}) (bool, string, error) {
+	if q.predicate != nil {
+		outcome, err := ev.comparison(q.predicate, element)
+		if err != nil {
+			return false, "", err
+		}
+		return outcome.Passed, fmt.Sprintf("%s is %s", q.predicate.left.src, jsonText(outcome.Actual)), nil
+	}
+
+	tags, _ := lookupKey(element, "tags")
+	tagMap, _ := tags.(map[string]interface{})
+	value, ok := tagMap[q.tag.key]
+	if !ok {
+		return false, fmt.Sprintf("missing tag %s", q.tag.key), nil
+	}
+	if q.tag.op == "" {
+		return true, fmt.Sprintf("tag %s is %s", q.tag.key, jsonText(value)), nil
+	}
+
+	expected, err := ev.value(q.tag.value, element)
+	if err != nil {
+		return false, "", err
+	}
+	passed, err := compare(value, q.tag.op, expected)
+	if err != nil {
+		return false, "", err
+	}
+	return passed, fmt.Sprintf("tag %s is %s", q.tag.key, jsonText(value)), nil
+}
+
+// value resolves a literal or path and applies its pipes
+func (ev *evaluator) value(v *valueExpr, element interface{}) (interface{}, error) {
+	var current interface{}
+	segments := v.segments
+
+	switch {
+	case v.isLit:
+		current = v.literal
+	case v.element:
+		current = element
+	case v.root == "outputs":
+		if ev.data.Outputs == nil {
+			return nil, fmt.Errorf("%s: no outputs available", v.src)
+		}
+		current = ev.data.Outputs.Values()
+	case v.root == "tfvars":
+		current = ev.data.TfVars
+	case v.root == "state":
+		if ev.data.State == nil {
+			return nil, fmt.Errorf("%s: no state available", v.src)
+		}
+		current = resourcesOf(ev.data.State, segments[0].key)
+		segments = segments[1:]
+	}
+
+	path := v.root
+	if v.root == "state" {
+		path += "." + v.segments[0].key
+	}
+	for _, seg := range segments {
+		var err error
+		if seg.isIndex {
+			path += fmt.Sprintf("[%d]", seg.index)
+			current, err = lookupIndex(current, seg.index)
+		} else {
+			path = strings.TrimPrefix(path+"."+seg.key, ".")
+			current, err = lookupKey(current, seg.key)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", path, err)
+		}
+	}
+
+	for _, pipe := range v.pipes {
+		var err error
+		if current, err = applyPipe(pipe, current); err != nil {
+			return nil, fmt.Errorf("%s: %w", v.src, err)
+		}
+	}
+	return current, nil
+}
+
+// resourcesOf returns the managed resources of a type as a list
+func resourcesOf(state *terraform.State, resourceType string) []interface{} {
+	resources := []interface{}{}
+	for _, r := range state.ResourcesByType(resourceType) {
+		resources = append(resources, resource{address: r.Address, values: r.Values})
+	}
+	return resources
+}
+
+// lookupKey looks up a map key or resource attribute; on a list it looks the
+// key up in every element and returns the list of results
+func lookupKey(value interface{}, key string) (interface{}, error) {
+	switch v := value.(type) {
+	case map[string]interface{}:
+		item, ok := v[key]
+		if !ok {
+			return nil, fmt.Errorf("no key %q", key)
+		}
+		return item, nil
+	case resource:
+		if item, ok := v.values[key]; ok {
+			return item, nil
+		}
+		if key == "address" {
+			return v.address, nil
+		}
+		return nil, fmt.Errorf("%s has no attribute %q", v.address, key)
+	case []interface{}:
+		items := make([]interface{}, len(v))
+		for i, elem := range v {
+			item, err := lookupKey(elem, key)
+			if err != nil {
+				return nil, fmt.Errorf("[%d]: %w", i, err)
+			}
+			items[i] = item
+		}
+		return items, nil
+	case nil:
+		return nil, fmt.Errorf("no key %q in null", key)
+	default:
+		return nil, fmt.Errorf("no key %q in %s", key, typeName(value))
+	}
+}
+
+func lookupIndex(value interface{}, index int) (interface{}, error) {
+	list, ok := value.([]interface{})
+	if !ok {
+		return nil, fmt.Errorf("can't index %s", typeName(value))
+	}
+	if index < 0 {
+		index += len(list)
+	}
+	if index < 0 || index >= len(list) {
+		return nil, fmt.Errorf("index out of range, length is %d", len(list))
+	}
+	return list[index], nil
+}
+
+func applyPipe(pipe string, value interface{}) (interface{}, error) {
+	switch pipe {
+	case "length":
+		switch v := value.(type) {
+		case []interface{}:
+			return float64(len(v)), nil
+		case map[string]interface{}:
+			return float64(len(v)), nil
+		case string:
+			return float64(len(v)), nil
+		case resource:
+			return float64(len(v.values)), nil
+		}
+	case "keys":
+		if m, ok := value.(map[string]interface{}); ok {
+			keys := make([]interface{}, 0, len(m))
+			for _, key := range sortedKeys(m) {
+				keys = append(keys, key)
+			}
+			return keys, nil
+		}
+	case "values":
+		if m, ok := value.(map[string]interface{}); ok {
+			values := make([]interface{}, 0, len(m))
+			for _, key := range sortedKeys(m) {
+				values = append(values, m[key])
+			}
+			return values, nil
+		}
+	case "sort":
+		if list, ok := value.([]interface{}); ok {
+			sorted := append([]interface{}(nil), list...)
+			sort.SliceStable(sorted, func(i, j int) bool {
+				return less(sorted[i], sorted[j])
+			})
+			return sorted, nil
+		}
+	case "unique":
+		if list, ok := value.([]interface{}); ok {
+			var unique []interface{}
+			for _, item := range list {
+				if !containsValue(unique, item) {
+					unique = append(unique, item)
+				}
+			}
+			return unique, nil
+		}
+	case "first", "last":
+		if list, ok := value.([]interface{}); ok {
+			if len(list) == 0 {
+				return nil, fmt.Errorf("%s of an empty list", pipe)
+			}
+			if pipe == "first" {
+				return list[0], nil
+			}
+			return list[len(list)-1], nil
+		}
+	case "lower", "upper":
+		if s, ok := value.(string); ok {
+			if pipe == "lower" {
+				return strings.ToLower(s), nil
+			}
+			return strings.ToUpper(s), nil
+		}
+	}
+	return nil, fmt.Errorf("can't apply %s to %s", pipe, typeName(value))
+}
+
+func compare(left interface{}, op string, right interface{}) (bool, error) {
+	left, right = normalize(left), normalize(right)
+
+	switch op {
+	case "==":
+		return reflect.DeepEqual(left, right), nil
+	case "!=":
+		return !reflect.DeepEqual(left, right), nil
+	case "<", "<=", ">", ">=":
+		ln, lok := left.(float64)
+		rn, rok := right.(float64)
+		if !lok || !rok {
+			ls, lok := left.(string)
+			rs, rok := right.(string)
+			if !lok || !rok {
+				return false, fmt.Errorf("can't order %s and %s", typeName(left), typeName(right))
+			}
+			ln, rn = float64(strings.Compare(ls, rs)), 0
+		}
+		switch op {
+		case "<":
+			return ln < rn, nil
+		case "<=":
+			return ln <= rn, nil
+		case ">":
+			return ln > rn, nil
+		default:
+			return ln >= rn, nil
+		}
+	case "contains":
+		switch l := left.(type) {
+		case []interface{}:
+			return containsValue(l, right), nil
+		case map[string]interface{}:
+			key, ok := right.(string)
+			_, found := l[key]
+			return ok && found, nil
+		case string:
+			sub, ok := right.(string)
+			if !ok {
+				return false, fmt.Errorf("contains on a string needs a string, got %s", typeName(right))
+			}
+			return strings.Contains(l, sub), nil
+		}
+		return false, fmt.Errorf("can't use contains on %s", typeName(left))
+	case "in":
+		return compare(right, "contains", left)
+	case "matches":
+		s, ok := left.(string)
+		pattern, pok := right.(string)
+		if !ok || !pok {
+			return false, fmt.Errorf("matches needs strings, got %s and %s", typeName(left), typeName(right))
+		}
+		re, err := regexp.Compile(pattern)
+		if err != nil {
+			return false, err
+		}
+		return re.MatchString(s), nil
+	}
+	return false, fmt.Errorf("unknown operator %q", op)
+}
+
+// normalize converts numbers to float64 and resources to their attributes, so
+// values from YAML, JSON and literals compare equal
+func normalize(value interface{}) interface{} {
+	switch v := value.(type) {
+	case int:
+		return float64(v)
+	case int64:
+		return float64(v)
+	case uint64:
+		return float64(v)
+	case float32:
+		return float64(v)
+	case resource:
+		return normalize(v.values)
+	case []interface{}:
+		items := make([]interface{}, len(v))
+		for i, item := range v {
+			items[i] = normalize(item)
+		}
+		return items
+	case map[string]interface{}:
+		m := make(map[string]interface{}, len(v))
+		for key, item := range v {
+			m[key] = normalize(item)
+		}
+		return m
+	case map[interface{}]interface{}:
+		m := make(map[string]interface{}, len(v))
+		for key, item := range v {
+			m[fmt.Sprint(key)] = normalize(item)
+		}
+		return m
+	default:
+		return value
+	}
+}
+
+func containsValue(list []interface{}, value interface{}) bool {
+	value = normalize(value)
+	for _, item := range list {
+		if reflect.DeepEqual(normalize(item), value) {
+			return true
+		}
+	}
+	return false
+}
+
+func less(a, b interface{}) bool {
+	a, b = normalize(a), normalize(b)
+	if an, ok := a.(float64); ok {
+		if bn, ok := b.(float64); ok {
+			return an < bn
+		}
+	}
+	return fmt.Sprint(a) < fmt.Sprint(b)
+}
+
+func truthy(value interface{}) bool {
+	switch v := normalize(value).(type) {
+	case nil:
+		return false
+	case bool:
+		return v
+	case float64:
+		return v != 0
+	case string:
+		return v != ""
+	case []interface{}:
+		return len(v) > 0
+	case map[string]interface{}:
+		return len(v) > 0
+	default:
+		return true
+	}
+}
+
+// elementsOf returns the items of a list, or the values of a map by key
+func elementsOf(value interface{}) ([]interface{}, error) {
+	switch v := value.(type) {
+	case []interface{}:
+		return v, nil
+	case map[string]interface{}:
+		values := make([]interface{}, 0, len(v))
+		for _, key := range sortedKeys(v) {
+			values = append(values, v[key])
+		}
+		return values, nil
+	}
+	return nil, fmt.Errorf("expected a list or map, got %s", typeName(value))
+}
+
+// label names an element in failure messages
+func label(element interface{}, i int) string {
+	if r, ok := element.(resource); ok {
+		return r.address
+	}
+	return fmt.Sprintf("[%d]", i)
+}
+
+// display returns a value as it should appear in results; resources are shown by address
+func display(value interface{}) interface{} {
+	switch v := value.(type) {
+	case resource:
+		return v.address
+	case []interface{}:
+		items := make([]interface{}, len(v))
+		for i, item := range v {
+			items[i] = display(item)
+		}
+		return items
+	default:
+		return normalize(value)
+	}
+}
+
+func jsonText(value interface{}) string {
+	data, err := json.Marshal(value)
+	if err != nil {
+		return fmt.Sprint(value)
+	}
+	return string(data)
+}
+
+func typeName(value interface{}) string {
+	switch normalize(value).(type) {
+	case nil:
+		return "null"
+	case bool:
+		return "bool"
+	case float64:
+		return "number"
+	case string:
+		return "string"
+	case []interface{}:
+		return "list"
+	case map[string]interface{}:
+		return "map"
+	default:
+		return fmt.Sprintf("%T", value)
+	}
+}
+
+func sortedKeys(m map[string]interface{}) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -0,0 +1,224 @@
+package assertions
+
+import (
+	"strings"
+	"testing"
+
+	"qa-test-app/internal/terraform"
+)
+
+// testData is a small workspace: outputs and state as terraform prints them
+// in JSON, and tfvars as YAML decodes them
+func testData(t *testing.T) Data {
+	t.Helper()
+	state, err := terraform.ParseState([]byte(`{
+		"format_version": "1.0",
+		"values": {
+			"outputs": {
+				"vpc_id": {"value": "vpc-1", "type": "string"},
+				"count": {"value": 2, "type": "number"},
+				"ids": {"value": ["c", "a", "b", "a"], "type": ["list", "string"]},
+				"empty": {"value": [], "type": ["list", "string"]},
+				"tags": {"value": {"Env": "qa", "Owner": "ops"}, "type": ["map", "string"]}
+			},
+			"root_module": {"resources": [
+				{"address": "aws_subnet.public[0]", "mode": "managed", "type": "aws_subnet", "name": "public", "index": 0,
+				 "values": {"id": "subnet-1", "cidr_block": "10.0.101.0/24", "map_public_ip_on_launch": true, "tags": {"Type": "public", "Env": "qa"}}},
+				{"address": "aws_subnet.private[0]", "mode": "managed", "type": "aws_subnet", "name": "private", "index": 0,
+				 "values": {"id": "subnet-2", "cidr_block": "10.0.1.0/24", "map_public_ip_on_launch": false, "tags": {"Type": "private"}}}
+			]}
+		}
+	}`))
+	if err != nil {
+		t.Fatalf("ParseState: %v", err)
+	}
+	return Data{
+		Outputs: state.Outputs(),
+		State:   state,
+		TfVars: map[string]interface{}{
+			"port":    443,
+			"ports":   []interface{}{80, 443},
+			"enabled": true,
+			"name":    "qa-test",
+			"unset":   nil,
+			"limits":  map[string]interface{}{"max": int64(5)},
+		},
+	}
+}
+
+// evaluateExpr parses and evaluates an expression against the test data
+func evaluateExpr(t *testing.T, data Data, src string) (Outcome, error) {
+	t.Helper()
+	expr, err := Parse(src)
+	if err != nil {
+		t.Fatalf("Parse(%q): %v", src, err)
+	}
+	return expr.Evaluate(data)
+}
+
+func TestEvaluatePrecedence(t *testing.T) {
+	for _, tc := range []struct {
+		expr string
+		want bool
+	}{
+		// Pipes bind tighter than operators and apply left to right
+		{`outputs.ids | length == 4`, true},
+		{`outputs.ids | unique | length == 3`, true},
+		{`outputs.ids | sort | first == "a"`, true},
+		{`outputs.ids | first | upper == "C"`, true},
+		{`outputs.ids | sort | last | upper == "C"`, true},
+		// Pipes on the right apply to the right operand only
+		{`4 == outputs.ids | length`, true},
+		{`"c" == outputs.ids | sort | last`, true},
+		{`outputs.tags | keys == ["Env", "Owner"]`, true},
+		{`outputs.tags | values | first == "qa"`, true},
+		// A predicate covers the rest of the expression
+		{`every s in state.aws_subnet has tags.Type in ["public", "private"]`, true},
+		{`some s in state.aws_subnet has map_public_ip_on_launch`, true},
+		{`every s in state.aws_subnet has map_public_ip_on_launch`, false},
+		{`no s in state.aws_subnet has cidr_block == "10.0.0.0/16"`, true},
+		{`every s in state.aws_subnet has s.tags | length >= 1`, true},
+		{`every s in state.aws_subnet has tag Type`, true},
+		{`every s in state.aws_subnet has tag Env == "qa"`, false},
+		{`some v in outputs.tags has v == "ops"`, true},
+		// Negative numbers are literals, not operators
+		{`-1 < 0`, true},
+		{`outputs.count > -2.5`, true},
+	} {
+		t.Run(tc.expr, func(t *testing.T) {
+			outcome, err := evaluateExpr(t, testData(t), tc.expr)
+			if err != nil {
+				t.Fatalf("Evaluate: %v", err)
+			}
+			if outcome.Passed != tc.want {
+				t.Errorf("passed = %v, want %v (expected %s, actual %v)", outcome.Passed, tc.want, outcome.Expected, outcome.Actual)
+			}
+		})
+	}
+}
+
+func TestEvaluateComparisons(t *testing.T) {
+	for _, tc := range []struct {
+		expr string
+		want bool
+	}{
+		// Numbers compare equal whatever they were decoded as
+		{`outputs.count == 2`, true},
+		{`outputs.count == 2.0`, true},
+		{`tfvars.port == 443`, true},
+		{`tfvars.limits.max == 5`, true},
+		{`tfvars.ports == [80, 443]`, true},
+		{`tfvars.ports contains 443`, true},
+		{`443 in tfvars.ports`, true},
+		{`tfvars.port <= outputs.count`, false},
+		// Values of different types are never equal
+		{`tfvars.port == "443"`, false},
+		{`tfvars.port != "443"`, true},
+		{`tfvars.enabled == "true"`, false},
+		{`tfvars.enabled == 1`, false},
+		{`tfvars.unset == null`, true},
+		{`tfvars.unset == ""`, false},
+		{`outputs.empty == null`, false},
+		{`tfvars.ports contains "443"`, false},
+		// Strings order lexically
+		{`"b" > "a"`, true},
+		{`"10" < "9"`, true},
+		{`outputs.vpc_id contains "vpc"`, true},
+		{`"vpc" in outputs.vpc_id`, true},
+		{`outputs.tags contains "Env"`, true},
+		{`outputs.tags contains "qa"`, false},
+		{`tfvars.name matches "^qa-"`, true},
+		{`tfvars.name matches 'test$'`, true},
+		// Truthiness without an operator
+		{`tfvars.enabled`, true},
+		{`tfvars.unset`, false},
+		{`outputs.empty`, false},
+		{`outputs.count`, true},
+	} {
+		t.Run(tc.expr, func(t *testing.T) {
+			outcome, err := evaluateExpr(t, testData(t), tc.expr)
+			if err != nil {
+				t.Fatalf("Evaluate: %v", err)
+			}
+			if outcome.Passed != tc.want {
+				t.Errorf("passed = %v, want %v (expected %s, actual %v)", outcome.Passed, tc.want, outcome.Expected, outcome.Actual)
+			}
+		})
+	}
+}
+
+func TestEvaluateErrors(t *testing.T) {
+	for _, tc := range []struct {
+		expr string
+		want string
+	}{
+		// Comparisons that have no answer between the types
+		{`tfvars.port < "5"`, "can't order number and string"},
+		{`tfvars.enabled > false`, "can't order bool and bool"},
+		{`outputs.vpc_id contains 1`, "contains on a string needs a string, got number"},
+		{`tfvars.port contains 4`, "can't use contains on number"},
+		{`tfvars.port matches "4"`, "matches needs strings, got number and string"},
+		{`tfvars.name matches "("`, "missing closing )"},
+		// Missing paths name the path up to where the lookup failed
+		{`outputs.nope == 1`, `outputs.nope: no key "nope"`},
+		{`outputs.ids[4] == "a"`, "outputs.ids[4]: index out of range, length is 4"},
+		{`outputs.ids[-5] == "a"`, "outputs.ids[-5]: index out of range, length is 4"},
+		{`outputs.vpc_id.name == "x"`, `outputs.vpc_id.name: no key "name" in string`},
+		{`outputs.vpc_id[0] == "v"`, "outputs.vpc_id[0]: can't index string"},
+		{`tfvars.unset.x == 1`, `tfvars.unset.x: no key "x" in null`},
+		{`tfvars.missing == 1`, `tfvars.missing: no key "missing"`},
+		{`state.aws_subnet.nope == 1`, `state.aws_subnet.nope: [0]: aws_subnet.public[0] has no attribute "nope"`},
+		{`state.aws_subnet[2].id == "x"`, "state.aws_subnet[2]: index out of range, length is 2"},
+		{`state.aws_subnet[1].tags.Env == "qa"`, `state.aws_subnet[1].tags.Env: no key "Env"`},
+		{`every s in state.aws_subnet has tags.Env == "qa"`, `aws_subnet.private[0]: tags.Env: no key "Env"`},
+		{`outputs.empty | first == "a"`, "outputs.empty | first: first of an empty list"},
+		{`outputs.vpc_id | keys == []`, "outputs.vpc_id | keys: can't apply keys to string"},
+		{`every x in outputs.vpc_id has x == "v"`, "outputs.vpc_id: expected a list or map, got string"},
+	} {
+		t.Run(tc.expr, func(t *testing.T) {
+			_, err := evaluateExpr(t, testData(t), tc.expr)
+			if err == nil || !strings.Contains(err.Error(), tc.want) {
+				t.Errorf("error = %v, want %q", err, tc.want)
+			}
+		})
+	}
+}
+
+func TestEvaluateWithoutWorkspace(t *testing.T) {
+	for _, tc := range []struct {
+		expr string
+		want string
+	}{
+		{`outputs.vpc_id == "vpc-1"`, "outputs.vpc_id: no outputs available"},
+		{`state.aws_subnet | length == 2`, "state.aws_subnet | length: no state available"},
+	} {
+		if _, err := evaluateExpr(t, Data{}, tc.expr); err == nil || err.Error() != tc.want {
+			t.Errorf("%s: error = %v, want %q", tc.expr, err, tc.want)
+		}
+	}
+}
+
+func TestEvaluateQuantifiedOutcome(t *testing.T) {
+	data := testData(t)
+
+	outcome, err := evaluateExpr(t, data, `every s in state.aws_subnet has tag Env == "qa"`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(outcome.Failures) != 1 || outcome.Failures[0] != "aws_subnet.private[0]: missing tag Env" {
+		t.Errorf("failures = %q, want the private subnet", outcome.Failures)
+	}
+
+	// A resource type the state doesn't have is an empty list, which every rejects
+	outcome, err = evaluateExpr(t, data, `every n in state.aws_nat_gateway has tag Name`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if outcome.Passed || outcome.Expected != "state.aws_nat_gateway to be non-empty" {
+		t.Errorf("outcome = %+v, want a failure for the empty collection", outcome)
+	}
+	outcome, err = evaluateExpr(t, data, `no n in state.aws_nat_gateway has tag Name`)
+	if err != nil || !outcome.Passed {
+		t.Errorf("no over an empty collection: %+v, %v", outcome, err)
+	}
+}
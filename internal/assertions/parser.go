@@ -0,0 +1,359 @@
+package assertions
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Roots are the names an expression can start from
+var Roots = []string{"outputs", "state", "tfvars"}
+
+// Pipes are the functions that can follow a value with |
+var Pipes = []string{"length", "keys", "values", "sort", "unique", "first", "last", "lower", "upper"}
+
+// Operators compare the value on the left with the one on the right
+var Operators = []string{"==", "!=", "<", "<=", ">", ">=", "contains", "in", "matches"}
+
+var quantifiers = []string{"every", "some", "no"}
+
+// Expression is a parsed assertion
+type Expression struct {
+	src        string
+	comparison *comparison
+	quantified *quantified
+}
+
+func (e *Expression) String() string {
+	return e.src
+}
+
+// comparison is "value [op value]"; without an operator the value must be truthy
+type comparison struct {
+	left  *valueExpr
+	op    string
+	right *valueExpr
+}
+
+// quantified is "every|some|no NAME in value has predicate"
+type quantified struct {
+	quantifier string
+	variable   string
+	collection *valueExpr
+	// Exactly one of tag and predicate is set
+	tag       *tagPredicate
+	predicate *comparison
+}
+
+// tagPredicate is "tag KEY [op value]"; without an operator the tag only has to exist
+type tagPredicate struct {
+	key   string
+	op    string
+	value *valueExpr
+}
+
+// valueExpr is a literal or a path, followed by pipes
+type valueExpr struct {
+	src     string
+	literal interface{}
+	isLit   bool
+	// root is the first name of a path; element marks a path relative to the quantified element
+	root     string
+	element  bool
+	segments []segment
+	pipes    []string
+}
+
+// segment is ".name", "[0]" or `["name"]`
+type segment struct {
+	key     string
+	index   int
+	isIndex bool
+}
+
+type parser struct {
+	src    string
+	tokens []token
+	pos    int
+	// variable is the name bound by the quantifier being parsed, if any
+	variable string
+}
+
+// Parse parses an assertion expression such as
+//
+//	outputs.private_subnet_ids | length == 2
+//	every subnet in state.aws_subnet has tag Environment == "test"
+func Parse(src string) (*Expression, error) {
+	tokens, err := lex(src)
+	if err != nil {
+		return nil, err
+	}
+	p := &parser{src: src, tokens: tokens}
+
+	expr := &Expression{src: strings.TrimSpace(src)}
+	if p.peekQuantifier() {
+		expr.quantified, err = p.parseQuantified()
+	} else {
+		expr.comparison, err = p.parseComparison()
+	}
+	if err != nil {
+		return nil, err
+	}
+	if tok := p.peek(); tok.kind != tokenEOF {
+		return nil, p.errorf(tok, "unexpected %s", tok)
+	}
+	return expr, nil
+}
+
+func (p *parser) peek() token {
+	return p.tokens[p.pos]
+}
+
+func (p *parser) next() token {
+	tok := p.tokens[p.pos]
+	if tok.kind != tokenEOF {
+		p.pos++
+	}
+	return tok
+}
+
+func (p *parser) errorf(tok token, format string, args ...interface{}) error {
+	return &SyntaxError{Pos: tok.pos, Msg: fmt.Sprintf(format, args...)}
+}
+
+func (p *parser) expectIdent(word string) error {
+	tok := p.next()
+	if tok.kind != tokenIdent || tok.text != word {
+		return p.errorf(tok, "expected %q, got %s", word, tok)
+	}
+	return nil
+}
+
+func (p *parser) expectPunct(punct string) error {
+	tok := p.next()
+	if tok.kind != tokenPunct || tok.text != punct {
+		return p.errorf(tok, "expected %q, got %s", punct, tok)
+	}
+	return nil
+}
+
+// peekQuantifier reports whether the expression starts with "every|some|no NAME in"
+func (p *parser) peekQuantifier() bool {
+	if len(p.tokens) < 3 || !contains(quantifiers, p.tokens[0].text) || p.tokens[0].kind != tokenIdent {
+		return false
+	}
+	return p.tokens[1].kind == tokenIdent && p.tokens[2].kind == tokenIdent && p.tokens[2].text == "in"
+}
+
+func (p *parser) parseQuantified() (*quantified, error) {
+	q := &quantified{quantifier: p.next().text, variable: p.next().text}
+	p.next() // in
+
+	if contains(Roots, q.variable) {
+		return nil, p.errorf(p.tokens[1], "%q can't be used as a variable name", q.variable)
+	}
+
+	collection, err := p.parseValue()
+	if err != nil {
+		return nil, err
+	}
+	q.collection = collection
+
+	if err := p.expectIdent("has"); err != nil {
+		return nil, err
+	}
+
+	p.variable = q.variable
+	defer func() { p.variable = "" }()
+
+	if tok := p.peek(); tok.kind == tokenIdent && tok.text == "tag" {
+		p.next()
+		q.tag, err = p.parseTagPredicate()
+	} else {
+		q.predicate, err = p.parseComparison()
+	}
+	if err != nil {
+		return nil, err
+	}
+	return q, nil
+}
+
+func (p *parser) parseTagPredicate() (*tagPredicate, error) {
+	tok := p.next()
+	if tok.kind != tokenIdent && tok.kind != tokenString {
+		return nil, p.errorf(tok, "expected a tag name, got %s", tok)
+	}
+	tag := &tagPredicate{key: tok.text}
+	if tok.kind == tokenString {
+		tag.key = tok.value.(string)
+	}
+
+	if op, ok := p.parseOperator(); ok {
+		value, err := p.parseValue()
+		if err != nil {
+			return nil, err
+		}
+		tag.op, tag.value = op, value
+	}
+	return tag, nil
+}
+
+func (p *parser) parseComparison() (*comparison, error) {
+	left, err := p.parseValue()
+	if err != nil {
+		return nil, err
+	}
+	c := &comparison{left: left}
+
+	if op, ok := p.parseOperator(); ok {
+		right, err := p.parseValue()
+		if err != nil {
+			return nil, err
+		}
+		c.op, c.right = op, right
+	}
+	return c, nil
+}
+
+func (p *parser) parseOperator() (string, bool) {
+	tok := p.peek()
+	if (tok.kind == tokenOperator || tok.kind == tokenIdent) && contains(Operators, tok.text) {
+		p.next()
+		return tok.text, true
+	}
+	return "", false
+}
+
+func (p *parser) parseValue() (*valueExpr, error) {
+	start := p.peek().pos
+	v, err := p.parseOperand()
+	if err != nil {
+		return nil, err
+	}
+
+	for {
+		tok := p.peek()
+		if tok.kind != tokenPunct || tok.text != "|" {
+			break
+		}
+		p.next()
+		name := p.next()
+		if name.kind != tokenIdent || !contains(Pipes, name.text) {
+			return nil, p.errorf(name, "unknown pipe %s, expected one of: %s", name, strings.Join(Pipes, ", "))
+		}
+		v.pipes = append(v.pipes, name.text)
+	}
+
+	v.src = strings.TrimSpace(p.src[start:p.peek().pos])
+	return v, nil
+}
+
+func (p *parser) parseOperand() (*valueExpr, error) {
+	tok := p.next()
+	switch tok.kind {
+	case tokenString, tokenNumber:
+		return &valueExpr{literal: tok.value, isLit: true}, nil
+	case tokenPunct:
+		if tok.text == "[" {
+			return p.parseList()
+		}
+	case tokenIdent:
+		switch tok.text {
+		case "true", "false":
+			return &valueExpr{literal: tok.text == "true", isLit: true}, nil
+		case "null":
+			return &valueExpr{literal: nil, isLit: true}, nil
+		}
+		return p.parsePath(tok)
+	}
+	return nil, p.errorf(tok, "expected a value, got %s", tok)
+}
+
+func (p *parser) parseList() (*valueExpr, error) {
+	var items []interface{}
+	for {
+		if tok := p.peek(); tok.kind == tokenPunct && tok.text == "]" {
+			p.next()
+			return &valueExpr{literal: items, isLit: true}, nil
+		}
+		start := p.peek()
+		item, err := p.parseOperand()
+		if err != nil {
+			return nil, err
+		}
+		if !item.isLit {
+			return nil, p.errorf(start, "list items must be literals")
+		}
+		items = append(items, item.literal)
+
+		if tok := p.peek(); tok.kind == tokenPunct && tok.text == "," {
+			p.next()
+		} else if tok.kind != tokenPunct || tok.text != "]" {
+			return nil, p.errorf(tok, "expected \",\" or \"]\", got %s", tok)
+		}
+	}
+}
+
+func (p *parser) parsePath(first token) (*valueExpr, error) {
+	v := &valueExpr{root: first.text}
+	switch {
+	case p.variable != "" && first.text == p.variable:
+		v.element = true
+		v.root = ""
+	case contains(Roots, first.text):
+	case p.variable != "":
+		// A bare name inside a predicate is an attribute of the element
+		v.element = true
+		v.root = ""
+		v.segments = append(v.segments, segment{key: first.text})
+	default:
+		return nil, p.errorf(first, "unknown name %q, expected one of: %s", first.text, strings.Join(Roots, ", "))
+	}
+
+	for {
+		tok := p.peek()
+		if tok.kind != tokenPunct || (tok.text != "." && tok.text != "[") {
+			break
+		}
+		p.next()
+
+		if tok.text == "." {
+			name := p.next()
+			if name.kind != tokenIdent {
+				return nil, p.errorf(name, "expected a name after \".\", got %s", name)
+			}
+			v.segments = append(v.segments, segment{key: name.text})
+			continue
+		}
+
+		key := p.next()
+		switch key.kind {
+		case tokenNumber:
+			index := key.value.(float64)
+			if index != float64(int(index)) {
+				return nil, p.errorf(key, "index must be a whole number")
+			}
+			v.segments = append(v.segments, segment{index: int(index), isIndex: true})
+		case tokenString:
+			v.segments = append(v.segments, segment{key: key.value.(string)})
+		default:
+			return nil, p.errorf(key, "expected an index or a quoted key, got %s", key)
+		}
+		if err := p.expectPunct("]"); err != nil {
+			return nil, err
+		}
+	}
+
+	if v.root == "state" && (len(v.segments) == 0 || v.segments[0].isIndex) {
+		return nil, p.errorf(first, "state must be followed by a resource type, e.g. state.aws_subnet")
+	}
+	return v, nil
+}
+
+func contains(list []string, s string) bool {
+	for _, item := range list {
+		if item == s {
+			return true
+		}
+	}
+	return false
+}
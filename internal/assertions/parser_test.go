@@ -0,0 +1,112 @@
+package assertions
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestParseErrorPositions(t *testing.T) {
+	for _, tc := range []struct {
+		expr string
+		pos  int
+		msg  string
+	}{
+		{`outputs.vpc_id ==`, 17, "expected a value, got end of expression"},
+		{`outputs.ids | size == 2`, 14, `unknown pipe "size"`},
+		{`vpc.id == "x"`, 0, `unknown name "vpc"`},
+		{`outputs.vpc_id == "vpc-1`, 18, "unterminated string"},
+		{`outputs.vpc_id # "x"`, 15, "unexpected character '#'"},
+		{`outputs.count == 1 2`, 19, `unexpected "2"`},
+		{`outputs.a == outputs.b == true`, 23, `unexpected "=="`},
+		{`outputs.ids[1.5] == "a"`, 12, "index must be a whole number"},
+		{`outputs.ids[name]`, 12, "expected an index or a quoted key"},
+		{`outputs.ids[0 == 1`, 14, `expected "]", got "=="`},
+		{`outputs. == 1`, 9, `expected a name after ".", got "=="`},
+		{`outputs.ids in ["a" "b"]`, 20, `expected "," or "]", got "\"b\""`},
+		{`outputs.ids == ["a", outputs.b]`, 21, "list items must be literals"},
+		{`state[0].id == "x"`, 0, "state must be followed by a resource type"},
+		{`every outputs in tfvars.x has y`, 6, `"outputs" can't be used as a variable name`},
+		{`every s in state.aws_subnet with tag Name`, 28, `expected "has", got "with"`},
+		{`every s in state.aws_subnet has tag == "x"`, 36, `expected a tag name, got "=="`},
+		{`   `, 3, "expected a value, got end of expression"},
+		// "-" isn't part of a name, and a number where a name or operator belongs
+		{`outputs.a-1 == 2`, 9, `unexpected "-1"`},
+		{`outputs.a-b == 2`, 9, "unexpected character '-'"},
+		{`tfvars.name matches '\d+'`, 20, `invalid string '\d+'`},
+	} {
+		t.Run(tc.expr, func(t *testing.T) {
+			_, err := Parse(tc.expr)
+			var syntaxErr *SyntaxError
+			if !errors.As(err, &syntaxErr) {
+				t.Fatalf("Parse(%q) error = %v, want a SyntaxError", tc.expr, err)
+			}
+			if syntaxErr.Pos != tc.pos || !strings.Contains(syntaxErr.Msg, tc.msg) {
+				t.Errorf("Parse(%q) = error at %d: %s, want at %d: %s", tc.expr, syntaxErr.Pos, syntaxErr.Msg, tc.pos, tc.msg)
+			}
+		})
+	}
+}
+
+func TestSyntaxErrorColumn(t *testing.T) {
+	_, err := Parse(`outputs.vpc_id ==`)
+	if err == nil || err.Error() != "column 18: expected a value, got end of expression" {
+		t.Errorf("error = %v, want it to name column 18", err)
+	}
+}
+
+func TestParseString(t *testing.T) {
+	expr, err := Parse("  outputs.ids | length   == 2 ")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := expr.String(); got != "outputs.ids | length   == 2" {
+		t.Errorf("String() = %q", got)
+	}
+	if got := expr.comparison.left.src; got != "outputs.ids | length" {
+		t.Errorf("left operand = %q, want the path and its pipes", got)
+	}
+}
+
+func TestParseStringEscapes(t *testing.T) {
+	for _, tc := range []struct {
+		src  string
+		want string
+	}{
+		{`"a\nb"`, "a\nb"},
+		{`'a\nb'`, "a\nb"},
+		{`"tab\there"`, "tab\there"},
+		{`'tab\there'`, "tab\there"},
+		{`"\\d+"`, `\d+`},
+		{`'\\d+'`, `\d+`},
+		{`"it's"`, "it's"},
+		{`'it\'s'`, "it's"},
+		{`"it\'s"`, "it's"},
+		{`'say "hi"'`, `say "hi"`},
+		{`'say \"hi\"'`, `say "hi"`},
+		{`"say \"hi\""`, `say "hi"`},
+		{`'\u00e9t\u00e9'`, "été"},
+	} {
+		t.Run(tc.src, func(t *testing.T) {
+			expr, err := Parse(tc.src)
+			if err != nil {
+				t.Fatalf("Parse: %v", err)
+			}
+			if got := expr.comparison.left.literal; got != tc.want {
+				t.Errorf("value = %q, want %q", got, tc.want)
+			}
+		})
+	}
+}
+
+func TestParseQuotedKeys(t *testing.T) {
+	// Names that aren't identifiers are looked up with a quoted key
+	expr, err := Parse(`outputs["a-1"] == 'x'`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	segments := expr.comparison.left.segments
+	if len(segments) != 1 || segments[0].key != "a-1" {
+		t.Errorf("segments = %+v, want the key a-1", segments)
+	}
+}
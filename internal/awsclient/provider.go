@@ -0,0 +1,110 @@
+// Package awsclient creates the AWS clients test functions use, configured
+// for the test case being run
+package awsclient
+
+import (
+	"fmt"
+	"sync"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/credentials"
+	"github.com/aws/aws-sdk-go/aws/credentials/stscreds"
+	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/aws/aws-sdk-go/service/ec2"
+	"github.com/aws/aws-sdk-go/service/ec2/ec2iface"
+)
+
+// RoleSessionName identifies qa-test-app in CloudTrail when a role is assumed
+const RoleSessionName = "qa-test-app"
+
+// RegionKeys are the tfvars and output names a region is read from, in order
+var RegionKeys = []string{"region", "aws_region"}
+
+// Config selects the account, region and endpoint clients talk to
+type Config struct {
+	// Region falls back to the SDK defaults, AWS_REGION or the profile's region, when empty
+	Region string
+	// Profile is a shared config profile; empty uses AWS_PROFILE or the default chain
+	Profile string
+	// AssumeRoleARN, when set, is assumed with the profile's credentials
+	AssumeRoleARN string
+	// Endpoint overrides the AWS endpoint, e.g. for a local emulator
+	Endpoint string
+	// AccessKeyID and SecretAccessKey, when set, replace the credential chain,
+	// e.g. with the dummy credentials a local emulator accepts
+	AccessKeyID     string
+	SecretAccessKey string
+}
+
+// Provider hands out AWS clients that share one session
+type Provider struct {
+	Config Config
+
+	once sync.Once
+	sess *session.Session
+	err  error
+}
+
+// NewProvider creates a provider; the session is created on first use
+func NewProvider(cfg Config) *Provider {
+	return &Provider{Config: cfg}
+}
+
+// Region returns the first region found in tfvars, then outputs, or "" if neither has one
+func Region(tfvars, outputs map[string]interface{}) string {
+	for _, values := range []map[string]interface{}{tfvars, outputs} {
+		for _, key := range RegionKeys {
+			if region, ok := values[key].(string); ok && region != "" {
+				return region
+			}
+		}
+	}
+	return ""
+}
+
+// Session returns the shared session, creating it on the first call
+func (p *Provider) Session() (*session.Session, error) {
+	p.once.Do(func() {
+		p.sess, p.err = p.newSession()
+	})
+	return p.sess, p.err
+}
+
+func (p *Provider) newSession() (*session.Session, error) {
+	cfg := aws.NewConfig()
+	if p.Config.Region != "" {
+		cfg.WithRegion(p.Config.Region)
+	}
+	if p.Config.Endpoint != "" {
+		cfg.WithEndpoint(p.Config.Endpoint)
+	}
+	if p.Config.AccessKeyID != "" {
+		cfg.WithCredentials(credentials.NewStaticCredentials(p.Config.AccessKeyID, p.Config.SecretAccessKey, ""))
+	}
+
+	sess, err := session.NewSessionWithOptions(session.Options{
+		Config:            *cfg,
+		Profile:           p.Config.Profile,
+		SharedConfigState: session.SharedConfigEnable,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("AWS session creation failed: %w", err)
+	}
+
+	if p.Config.AssumeRoleARN != "" {
+		creds := stscreds.NewCredentials(sess, p.Config.AssumeRoleARN, func(arp *stscreds.AssumeRoleProvider) {
+			arp.RoleSessionName = RoleSessionName
+		})
+		sess = sess.Copy(aws.NewConfig().WithCredentials(creds))
+	}
+	return sess, nil
+}
+
+// EC2 returns an EC2 client on the shared session
+func (p *Provider) EC2() (ec2iface.EC2API, error) {
+	sess, err := p.Session()
+	if err != nil {
+		return nil, err
+	}
+	return ec2.New(sess), nil
+}
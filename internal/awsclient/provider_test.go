@@ -0,0 +1,157 @@
+package awsclient
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/credentials"
+	"github.com/aws/aws-sdk-go/service/ec2"
+)
+
+// isolate keeps the environment and the user's AWS files out of a test. The
+// shared config file it returns is read for profiles.
+func isolate(t *testing.T) string {
+	t.Helper()
+	for _, key := range []string{
+		"AWS_PROFILE", "AWS_DEFAULT_PROFILE", "AWS_REGION", "AWS_DEFAULT_REGION",
+		"AWS_ACCESS_KEY_ID", "AWS_SECRET_ACCESS_KEY", "AWS_SESSION_TOKEN",
+		"AWS_ROLE_ARN", "AWS_WEB_IDENTITY_TOKEN_FILE", "AWS_CA_BUNDLE",
+	} {
+		t.Setenv(key, "")
+		os.Unsetenv(key)
+	}
+	dir := t.TempDir()
+	config := filepath.Join(dir, "config")
+	t.Setenv("AWS_CONFIG_FILE", config)
+	t.Setenv("AWS_SHARED_CREDENTIALS_FILE", filepath.Join(dir, "credentials"))
+	return config
+}
+
+func TestRegion(t *testing.T) {
+	for _, tc := range []struct {
+		name    string
+		tfvars  map[string]interface{}
+		outputs map[string]interface{}
+		want    string
+	}{
+		{
+			name:    "tfvars before outputs",
+			tfvars:  map[string]interface{}{"aws_region": "eu-west-1"},
+			outputs: map[string]interface{}{"region": "us-east-1"},
+			want:    "eu-west-1",
+		},
+		{
+			name:   "region before aws_region",
+			tfvars: map[string]interface{}{"aws_region": "eu-west-1", "region": "eu-central-1"},
+			want:   "eu-central-1",
+		},
+		{
+			name:    "outputs when tfvars have none",
+			tfvars:  map[string]interface{}{"vpc_cidr": "10.0.0.0/16"},
+			outputs: map[string]interface{}{"aws_region": "us-west-2"},
+			want:    "us-west-2",
+		},
+		{
+			name:    "empty and non-string values are skipped",
+			tfvars:  map[string]interface{}{"region": "", "aws_region": 1},
+			outputs: map[string]interface{}{"region": "ap-south-1"},
+			want:    "ap-south-1",
+		},
+		{
+			name: "none",
+			want: "",
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			if got := Region(tc.tfvars, tc.outputs); got != tc.want {
+				t.Errorf("Region() = %q, want %q", got, tc.want)
+			}
+		})
+	}
+}
+
+func TestSessionEndpoint(t *testing.T) {
+	isolate(t)
+	p := NewProvider(Config{Region: "us-east-1", Endpoint: "http://localhost:4566"})
+
+	sess, err := p.Session()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := aws.StringValue(sess.Config.Region); got != "us-east-1" {
+		t.Errorf("region = %q, want us-east-1", got)
+	}
+	client, err := p.EC2()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := client.(*ec2.EC2).Endpoint; got != "http://localhost:4566" {
+		t.Errorf("EC2 endpoint = %q, want the override", got)
+	}
+
+	again, _ := p.Session()
+	if again != sess {
+		t.Error("Session() created a second session, want the first one shared")
+	}
+}
+
+func TestSessionStaticCredentials(t *testing.T) {
+	isolate(t)
+	p := NewProvider(Config{Region: "us-east-1", AccessKeyID: "test", SecretAccessKey: "secret"})
+
+	sess, err := p.Session()
+	if err != nil {
+		t.Fatal(err)
+	}
+	creds, err := sess.Config.Credentials.Get()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if creds.AccessKeyID != "test" || creds.SecretAccessKey != "secret" || creds.ProviderName != credentials.StaticProviderName {
+		t.Errorf("credentials = %s/%s from %s, want the static ones", creds.AccessKeyID, creds.SecretAccessKey, creds.ProviderName)
+	}
+}
+
+func TestSessionProfile(t *testing.T) {
+	config := isolate(t)
+	profile := "[profile qa]\nregion = eu-north-1\naws_access_key_id = qa-key\naws_secret_access_key = qa-secret\n"
+	if err := os.WriteFile(config, []byte(profile), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	sess, err := NewProvider(Config{Profile: "qa"}).Session()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := aws.StringValue(sess.Config.Region); got != "eu-north-1" {
+		t.Errorf("region = %q, want the profile's", got)
+	}
+	creds, err := sess.Config.Credentials.Get()
+	if err != nil || creds.AccessKeyID != "qa-key" {
+		t.Errorf("credentials = %v (%v), want the profile's", creds.AccessKeyID, err)
+	}
+
+	// An explicit region wins over the profile's
+	sess, err = NewProvider(Config{Profile: "qa", Region: "us-east-2"}).Session()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := aws.StringValue(sess.Config.Region); got != "us-east-2" {
+		t.Errorf("region = %q, want the configured one", got)
+	}
+}
+
+func TestSessionError(t *testing.T) {
+	isolate(t)
+	t.Setenv("AWS_CA_BUNDLE", filepath.Join(t.TempDir(), "missing.pem"))
+	p := NewProvider(Config{Region: "us-east-1"})
+	if _, err := p.Session(); err == nil || !strings.HasPrefix(err.Error(), "AWS session creation failed") {
+		t.Errorf("Session() error = %v, want the CA bundle reported", err)
+	}
+	if _, err := p.EC2(); err == nil {
+		t.Error("EC2() succeeded, want the session error")
+	}
+}
@@ -0,0 +1,368 @@
+// Package fakeaws is an in-memory stand-in for the AWS APIs test functions
+// call, seeded from a terraform state or a fixture file, so test functions
+// can run without AWS
+package fakeaws
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"qa-test-app/internal/network"
+	"qa-test-app/internal/terraform"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/aws/awsutil"
+	"github.com/aws/aws-sdk-go/aws/request"
+	"github.com/aws/aws-sdk-go/service/ec2"
+	"github.com/aws/aws-sdk-go/service/ec2/ec2iface"
+)
+
+// EC2 answers the describe calls for VPCs, subnets, route tables, network ACLs
+// and security groups from memory. ID lists and the common filters behave as
+// in EC2, and unsupported filters are rejected; other calls panic through the
+// nil embedded interface. The resources must not be changed while in use.
+type EC2 struct {
+	ec2iface.EC2API
+
+	Vpcs           []*ec2.Vpc
+	Subnets        []*ec2.Subnet
+	RouteTables    []*ec2.RouteTable
+	NetworkAcls    []*ec2.NetworkAcl
+	SecurityGroups []*ec2.SecurityGroup
+}
+
+// Provider hands out the fake as the EC2 client of a test environment
+type Provider struct {
+	Fake *EC2
+}
+
+// EC2 returns the fake
+func (p Provider) EC2() (ec2iface.EC2API, error) {
+	return p.Fake, nil
+}
+
+// FromState builds a fake that describes the network resources of a
+// terraform state, see network.FromState
+func FromState(state *terraform.State) (*EC2, error) {
+	inv, err := network.FromState(state)
+	if err != nil {
+		return nil, err
+	}
+	return &EC2{
+		Vpcs:           inv.Vpcs,
+		Subnets:        inv.Subnets,
+		RouteTables:    inv.RouteTables,
+		NetworkAcls:    inv.NetworkAcls,
+		SecurityGroups: inv.SecurityGroups,
+	}, nil
+}
+
+func (f *EC2) DescribeVpcs(input *ec2.DescribeVpcsInput) (*ec2.DescribeVpcsOutput, error) {
+	return f.DescribeVpcsWithContext(context.Background(), input)
+}
+
+func (f *EC2) DescribeVpcsWithContext(ctx context.Context, input *ec2.DescribeVpcsInput, _ ...request.Option) (*ec2.DescribeVpcsOutput, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	output := &ec2.DescribeVpcsOutput{}
+	err := describe(len(f.Vpcs), input.VpcIds, "InvalidVpcID.NotFound", input.Filters,
+		func(i int) string { return aws.StringValue(f.Vpcs[i].VpcId) },
+		func(i int, name string) ([]string, bool) { return vpcFilter(f.Vpcs[i], name) },
+		func(i int) { output.Vpcs = append(output.Vpcs, awsutil.CopyOf(f.Vpcs[i]).(*ec2.Vpc)) })
+	if err != nil {
+		return nil, err
+	}
+	return output, nil
+}
+
+func (f *EC2) DescribeSubnets(input *ec2.DescribeSubnetsInput) (*ec2.DescribeSubnetsOutput, error) {
+	return f.DescribeSubnetsWithContext(context.Background(), input)
+}
+
+func (f *EC2) DescribeSubnetsWithContext(ctx context.Context, input *ec2.DescribeSubnetsInput, _ ...request.Option) (*ec2.DescribeSubnetsOutput, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	output := &ec2.DescribeSubnetsOutput{}
+	err := describe(len(f.Subnets), input.SubnetIds, "InvalidSubnetID.NotFound", input.Filters,
+		func(i int) string { return aws.StringValue(f.Subnets[i].SubnetId) },
+		func(i int, name string) ([]string, bool) { return subnetFilter(f.Subnets[i], name) },
+		func(i int) { output.Subnets = append(output.Subnets, awsutil.CopyOf(f.Subnets[i]).(*ec2.Subnet)) })
+	if err != nil {
+		return nil, err
+	}
+	return output, nil
+}
+
+func (f *EC2) DescribeRouteTables(input *ec2.DescribeRouteTablesInput) (*ec2.DescribeRouteTablesOutput, error) {
+	return f.DescribeRouteTablesWithContext(context.Background(), input)
+}
+
+func (f *EC2) DescribeRouteTablesWithContext(ctx context.Context, input *ec2.DescribeRouteTablesInput, _ ...request.Option) (*ec2.DescribeRouteTablesOutput, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	output := &ec2.DescribeRouteTablesOutput{}
+	err := describe(len(f.RouteTables), input.RouteTableIds, "InvalidRouteTableID.NotFound", input.Filters,
+		func(i int) string { return aws.StringValue(f.RouteTables[i].RouteTableId) },
+		func(i int, name string) ([]string, bool) { return routeTableFilter(f.RouteTables[i], name) },
+		func(i int) {
+			output.RouteTables = append(output.RouteTables, awsutil.CopyOf(f.RouteTables[i]).(*ec2.RouteTable))
+		})
+	if err != nil {
+		return nil, err
+	}
+	return output, nil
+}
+
+func (f *EC2) DescribeNetworkAcls(input *ec2.DescribeNetworkAclsInput) (*ec2.DescribeNetworkAclsOutput, error) {
+	return f.DescribeNetworkAclsWithContext(context.Background(), input)
+}
+
+func (f *EC2) DescribeNetworkAclsWithContext(ctx context.Context, input *ec2.DescribeNetworkAclsInput, _ ...request.Option) (*ec2.DescribeNetworkAclsOutput, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	output := &ec2.DescribeNetworkAclsOutput{}
+	err := describe(len(f.NetworkAcls), input.NetworkAclIds, "InvalidNetworkAclID.NotFound", input.Filters,
+		func(i int) string { return aws.StringValue(f.NetworkAcls[i].NetworkAclId) },
+		func(i int, name string) ([]string, bool) { return networkAclFilter(f.NetworkAcls[i], name) },
+		func(i int) {
+			output.NetworkAcls = append(output.NetworkAcls, awsutil.CopyOf(f.NetworkAcls[i]).(*ec2.NetworkAcl))
+		})
+	if err != nil {
+		return nil, err
+	}
+	return output, nil
+}
+
+func (f *EC2) DescribeSecurityGroups(input *ec2.DescribeSecurityGroupsInput) (*ec2.DescribeSecurityGroupsOutput, error) {
+	return f.DescribeSecurityGroupsWithContext(context.Background(), input)
+}
+
+func (f *EC2) DescribeSecurityGroupsWithContext(ctx context.Context, input *ec2.DescribeSecurityGroupsInput, _ ...request.Option) (*ec2.DescribeSecurityGroupsOutput, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	filters := input.Filters
+	if len(input.GroupNames) > 0 {
+		filters = append(filters, &ec2.Filter{Name: aws.String("group-name"), Values: input.GroupNames})
+	}
+	output := &ec2.DescribeSecurityGroupsOutput{}
+	err := describe(len(f.SecurityGroups), input.GroupIds, "InvalidGroup.NotFound", filters,
+		func(i int) string { return aws.StringValue(f.SecurityGroups[i].GroupId) },
+		func(i int, name string) ([]string, bool) { return securityGroupFilter(f.SecurityGroups[i], name) },
+		func(i int) {
+			output.SecurityGroups = append(output.SecurityGroups, awsutil.CopyOf(f.SecurityGroups[i]).(*ec2.SecurityGroup))
+		})
+	if err != nil {
+		return nil, err
+	}
+	return output, nil
+}
+
+// describe selects the resources matching ids and filters. Like EC2, it fails
+// if an ID doesn't exist or a filter name isn't supported.
+func describe(count int, ids []*string, notFoundCode string, filters []*ec2.Filter,
+	id func(i int) string, values func(i int, name string) ([]string, bool), add func(i int)) error {
+
+	wanted := make(map[string]bool, len(ids))
+	for _, want := range ids {
+		wanted[aws.StringValue(want)] = false
+	}
+
+	for i := 0; i < count; i++ {
+		if len(ids) > 0 {
+			if _, ok := wanted[id(i)]; !ok {
+				continue
+			}
+			wanted[id(i)] = true
+		}
+
+		match := true
+		for _, filter := range filters {
+			name := aws.StringValue(filter.Name)
+			actual, ok := values(i, name)
+			if !ok {
+				return awserr.New("InvalidParameterValue", fmt.Sprintf("The filter '%s' is invalid", name), nil)
+			}
+			if !matchAny(actual, filter.Values) {
+				match = false
+				break
+			}
+		}
+		if match {
+			add(i)
+		}
+	}
+
+	for want, found := range wanted {
+		if !found {
+			return awserr.New(notFoundCode, fmt.Sprintf("The ID '%s' does not exist", want), nil)
+		}
+	}
+	return nil
+}
+
+// matchAny reports whether any actual value matches any filter value; filter
+// values may use the * and ? wildcards
+func matchAny(actual []string, patterns []*string) bool {
+	for _, pattern := range patterns {
+		for _, value := range actual {
+			if wildcard(aws.StringValue(pattern)).MatchString(value) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// wildcard compiles an EC2 filter value, where * matches any characters and ? a single one
+func wildcard(pattern string) *regexp.Regexp {
+	quoted := regexp.QuoteMeta(pattern)
+	quoted = strings.NewReplacer(`\*`, ".*", `\?`, ".").Replace(quoted)
+	return regexp.MustCompile("^" + quoted + "$")
+}
+
+// tagFilter resolves the tag:<key> and tag-key filters
+func tagFilter(tags []*ec2.Tag, name string) ([]string, bool) {
+	var values []string
+	switch {
+	case name == "tag-key":
+		for _, tag := range tags {
+			values = append(values, aws.StringValue(tag.Key))
+		}
+	case strings.HasPrefix(name, "tag:"):
+		for _, tag := range tags {
+			if aws.StringValue(tag.Key) == name[4:] {
+				values = append(values, aws.StringValue(tag.Value))
+			}
+		}
+	case name == "tag-value":
+		for _, tag := range tags {
+			values = append(values, aws.StringValue(tag.Value))
+		}
+	default:
+		return nil, false
+	}
+	return values, true
+}
+
+func vpcFilter(vpc *ec2.Vpc, name string) ([]string, bool) {
+	switch name {
+	case "vpc-id":
+		return []string{aws.StringValue(vpc.VpcId)}, true
+	case "cidr", "cidr-block-association.cidr-block":
+		return []string{aws.StringValue(vpc.CidrBlock)}, true
+	case "is-default":
+		return []string{strconv.FormatBool(aws.BoolValue(vpc.IsDefault))}, true
+	case "state":
+		return []string{aws.StringValue(vpc.State)}, true
+	}
+	return tagFilter(vpc.Tags, name)
+}
+
+func subnetFilter(subnet *ec2.Subnet, name string) ([]string, bool) {
+	switch name {
+	case "subnet-id":
+		return []string{aws.StringValue(subnet.SubnetId)}, true
+	case "vpc-id":
+		return []string{aws.StringValue(subnet.VpcId)}, true
+	case "cidr-block", "cidr":
+		return []string{aws.StringValue(subnet.CidrBlock)}, true
+	case "availability-zone":
+		return []string{aws.StringValue(subnet.AvailabilityZone)}, true
+	case "default-for-az":
+		return []string{strconv.FormatBool(aws.BoolValue(subnet.DefaultForAz))}, true
+	case "map-public-ip-on-launch":
+		return []string{strconv.FormatBool(aws.BoolValue(subnet.MapPublicIpOnLaunch))}, true
+	case "state":
+		return []string{aws.StringValue(subnet.State)}, true
+	}
+	return tagFilter(subnet.Tags, name)
+}
+
+func routeTableFilter(rt *ec2.RouteTable, name string) ([]string, bool) {
+	var values []string
+	switch name {
+	case "route-table-id":
+		return []string{aws.StringValue(rt.RouteTableId)}, true
+	case "vpc-id":
+		return []string{aws.StringValue(rt.VpcId)}, true
+	case "association.subnet-id", "association.route-table-association-id", "association.main":
+		for _, assoc := range rt.Associations {
+			switch name {
+			case "association.subnet-id":
+				if assoc.SubnetId != nil {
+					values = append(values, aws.StringValue(assoc.SubnetId))
+				}
+			case "association.route-table-association-id":
+				values = append(values, aws.StringValue(assoc.RouteTableAssociationId))
+			default:
+				values = append(values, strconv.FormatBool(aws.BoolValue(assoc.Main)))
+			}
+		}
+		return values, true
+	case "route.destination-cidr-block", "route.gateway-id", "route.nat-gateway-id", "route.state":
+		for _, route := range rt.Routes {
+			var value *string
+			switch name {
+			case "route.destination-cidr-block":
+				value = route.DestinationCidrBlock
+			case "route.gateway-id":
+				value = route.GatewayId
+			case "route.nat-gateway-id":
+				value = route.NatGatewayId
+			default:
+				value = route.State
+			}
+			if value != nil {
+				values = append(values, *value)
+			}
+		}
+		return values, true
+	}
+	return tagFilter(rt.Tags, name)
+}
+
+func networkAclFilter(acl *ec2.NetworkAcl, name string) ([]string, bool) {
+	var values []string
+	switch name {
+	case "network-acl-id":
+		return []string{aws.StringValue(acl.NetworkAclId)}, true
+	case "vpc-id":
+		return []string{aws.StringValue(acl.VpcId)}, true
+	case "default":
+		return []string{strconv.FormatBool(aws.BoolValue(acl.IsDefault))}, true
+	case "association.subnet-id":
+		for _, assoc := range acl.Associations {
+			values = append(values, aws.StringValue(assoc.SubnetId))
+		}
+		return values, true
+	case "association.network-acl-id":
+		for _, assoc := range acl.Associations {
+			values = append(values, aws.StringValue(assoc.NetworkAclId))
+		}
+		return values, true
+	}
+	return tagFilter(acl.Tags, name)
+}
+
+func securityGroupFilter(sg *ec2.SecurityGroup, name string) ([]string, bool) {
+	switch name {
+	case "group-id":
+		return []string{aws.StringValue(sg.GroupId)}, true
+	case "group-name":
+		return []string{aws.StringValue(sg.GroupName)}, true
+	case "vpc-id":
+		return []string{aws.StringValue(sg.VpcId)}, true
+	case "description":
+		return []string{aws.StringValue(sg.Description)}, true
+	}
+	return tagFilter(sg.Tags, name)
+}
@@ -0,0 +1,234 @@
+package fakeaws
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+
+	"qa-test-app/internal/terraform"
+
+	ctyjson "github.com/zclconf/go-cty/cty/json"
+	"gopkg.in/yaml.v3"
+)
+
+// Fixture is a hand-written stand-in for a workspace: outputs, and resources
+// by type with the attributes terraform would record in the state, e.g.
+//
+//	outputs:
+//	  vpc_id: vpc-1
+//	resources:
+//	  aws_vpc:
+//	    - id: vpc-1
+//	      cidr_block: 10.0.0.0/16
+type Fixture struct {
+	Outputs   map[string]interface{}              `yaml:"outputs"`
+	Resources map[string][]map[string]interface{} `yaml:"resources"`
+}
+
+// LoadState reads a workspace to fake: a `terraform show -json` file when
+// the name ends in .json, a Fixture otherwise
+func LoadState(path string) (*terraform.State, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	if filepath.Ext(path) == ".json" {
+		state, err := terraform.ParseState(data)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", path, err)
+		}
+		return state, nil
+	}
+
+	var fixture Fixture
+	decoder := yaml.NewDecoder(bytes.NewReader(data))
+	decoder.KnownFields(true)
+	if err := decoder.Decode(&fixture); err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	state, err := fixture.State()
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return state, nil
+}
+
+// State converts the fixture into the state terraform would have recorded.
+// Resources are addressed as <type>.fixture[<index>].
+func (f *Fixture) State() (*terraform.State, error) {
+	outputs := make(map[string]interface{}, len(f.Outputs))
+	for name, value := range f.Outputs {
+		output, err := fixtureOutput(value)
+		if err != nil {
+			return nil, fmt.Errorf("output %s: %w", name, err)
+		}
+		outputs[name] = output
+	}
+
+	types := make([]string, 0, len(f.Resources))
+	for resourceType := range f.Resources {
+		types = append(types, resourceType)
+	}
+	sort.Strings(types)
+
+	resources := []interface{}{}
+	for _, resourceType := range types {
+		for i, values := range f.Resources[resourceType] {
+			if _, ok := values["id"].(string); !ok {
+				return nil, fmt.Errorf("%s.fixture[%d]: id is required", resourceType, i)
+			}
+			resources = append(resources, map[string]interface{}{
+				"address": fmt.Sprintf("%s.fixture[%d]", resourceType, i),
+				"mode":    "managed",
+				"type":    resourceType,
+				"name":    "fixture",
+				"index":   i,
+				"values":  values,
+			})
+		}
+	}
+
+	// Round-trip through JSON so values have the types terraform show -json produces
+	data, err := json.Marshal(map[string]interface{}{
+		"format_version": "1.0",
+		"values": map[string]interface{}{
+			"outputs":     outputs,
+			"root_module": map[string]interface{}{"resources": resources},
+		},
+	})
+	if err != nil {
+		return nil, err
+	}
+	return terraform.ParseState(data)
+}
+
+// fixtureOutput returns an output value with the type terraform would infer for it
+func fixtureOutput(value interface{}) (map[string]interface{}, error) {
+	data, err := json.Marshal(value)
+	if err != nil {
+		return nil, err
+	}
+	ty, err := ctyjson.ImpliedType(data)
+	if err != nil {
+		return nil, err
+	}
+	typeJSON, err := ctyjson.MarshalType(ty)
+	if err != nil {
+		return nil, err
+	}
+	return map[string]interface{}{
+		"value":     json.RawMessage(data),
+		"type":      json.RawMessage(typeJSON),
+		"sensitive": false,
+	}, nil
+}
+
+// FixtureFromTfvars lays out what terraform/base creates for a set of tfvars:
+// a VPC with an internet gateway, public subnets sharing a route table with a
+// default route to the gateway, and private subnets with a route table each
+func FixtureFromTfvars(tfvars map[string]interface{}) *Fixture {
+	vpcID := "vpc-mock123"
+	igwID := "igw-mock123"
+	environment := stringValue(tfvars["environment"])
+	zones := list(tfvars["availability_zones"])
+
+	commonTags, _ := tfvars["common_tags"].(map[string]interface{})
+	withTags := func(extra map[string]interface{}) map[string]interface{} {
+		tags := make(map[string]interface{}, len(commonTags)+len(extra))
+		for key, value := range commonTags {
+			tags[key] = value
+		}
+		for key, value := range extra {
+			tags[key] = value
+		}
+		return tags
+	}
+
+	f := &Fixture{
+		Outputs: map[string]interface{}{
+			"vpc_id":              vpcID,
+			"vpc_cidr_block":      stringValue(tfvars["vpc_cidr"]),
+			"internet_gateway_id": igwID,
+		},
+		Resources: map[string][]map[string]interface{}{
+			"aws_vpc": {{
+				"id":         vpcID,
+				"cidr_block": stringValue(tfvars["vpc_cidr"]),
+				"tags":       withTags(map[string]interface{}{"Name": environment + "-vpc"}),
+			}},
+			"aws_internet_gateway": {{
+				"id":     igwID,
+				"vpc_id": vpcID,
+				"tags":   withTags(map[string]interface{}{"Name": environment + "-igw"}),
+			}},
+			"aws_route_table": {{
+				"id":     "rtb-public",
+				"vpc_id": vpcID,
+				"route":  []interface{}{map[string]interface{}{"cidr_block": "0.0.0.0/0", "gateway_id": igwID}},
+				"tags":   withTags(map[string]interface{}{"Name": environment + "-public-rt"}),
+			}},
+		},
+	}
+
+	var subnetIDs = map[string][]interface{}{}
+	for _, kind := range []string{"public", "private"} {
+		for i, cidr := range list(tfvars[kind+"_subnets"]) {
+			subnetID := fmt.Sprintf("subnet-%s-%d", kind, i+1)
+			subnetIDs[kind] = append(subnetIDs[kind], subnetID)
+
+			zone := ""
+			if i < len(zones) {
+				zone = stringValue(zones[i])
+			}
+			f.Resources["aws_subnet"] = append(f.Resources["aws_subnet"], map[string]interface{}{
+				"id":                      subnetID,
+				"vpc_id":                  vpcID,
+				"cidr_block":              stringValue(cidr),
+				"availability_zone":       zone,
+				"map_public_ip_on_launch": kind == "public",
+				"tags": withTags(map[string]interface{}{
+					"Name": fmt.Sprintf("%s-%s-%d", environment, kind, i+1),
+					"Type": kind,
+				}),
+			})
+
+			routeTableID := "rtb-public"
+			if kind == "private" {
+				routeTableID = fmt.Sprintf("rtb-private-%d", i+1)
+				f.Resources["aws_route_table"] = append(f.Resources["aws_route_table"], map[string]interface{}{
+					"id":     routeTableID,
+					"vpc_id": vpcID,
+					"route":  []interface{}{},
+					"tags":   withTags(map[string]interface{}{"Name": fmt.Sprintf("%s-private-rt-%d", environment, i+1)}),
+				})
+			}
+			f.Resources["aws_route_table_association"] = append(f.Resources["aws_route_table_association"], map[string]interface{}{
+				"id":             fmt.Sprintf("rtbassoc-%s-%d", kind, i+1),
+				"subnet_id":      subnetID,
+				"route_table_id": routeTableID,
+			})
+		}
+	}
+	f.Outputs["public_subnet_ids"] = append([]interface{}{}, subnetIDs["public"]...)
+	f.Outputs["private_subnet_ids"] = append([]interface{}{}, subnetIDs["private"]...)
+	return f
+}
+
+func list(value interface{}) []interface{} {
+	items, _ := value.([]interface{})
+	return items
+}
+
+func stringValue(value interface{}) string {
+	switch v := value.(type) {
+	case string:
+		return v
+	case nil:
+		return ""
+	default:
+		return fmt.Sprint(v)
+	}
+}
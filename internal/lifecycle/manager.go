@@ -0,0 +1,271 @@
+// Package lifecycle guarantees that test workspaces are torn down, even when
+// a run is interrupted or fails part way through.
+package lifecycle
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"qa-test-app/internal/terraform"
+	"sync"
+	"syscall"
+	"time"
+)
+
+// DefaultRecoveryDir holds recovery records, relative to the directory qa-test-app is started from
+const DefaultRecoveryDir = ".qa/recovery"
+
+// DefaultCleanupTimeout bounds a teardown started after the run was cancelled
+const DefaultCleanupTimeout = 30 * time.Minute
+
+// ForceCleanupTimeout bounds the forced workspace deletion after a failed
+// teardown, which gets its own deadline in case the teardown used up CleanupTimeout
+const ForceCleanupTimeout = 2 * time.Minute
+
+// Record is written while a test workspace exists so a later invocation can
+// finish tearing it down if this one dies before it does
+type Record struct {
+	Workspace  string    `json:"workspace"`
+	TestName   string    `json:"test_name"`
+	WorkingDir string    `json:"working_dir"`
+	TfvarsFile string    `json:"tfvars_file"`
+	RunsDir    string    `json:"runs_dir"`
+	PID        int       `json:"pid"`
+	Host       string    `json:"host"`
+	StartedAt  time.Time `json:"started_at"`
+	// Endpoint lets teardown reach the emulator the workspace was applied to
+	Endpoint string `json:"endpoint,omitempty"`
+}
+
+// Manager traps termination signals and owns teardown of the workspaces it tracks
+type Manager struct {
+	RecoveryDir    string
+	CleanupTimeout time.Duration
+
+	mu      sync.Mutex
+	current string
+}
+
+// NewManager creates a manager that keeps recovery records in dir
+func NewManager(dir string) *Manager {
+	return &Manager{
+		RecoveryDir:    dir,
+		CleanupTimeout: DefaultCleanupTimeout,
+	}
+}
+
+// Start traps SIGINT and SIGTERM. The returned context is cancelled on the
+// first signal, which interrupts the running terraform command; later signals
+// are reported but do not stop the teardown that follows. Call stop to
+// restore default signal handling.
+func (m *Manager) Start(parent context.Context) (context.Context, func()) {
+	ctx, cancel := context.WithCancelCause(parent)
+	sigs := make(chan os.Signal, 1)
+	signal.Notify(sigs, os.Interrupt, syscall.SIGTERM)
+
+	done := make(chan struct{})
+	go func() {
+		interrupted := false
+		for {
+			select {
+			case sig := <-sigs:
+				if !interrupted {
+					interrupted = true
+					log.Printf("Received %s, stopping terraform and tearing down", sig)
+					cancel(fmt.Errorf("received %s", sig))
+					continue
+				}
+				if ws := m.currentWorkspace(); ws != "" {
+					log.Printf("Received %s again, still tearing down workspace %s", sig, ws)
+				}
+			case <-done:
+				return
+			}
+		}
+	}()
+
+	var once sync.Once
+	stop := func() {
+		once.Do(func() {
+			signal.Stop(sigs)
+			close(done)
+			cancel(nil)
+		})
+	}
+	return ctx, stop
+}
+
+// Track writes a recovery record for the executor's current workspace
+func (m *Manager) Track(executor *terraform.Executor) error {
+	host, _ := os.Hostname()
+	rec := Record{
+		Workspace:  executor.CurrentWorkspace,
+		TestName:   executor.TestName,
+		WorkingDir: executor.WorkingDir,
+		TfvarsFile: executor.TfvarsFile,
+		RunsDir:    executor.RunsDir,
+		PID:        os.Getpid(),
+		Host:       host,
+		StartedAt:  time.Now().UTC(),
+		Endpoint:   executor.Endpoint,
+	}
+	if err := m.writeRecord(rec); err != nil {
+		return fmt.Errorf("failed to write recovery record: %w", err)
+	}
+
+	m.mu.Lock()
+	m.current = rec.Workspace
+	m.mu.Unlock()
+	return nil
+}
+
+// Release drops the recovery record of a workspace that no longer needs teardown
+func (m *Manager) Release(workspace string) {
+	os.Remove(m.recordPath(workspace))
+
+	m.mu.Lock()
+	if m.current == workspace {
+		m.current = ""
+	}
+	m.mu.Unlock()
+}
+
+// Teardown destroys the executor's workspace, falling back to ForceCleanup.
+// It runs even if ctx was cancelled, bounded by CleanupTimeout. The recovery
+// record is only released once the workspace is gone, so a workspace that
+// couldn't be deleted is retried by the next run. A workspace whose destroy
+// failed is never force-deleted while its state still lists resources, since
+// that state is the only way to destroy them later.
+func (m *Manager) Teardown(ctx context.Context, executor *terraform.Executor) error {
+	workspace := executor.CurrentWorkspace
+	if workspace == "" {
+		return nil
+	}
+
+	cleanupCtx, cancel := context.WithTimeout(context.WithoutCancel(ctx), m.CleanupTimeout)
+	defer cancel()
+
+	err := executor.CleanupTestEnvironment(cleanupCtx)
+	if err != nil {
+		if errors.Is(err, terraform.ErrDestroyFailed) {
+			if hasResources, stateErr := executor.HasResources(cleanupCtx); stateErr != nil || hasResources {
+				log.Printf("Destroy of %s failed with resources left in its state, keeping the workspace and its recovery record: %v", workspace, err)
+				return err
+			}
+		}
+		log.Printf("Cleanup of %s failed, forcing workspace deletion: %v", workspace, err)
+		forceCtx, cancelForce := context.WithTimeout(context.WithoutCancel(ctx), ForceCleanupTimeout)
+		defer cancelForce()
+		if forceErr := executor.ForceCleanup(forceCtx); forceErr != nil {
+			log.Printf("Forced deletion of %s failed, keeping its recovery record: %v", workspace, forceErr)
+			return errors.Join(err, forceErr)
+		}
+	}
+	m.Release(workspace)
+	return err
+}
+
+// Recover tears down workspaces left behind by earlier runs that died before
+// finishing their own teardown. Records owned by a process that is still
+// running, or by another host, are left alone.
+func (m *Manager) Recover(ctx context.Context, newExecutor func(Record) *terraform.Executor) error {
+	records, err := m.records()
+	if err != nil {
+		return err
+	}
+
+	host, _ := os.Hostname()
+	var errs []error
+	for _, rec := range records {
+		if rec.Host != host || (rec.PID != os.Getpid() && processAlive(rec.PID)) {
+			continue
+		}
+
+		log.Printf("Recovering interrupted run: tearing down workspace %s (%s)", rec.Workspace, rec.TestName)
+		if err := m.recover(ctx, rec, newExecutor(rec)); err != nil {
+			errs = append(errs, fmt.Errorf("workspace %s: %w", rec.Workspace, err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+func (m *Manager) recover(ctx context.Context, rec Record, executor *terraform.Executor) error {
+	workspaces, err := executor.WorkspaceList(ctx)
+	if err != nil {
+		return err
+	}
+
+	exists := false
+	for _, ws := range workspaces {
+		if ws == rec.Workspace {
+			exists = true
+			break
+		}
+	}
+	if !exists {
+		// Teardown got as far as deleting the workspace
+		m.Release(rec.Workspace)
+		return nil
+	}
+
+	if _, err := executor.SelectWorkspace(ctx, rec.Workspace); err != nil {
+		return err
+	}
+	executor.TestName = rec.TestName
+	return m.Teardown(ctx, executor)
+}
+
+func (m *Manager) currentWorkspace() string {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.current
+}
+
+func (m *Manager) recordPath(workspace string) string {
+	return filepath.Join(m.RecoveryDir, workspace+".json")
+}
+
+func (m *Manager) writeRecord(rec Record) error {
+	if err := os.MkdirAll(m.RecoveryDir, 0755); err != nil {
+		return err
+	}
+	data, err := json.MarshalIndent(rec, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	// Write then rename so a crash never leaves a truncated record behind
+	path := m.recordPath(rec.Workspace)
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, path)
+}
+
+func (m *Manager) records() ([]Record, error) {
+	paths, err := filepath.Glob(filepath.Join(m.RecoveryDir, "*.json"))
+	if err != nil {
+		return nil, err
+	}
+
+	var records []Record
+	for _, path := range paths {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return nil, err
+		}
+		var rec Record
+		if err := json.Unmarshal(data, &rec); err != nil {
+			log.Printf("Ignoring unreadable recovery record %s: %v", path, err)
+			continue
+		}
+		records = append(records, rec)
+	}
+	return records, nil
+}
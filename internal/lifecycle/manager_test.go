@@ -0,0 +1,182 @@
+package lifecycle
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+
+	"qa-test-app/internal/terraform"
+)
+
+const (
+	emptyState = `{"format_version": "1.0"}`
+	vpcState   = `{"format_version": "1.0", "values": {"root_module": {"resources": [
+		{"address": "aws_vpc.main", "mode": "managed", "type": "aws_vpc", "name": "main", "values": {"id": "vpc-1"}}
+	]}}}`
+)
+
+// fakeTerraform puts testdata/terraform first on PATH with state as the
+// workspace's state, and returns the file the commands it runs are logged to
+func fakeTerraform(t *testing.T, state string, env ...string) string {
+	t.Helper()
+	if runtime.GOOS == "windows" {
+		t.Skip("the fake terraform is a shell script")
+	}
+	testdata, err := filepath.Abs("testdata")
+	if err != nil {
+		t.Fatal(err)
+	}
+	dir := t.TempDir()
+	statePath := filepath.Join(dir, "state.json")
+	if err := os.WriteFile(statePath, []byte(state), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	logPath := filepath.Join(dir, "commands.log")
+
+	t.Setenv("PATH", testdata+string(os.PathListSeparator)+os.Getenv("PATH"))
+	t.Setenv("FAKE_TF_STATE", statePath)
+	t.Setenv("FAKE_TF_LOG", logPath)
+	t.Setenv("FAKE_TF_FAIL_DESTROY", "")
+	t.Setenv("FAKE_TF_FAIL_DELETE", "")
+	for _, kv := range env {
+		key, value, _ := strings.Cut(kv, "=")
+		t.Setenv(key, value)
+	}
+	return logPath
+}
+
+// trackedExecutor returns a manager and an executor whose workspace it tracks
+func trackedExecutor(t *testing.T) (*Manager, *terraform.Executor) {
+	t.Helper()
+	executor := terraform.NewExecutor(t.TempDir(), "terraform.tfvars")
+	executor.RunsDir = t.TempDir()
+	executor.TestName = "VPC"
+	executor.CurrentWorkspace = "test-vpc-1760659200"
+
+	m := NewManager(t.TempDir())
+	if err := m.Track(executor); err != nil {
+		t.Fatalf("Track: %v", err)
+	}
+	return m, executor
+}
+
+func TestTeardown(t *testing.T) {
+	for _, tc := range []struct {
+		name        string
+		state       string
+		env         []string
+		wantErr     string
+		wantRecord  bool
+		wantDeleted string
+	}{
+		{
+			name:        "destroyed",
+			state:       emptyState,
+			wantDeleted: "workspace delete test-vpc-1760659200",
+		},
+		{
+			name:        "workspace delete fails",
+			state:       emptyState,
+			env:         []string{"FAKE_TF_FAIL_DELETE=1"},
+			wantErr:     "failed to delete workspace",
+			wantDeleted: "workspace delete -force test-vpc-1760659200",
+		},
+		{
+			name:        "destroy fails with an empty state",
+			state:       emptyState,
+			env:         []string{"FAKE_TF_FAIL_DESTROY=1"},
+			wantErr:     "destroy failed",
+			wantDeleted: "workspace delete -force test-vpc-1760659200",
+		},
+		{
+			// Force-deleting the workspace would orphan the VPC
+			name:       "destroy fails with resources left",
+			state:      vpcState,
+			env:        []string{"FAKE_TF_FAIL_DESTROY=1"},
+			wantErr:    "destroy failed",
+			wantRecord: true,
+		},
+		{
+			name:       "destroy fails and the state can't be read",
+			state:      "not json",
+			env:        []string{"FAKE_TF_FAIL_DESTROY=1"},
+			wantErr:    "destroy failed",
+			wantRecord: true,
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			logPath := fakeTerraform(t, tc.state, tc.env...)
+			m, executor := trackedExecutor(t)
+			workspace := executor.CurrentWorkspace
+
+			err := m.Teardown(context.Background(), executor)
+			if tc.wantErr == "" && err != nil {
+				t.Errorf("Teardown: %v", err)
+			} else if tc.wantErr != "" && (err == nil || !strings.Contains(err.Error(), tc.wantErr)) {
+				t.Errorf("Teardown error = %v, want %q", err, tc.wantErr)
+			}
+
+			_, statErr := os.Stat(m.recordPath(workspace))
+			if hasRecord := statErr == nil; hasRecord != tc.wantRecord {
+				t.Errorf("recovery record kept = %v, want %v", hasRecord, tc.wantRecord)
+			}
+
+			commands, err := os.ReadFile(logPath)
+			if err != nil {
+				t.Fatal(err)
+			}
+			var deleted []string
+			for _, line := range strings.Split(string(commands), "\n") {
+				if strings.HasPrefix(line, "workspace delete") {
+					deleted = append(deleted, line)
+				}
+			}
+			if tc.wantDeleted == "" {
+				if len(deleted) > 0 {
+					t.Errorf("workspace deleted with resources in its state: %q", deleted)
+				}
+				if executor.CurrentWorkspace != workspace {
+					t.Errorf("CurrentWorkspace = %q, want %q", executor.CurrentWorkspace, workspace)
+				}
+			} else if len(deleted) == 0 || deleted[len(deleted)-1] != tc.wantDeleted {
+				t.Errorf("workspace deletes = %q, want the last to be %q", deleted, tc.wantDeleted)
+			} else if executor.CurrentWorkspace != "" {
+				t.Errorf("CurrentWorkspace = %q after deleting it", executor.CurrentWorkspace)
+			}
+		})
+	}
+}
+
+func TestRecoverRetriesFailedDestroy(t *testing.T) {
+	fakeTerraform(t, vpcState, "FAKE_TF_FAIL_DESTROY=1", "FAKE_TF_WORKSPACES=test-vpc-1760659200")
+	m, executor := trackedExecutor(t)
+	workspace := executor.CurrentWorkspace
+	newExecutor := func(rec Record) *terraform.Executor {
+		e := terraform.NewExecutor(rec.WorkingDir, rec.TfvarsFile)
+		e.RunsDir = rec.RunsDir
+		return e
+	}
+
+	if err := m.Teardown(context.Background(), executor); !errors.Is(err, terraform.ErrDestroyFailed) {
+		t.Fatalf("Teardown error = %v, want ErrDestroyFailed", err)
+	}
+	if err := m.Recover(context.Background(), newExecutor); !errors.Is(err, terraform.ErrDestroyFailed) {
+		t.Fatalf("Recover error = %v, want ErrDestroyFailed", err)
+	}
+	if _, err := os.Stat(m.recordPath(workspace)); err != nil {
+		t.Fatalf("recovery record gone while destroy keeps failing: %v", err)
+	}
+
+	// Once destroy goes through, the next run finishes the teardown
+	t.Setenv("FAKE_TF_FAIL_DESTROY", "")
+	if err := m.Recover(context.Background(), newExecutor); err != nil {
+		t.Fatalf("Recover: %v", err)
+	}
+	if _, err := os.Stat(m.recordPath(workspace)); !os.IsNotExist(err) {
+		t.Errorf("recovery record kept after a successful destroy: %v", err)
+	}
+}
@@ -0,0 +1,205 @@
+// Package network models how a VPC routes and filters traffic, from what the
+// EC2 API reports about its subnets, route tables, network ACLs and security
+// groups, so test functions can check which traffic can actually flow
+package network
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"sort"
+	"strings"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/ec2"
+	"github.com/aws/aws-sdk-go/service/ec2/ec2iface"
+)
+
+// Model is a snapshot of one VPC
+type Model struct {
+	VpcID          string
+	Subnets        []*ec2.Subnet
+	RouteTables    []*ec2.RouteTable
+	NetworkAcls    []*ec2.NetworkAcl
+	SecurityGroups []*ec2.SecurityGroup
+}
+
+// Load describes the subnets, route tables, network ACLs and security groups of a VPC
+func Load(ctx context.Context, svc ec2iface.EC2API, vpcID string) (*Model, error) {
+	filters := []*ec2.Filter{{Name: aws.String("vpc-id"), Values: []*string{aws.String(vpcID)}}}
+	m := &Model{VpcID: vpcID}
+
+	var token *string
+	for {
+		out, err := svc.DescribeSubnetsWithContext(ctx, &ec2.DescribeSubnetsInput{Filters: filters, NextToken: token})
+		if err != nil {
+			return nil, fmt.Errorf("failed to describe subnets: %w", err)
+		}
+		m.Subnets = append(m.Subnets, out.Subnets...)
+		if token = out.NextToken; token == nil {
+			break
+		}
+	}
+	for {
+		out, err := svc.DescribeRouteTablesWithContext(ctx, &ec2.DescribeRouteTablesInput{Filters: filters, NextToken: token})
+		if err != nil {
+			return nil, fmt.Errorf("failed to describe route tables: %w", err)
+		}
+		m.RouteTables = append(m.RouteTables, out.RouteTables...)
+		if token = out.NextToken; token == nil {
+			break
+		}
+	}
+	for {
+		out, err := svc.DescribeNetworkAclsWithContext(ctx, &ec2.DescribeNetworkAclsInput{Filters: filters, NextToken: token})
+		if err != nil {
+			return nil, fmt.Errorf("failed to describe network ACLs: %w", err)
+		}
+		m.NetworkAcls = append(m.NetworkAcls, out.NetworkAcls...)
+		if token = out.NextToken; token == nil {
+			break
+		}
+	}
+	for {
+		out, err := svc.DescribeSecurityGroupsWithContext(ctx, &ec2.DescribeSecurityGroupsInput{Filters: filters, NextToken: token})
+		if err != nil {
+			return nil, fmt.Errorf("failed to describe security groups: %w", err)
+		}
+		m.SecurityGroups = append(m.SecurityGroups, out.SecurityGroups...)
+		if token = out.NextToken; token == nil {
+			break
+		}
+	}
+
+	sortSubnets(m.Subnets)
+	return m, nil
+}
+
+// sortSubnets orders subnets by ID so results don't depend on API order
+func sortSubnets(subnets []*ec2.Subnet) {
+	sort.Slice(subnets, func(i, j int) bool {
+		return aws.StringValue(subnets[i].SubnetId) < aws.StringValue(subnets[j].SubnetId)
+	})
+}
+
+// Subnet returns a subnet of the VPC by ID
+func (m *Model) Subnet(id string) (*ec2.Subnet, bool) {
+	for _, subnet := range m.Subnets {
+		if aws.StringValue(subnet.SubnetId) == id {
+			return subnet, true
+		}
+	}
+	return nil, false
+}
+
+// MainRouteTable returns the route table subnets without an explicit association use, or nil
+func (m *Model) MainRouteTable() *ec2.RouteTable {
+	for _, rt := range m.RouteTables {
+		for _, assoc := range rt.Associations {
+			if aws.BoolValue(assoc.Main) {
+				return rt
+			}
+		}
+	}
+	return nil
+}
+
+// RouteTable returns the route table of a subnet and whether the subnet is
+// explicitly associated with it; unassociated subnets fall back to the main
+// table, and rt is nil when the VPC has none
+func (m *Model) RouteTable(subnetID string) (rt *ec2.RouteTable, explicit bool) {
+	for _, rt := range m.RouteTables {
+		for _, assoc := range rt.Associations {
+			if aws.StringValue(assoc.SubnetId) == subnetID {
+				return rt, true
+			}
+		}
+	}
+	return m.MainRouteTable(), false
+}
+
+// NetworkAcl returns the network ACL associated with a subnet, or nil
+func (m *Model) NetworkAcl(subnetID string) *ec2.NetworkAcl {
+	for _, acl := range m.NetworkAcls {
+		for _, assoc := range acl.Associations {
+			if aws.StringValue(assoc.SubnetId) == subnetID {
+				return acl
+			}
+		}
+	}
+	return nil
+}
+
+// SecurityGroup returns a security group of the VPC by ID
+func (m *Model) SecurityGroup(id string) (*ec2.SecurityGroup, bool) {
+	for _, sg := range m.SecurityGroups {
+		if aws.StringValue(sg.GroupId) == id {
+			return sg, true
+		}
+	}
+	return nil, false
+}
+
+// DefaultSecurityGroup returns the security group EC2 created with the VPC, or nil
+func (m *Model) DefaultSecurityGroup() *ec2.SecurityGroup {
+	for _, sg := range m.SecurityGroups {
+		if aws.StringValue(sg.GroupName) == "default" {
+			return sg
+		}
+	}
+	return nil
+}
+
+// LookupRoute returns the most specific IPv4 route of a route table whose
+// destination contains all of dest, or nil
+func LookupRoute(rt *ec2.RouteTable, dest *net.IPNet) *ec2.Route {
+	var best *ec2.Route
+	bestOnes := -1
+	for _, route := range rt.Routes {
+		_, network, err := net.ParseCIDR(aws.StringValue(route.DestinationCidrBlock))
+		if err != nil || !Contains(network, dest) {
+			continue
+		}
+		if ones, _ := network.Mask.Size(); ones > bestOnes {
+			best, bestOnes = route, ones
+		}
+	}
+	return best
+}
+
+// RouteTarget returns the ID a route sends traffic to, "local" for the VPC itself
+func RouteTarget(route *ec2.Route) string {
+	for _, target := range []*string{
+		route.GatewayId,
+		route.NatGatewayId,
+		route.TransitGatewayId,
+		route.VpcPeeringConnectionId,
+		route.NetworkInterfaceId,
+		route.InstanceId,
+		route.EgressOnlyInternetGatewayId,
+		route.LocalGatewayId,
+		route.CarrierGatewayId,
+	} {
+		if s := aws.StringValue(target); s != "" {
+			return s
+		}
+	}
+	return ""
+}
+
+// IsInternetGateway reports whether a route target is an internet gateway
+func IsInternetGateway(target string) bool {
+	return strings.HasPrefix(target, "igw-")
+}
+
+// IsBlackhole reports whether a route's target no longer exists
+func IsBlackhole(route *ec2.Route) bool {
+	return aws.StringValue(route.State) == ec2.RouteStateBlackhole
+}
+
+// Contains reports whether inner lies entirely inside outer
+func Contains(outer, inner *net.IPNet) bool {
+	outerOnes, outerBits := outer.Mask.Size()
+	innerOnes, innerBits := inner.Mask.Size()
+	return outerBits == innerBits && innerOnes >= outerOnes && outer.Contains(inner.IP)
+}
@@ -0,0 +1,281 @@
+package network
+
+import (
+	"fmt"
+	"net"
+	"sort"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/ec2"
+)
+
+// Internet is the destination of traffic leaving the VPC
+var Internet = &net.IPNet{IP: net.IPv4zero.To4(), Mask: net.CIDRMask(0, 32)}
+
+// EphemeralPort is the client port return traffic is checked against, the
+// start of the Linux ephemeral range
+const EphemeralPort = 32768
+
+// Protocols maps the protocols a Flow can use to their IANA numbers
+var Protocols = map[string]string{"icmp": "1", "tcp": "6", "udp": "17"}
+
+// allTraffic is how network ACLs and security groups write "every protocol"
+const allTraffic = "-1"
+
+// Flow is the traffic whose reachability is checked: a protocol from
+// Protocols and a destination port, which ICMP ignores
+type Flow struct {
+	Protocol string
+	Port     int64
+}
+
+func (f Flow) String() string {
+	if f.Protocol == "icmp" {
+		return "icmp"
+	}
+	return fmt.Sprintf("%s/%d", f.Protocol, f.Port)
+}
+
+// Verdict is whether a flow gets through, with the decision at every hop
+type Verdict struct {
+	Reachable bool
+	// Rule is the rule that blocked the flow, or the last one that allowed it
+	Rule string
+	// Hops lists the decision at each hop in the order the traffic meets them
+	Hops []string
+}
+
+// hop is one decision on the way of a flow; allowed is false when it blocks
+type hop struct {
+	allowed bool
+	rule    string
+}
+
+// SubnetToSubnet evaluates a flow from instances in one subnet to instances
+// in another, both using the given security groups. The reply is checked
+// too: network ACLs are stateless, so the reverse route and rules for the
+// ephemeral port must allow it.
+func (m *Model) SubnetToSubnet(fromID, toID string, flow Flow, groups []*ec2.SecurityGroup) (Verdict, error) {
+	from, fromNet, err := m.subnetNet(fromID)
+	if err != nil {
+		return Verdict{}, err
+	}
+	to, toNet, err := m.subnetNet(toID)
+	if err != nil {
+		return Verdict{}, err
+	}
+	reply := Flow{Protocol: flow.Protocol, Port: EphemeralPort}
+
+	egressGroups := func() hop { return groupHop(groups, true, toNet, groups, flow) }
+	ingressGroups := func() hop { return groupHop(groups, false, fromNet, groups, flow) }
+	if fromID == toID {
+		// Traffic within a subnet is neither routed nor filtered by its network ACL
+		return evaluate([]func() hop{egressGroups, ingressGroups}), nil
+	}
+
+	return evaluate([]func() hop{
+		func() hop { return m.localRoute(from, toNet) },
+		func() hop { return m.aclHop(fromID, true, toNet, flow) },
+		egressGroups,
+		func() hop { return m.aclHop(toID, false, fromNet, flow) },
+		ingressGroups,
+		func() hop { return m.localRoute(to, fromNet) },
+		func() hop { return m.aclHop(toID, true, fromNet, reply) },
+		func() hop { return m.aclHop(fromID, false, toNet, reply) },
+	}), nil
+}
+
+// SubnetToInternet evaluates a flow from instances in a subnet, using the
+// given security groups, to an address outside the VPC, and its reply
+func (m *Model) SubnetToInternet(fromID string, flow Flow, groups []*ec2.SecurityGroup) (Verdict, error) {
+	from, _, err := m.subnetNet(fromID)
+	if err != nil {
+		return Verdict{}, err
+	}
+	reply := Flow{Protocol: flow.Protocol, Port: EphemeralPort}
+
+	return evaluate([]func() hop{
+		func() hop { return m.internetRoute(from) },
+		func() hop { return m.aclHop(fromID, true, Internet, flow) },
+		func() hop { return groupHop(groups, true, Internet, nil, flow) },
+		func() hop { return m.aclHop(fromID, false, Internet, reply) },
+	}), nil
+}
+
+// evaluate runs the hops in order and stops at the first that blocks
+func evaluate(steps []func() hop) Verdict {
+	var v Verdict
+	for _, step := range steps {
+		h := step()
+		v.Hops = append(v.Hops, h.rule)
+		v.Rule = h.rule
+		if !h.allowed {
+			return v
+		}
+	}
+	v.Reachable = true
+	return v
+}
+
+func (m *Model) subnetNet(id string) (*ec2.Subnet, *net.IPNet, error) {
+	subnet, ok := m.Subnet(id)
+	if !ok {
+		return nil, nil, fmt.Errorf("subnet %s not found in VPC %s", id, m.VpcID)
+	}
+	_, network, err := net.ParseCIDR(aws.StringValue(subnet.CidrBlock))
+	if err != nil {
+		return nil, nil, fmt.Errorf("subnet %s: %w", id, err)
+	}
+	return subnet, network, nil
+}
+
+// localRoute checks that a subnet's route table keeps traffic to dest inside the VPC
+func (m *Model) localRoute(subnet *ec2.Subnet, dest *net.IPNet) hop {
+	rt, route, blocked := m.route(subnet, dest)
+	if blocked != nil {
+		return *blocked
+	}
+	id := aws.StringValue(rt.RouteTableId)
+	target := RouteTarget(route)
+	if target != "local" {
+		return hop{false, fmt.Sprintf("route table %s: %s -> %s sends %s out of the VPC", id, aws.StringValue(route.DestinationCidrBlock), target, dest)}
+	}
+	return hop{true, fmt.Sprintf("route table %s: %s -> local", id, aws.StringValue(route.DestinationCidrBlock))}
+}
+
+// internetRoute checks that a subnet's route table sends traffic leaving the
+// VPC to an internet or NAT gateway
+func (m *Model) internetRoute(subnet *ec2.Subnet) hop {
+	rt, route, blocked := m.route(subnet, Internet)
+	if blocked != nil {
+		return *blocked
+	}
+	id := aws.StringValue(rt.RouteTableId)
+	target := RouteTarget(route)
+	rule := fmt.Sprintf("route table %s: %s -> %s", id, aws.StringValue(route.DestinationCidrBlock), target)
+	if !IsInternetGateway(target) && aws.StringValue(route.NatGatewayId) == "" {
+		return hop{false, rule + " is not an internet or NAT gateway"}
+	}
+	return hop{true, rule}
+}
+
+// route finds the route a subnet uses for dest, or the hop that blocks it
+// when there is no usable route
+func (m *Model) route(subnet *ec2.Subnet, dest *net.IPNet) (*ec2.RouteTable, *ec2.Route, *hop) {
+	subnetID := aws.StringValue(subnet.SubnetId)
+	rt, _ := m.RouteTable(subnetID)
+	if rt == nil {
+		return nil, nil, &hop{false, fmt.Sprintf("subnet %s has no route table", subnetID)}
+	}
+	id := aws.StringValue(rt.RouteTableId)
+	route := LookupRoute(rt, dest)
+	if route == nil {
+		return nil, nil, &hop{false, fmt.Sprintf("route table %s has no route to %s", id, dest)}
+	}
+	if IsBlackhole(route) {
+		return nil, nil, &hop{false, fmt.Sprintf("route table %s: %s -> %s is a blackhole", id, aws.StringValue(route.DestinationCidrBlock), RouteTarget(route))}
+	}
+	return rt, route, nil
+}
+
+// aclHop evaluates the network ACL of a subnet for traffic leaving it
+// (egress) to peer or entering it from peer. Rules are checked in number
+// order and the first that matches decides.
+func (m *Model) aclHop(subnetID string, egress bool, peer *net.IPNet, flow Flow) hop {
+	direction, preposition := "ingress", "from"
+	if egress {
+		direction, preposition = "egress", "to"
+	}
+	acl := m.NetworkAcl(subnetID)
+	if acl == nil {
+		return hop{false, fmt.Sprintf("subnet %s has no network ACL", subnetID)}
+	}
+	id := aws.StringValue(acl.NetworkAclId)
+
+	var entries []*ec2.NetworkAclEntry
+	for _, entry := range acl.Entries {
+		if aws.BoolValue(entry.Egress) == egress {
+			entries = append(entries, entry)
+		}
+	}
+	sort.Slice(entries, func(i, j int) bool {
+		return aws.Int64Value(entries[i].RuleNumber) < aws.Int64Value(entries[j].RuleNumber)
+	})
+
+	for _, entry := range entries {
+		_, network, err := net.ParseCIDR(aws.StringValue(entry.CidrBlock))
+		if err != nil || !Contains(network, peer) || !matchProtocol(aws.StringValue(entry.Protocol), flow) {
+			continue
+		}
+		if flow.Protocol != "icmp" && entry.PortRange != nil && !inRange(flow.Port, entry.PortRange.From, entry.PortRange.To) {
+			continue
+		}
+		action := aws.StringValue(entry.RuleAction)
+		return hop{action == ec2.RuleActionAllow, fmt.Sprintf("network ACL %s %s rule %d: %s %s %s %s",
+			id, direction, aws.Int64Value(entry.RuleNumber), action, flow, preposition, network)}
+	}
+	return hop{false, fmt.Sprintf("network ACL %s has no %s rule for %s %s %s", id, direction, flow, preposition, peer)}
+}
+
+// groupHop evaluates security groups for traffic leaving (egress) to peer or
+// entering from peer. Any rule of any group allowing it is enough; peerGroups
+// are the groups of the instances on the other end, matched by group rules.
+func groupHop(groups []*ec2.SecurityGroup, egress bool, peer *net.IPNet, peerGroups []*ec2.SecurityGroup, flow Flow) hop {
+	direction, preposition := "ingress", "from"
+	if egress {
+		direction, preposition = "egress", "to"
+	}
+	var ids []string
+	for _, sg := range groups {
+		id := aws.StringValue(sg.GroupId)
+		ids = append(ids, id)
+
+		permissions := sg.IpPermissions
+		if egress {
+			permissions = sg.IpPermissionsEgress
+		}
+		for _, permission := range permissions {
+			if !matchProtocol(aws.StringValue(permission.IpProtocol), flow) {
+				continue
+			}
+			if flow.Protocol != "icmp" && permission.FromPort != nil && !inRange(flow.Port, permission.FromPort, permission.ToPort) {
+				continue
+			}
+			if source := permissionSource(permission, peer, peerGroups); source != "" {
+				return hop{true, fmt.Sprintf("security group %s %s: allow %s %s %s", id, direction, flow, preposition, source)}
+			}
+		}
+	}
+	if len(ids) == 0 {
+		return hop{false, fmt.Sprintf("no security group to allow %s %s %s", flow, preposition, peer)}
+	}
+	return hop{false, fmt.Sprintf("security groups %v have no %s rule for %s %s %s", ids, direction, flow, preposition, peer)}
+}
+
+// permissionSource returns the CIDR or group of a permission that covers
+// peer, or "" if none does
+func permissionSource(permission *ec2.IpPermission, peer *net.IPNet, peerGroups []*ec2.SecurityGroup) string {
+	for _, r := range permission.IpRanges {
+		_, network, err := net.ParseCIDR(aws.StringValue(r.CidrIp))
+		if err == nil && Contains(network, peer) {
+			return network.String()
+		}
+	}
+	for _, pair := range permission.UserIdGroupPairs {
+		for _, sg := range peerGroups {
+			if aws.StringValue(pair.GroupId) == aws.StringValue(sg.GroupId) {
+				return "group " + aws.StringValue(sg.GroupId)
+			}
+		}
+	}
+	return ""
+}
+
+// matchProtocol reports whether a rule's protocol, a number, a name or -1, covers the flow
+func matchProtocol(protocol string, flow Flow) bool {
+	return protocol == allTraffic || protocol == "all" || protocol == flow.Protocol || protocol == Protocols[flow.Protocol]
+}
+
+func inRange(port int64, from, to *int64) bool {
+	return port >= aws.Int64Value(from) && port <= aws.Int64Value(to)
+}
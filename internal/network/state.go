@@ -0,0 +1,671 @@
+package network
+
+import (
+	"fmt"
+	"net"
+	"sort"
+	"strconv"
+
+	"qa-test-app/internal/terraform"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/ec2"
+)
+
+// Inventory is every network resource of a terraform state as EC2 would describe it
+type Inventory struct {
+	Vpcs           []*ec2.Vpc
+	Subnets        []*ec2.Subnet
+	RouteTables    []*ec2.RouteTable
+	NetworkAcls    []*ec2.NetworkAcl
+	SecurityGroups []*ec2.SecurityGroup
+}
+
+// FromState describes the network resources of a terraform state the way
+// EC2 would. What EC2 creates on its own is added too: the local route of
+// every route table, and the main route table, default network ACL and
+// default security group of a VPC when the state doesn't manage them.
+func FromState(state *terraform.State) (*Inventory, error) {
+	b := &builder{
+		inv:           &Inventory{},
+		vpcs:          make(map[string]*ec2.Vpc),
+		routeTables:   make(map[string]*ec2.RouteTable),
+		networkAcls:   make(map[string]*ec2.NetworkAcl),
+		groups:        make(map[string]*ec2.SecurityGroup),
+		mainTables:    make(map[string]string),
+		defaultAcls:   make(map[string]string),
+		defaultGroups: make(map[string]string),
+	}
+
+	for _, r := range state.ResourcesByType("aws_vpc") {
+		b.addVpc(r)
+	}
+	for _, r := range state.ResourcesByType("aws_subnet") {
+		b.addSubnet(r)
+	}
+
+	// Route tables, then the routes and associations managed separately
+	for _, r := range state.ResourcesByType("aws_default_route_table") {
+		id := r.String("default_route_table_id")
+		vpcID := stringOr(r.String("vpc_id"), b.mainTables[id])
+		b.addRouteTable(id, vpcID, r)
+		b.setMain(vpcID, id)
+	}
+	for _, r := range state.ResourcesByType("aws_route_table") {
+		b.addRouteTable(r.ID(), r.String("vpc_id"), r)
+	}
+	for _, r := range state.ResourcesByType("aws_route") {
+		rt, ok := b.routeTables[r.String("route_table_id")]
+		if !ok {
+			return nil, fmt.Errorf("%s: route table %s not found", r.Address, r.String("route_table_id"))
+		}
+		rt.Routes = append(rt.Routes, route(r.Values, "destination_cidr_block"))
+	}
+	for _, r := range state.ResourcesByType("aws_main_route_table_association") {
+		b.setMain(r.String("vpc_id"), r.String("route_table_id"))
+	}
+	for _, r := range state.ResourcesByType("aws_route_table_association") {
+		rt, ok := b.routeTables[r.String("route_table_id")]
+		if !ok {
+			return nil, fmt.Errorf("%s: route table %s not found", r.Address, r.String("route_table_id"))
+		}
+		if subnetID := r.String("subnet_id"); subnetID != "" {
+			rt.Associations = append(rt.Associations, &ec2.RouteTableAssociation{
+				RouteTableAssociationId: aws.String(r.ID()),
+				RouteTableId:            rt.RouteTableId,
+				SubnetId:                aws.String(subnetID),
+				Main:                    aws.Bool(false),
+			})
+		}
+	}
+
+	// Network ACLs, their separately managed rules and associations
+	for _, r := range state.ResourcesByType("aws_default_network_acl") {
+		id := r.String("default_network_acl_id")
+		acl := b.addNetworkAcl(id, stringOr(r.String("vpc_id"), b.defaultAcls[id]), r)
+		acl.IsDefault = aws.Bool(true)
+	}
+	for _, r := range state.ResourcesByType("aws_network_acl") {
+		b.addNetworkAcl(r.ID(), r.String("vpc_id"), r)
+	}
+	for _, r := range state.ResourcesByType("aws_network_acl_rule") {
+		acl, ok := b.networkAcls[r.String("network_acl_id")]
+		if !ok {
+			return nil, fmt.Errorf("%s: network ACL %s not found", r.Address, r.String("network_acl_id"))
+		}
+		entry := aclEntry(r.Values, boolValue(r.Values["egress"]))
+		entry.RuleAction = aws.String(r.String("rule_action"))
+		entry.RuleNumber = aws.Int64(int64Value(r.Values["rule_number"]))
+		acl.Entries = append(acl.Entries, entry)
+	}
+	for _, r := range state.ResourcesByType("aws_network_acl_association") {
+		b.associateAcl(r.String("network_acl_id"), r.String("subnet_id"))
+	}
+
+	// Security groups and their separately managed rules
+	for _, r := range state.ResourcesByType("aws_default_security_group") {
+		sg := b.addSecurityGroup(r.ID(), r.String("vpc_id"), r)
+		sg.GroupName = aws.String("default")
+	}
+	for _, r := range state.ResourcesByType("aws_security_group") {
+		b.addSecurityGroup(r.ID(), r.String("vpc_id"), r)
+	}
+	for _, r := range state.ResourcesByType("aws_security_group_rule") {
+		sg, ok := b.groups[r.String("security_group_id")]
+		if !ok {
+			return nil, fmt.Errorf("%s: security group %s not found", r.Address, r.String("security_group_id"))
+		}
+		permission := ipPermission(r.Values, aws.StringValue(sg.GroupId))
+		if source := r.String("source_security_group_id"); source != "" {
+			permission.UserIdGroupPairs = append(permission.UserIdGroupPairs, &ec2.UserIdGroupPair{GroupId: aws.String(source)})
+		}
+		if r.String("type") == "egress" {
+			sg.IpPermissionsEgress = append(sg.IpPermissionsEgress, permission)
+		} else {
+			sg.IpPermissions = append(sg.IpPermissions, permission)
+		}
+	}
+	for _, kind := range []string{"ingress", "egress"} {
+		for _, r := range state.ResourcesByType("aws_vpc_security_group_" + kind + "_rule") {
+			sg, ok := b.groups[r.String("security_group_id")]
+			if !ok {
+				return nil, fmt.Errorf("%s: security group %s not found", r.Address, r.String("security_group_id"))
+			}
+			permission := vpcSecurityGroupRule(r.Values)
+			if kind == "egress" {
+				sg.IpPermissionsEgress = append(sg.IpPermissionsEgress, permission)
+			} else {
+				sg.IpPermissions = append(sg.IpPermissions, permission)
+			}
+		}
+	}
+
+	b.addDefaults()
+	return b.inv, nil
+}
+
+// Model returns the part of the inventory that belongs to one VPC
+func (inv *Inventory) Model(vpcID string) *Model {
+	m := &Model{VpcID: vpcID}
+	for _, subnet := range inv.Subnets {
+		if aws.StringValue(subnet.VpcId) == vpcID {
+			m.Subnets = append(m.Subnets, subnet)
+		}
+	}
+	for _, rt := range inv.RouteTables {
+		if aws.StringValue(rt.VpcId) == vpcID {
+			m.RouteTables = append(m.RouteTables, rt)
+		}
+	}
+	for _, acl := range inv.NetworkAcls {
+		if aws.StringValue(acl.VpcId) == vpcID {
+			m.NetworkAcls = append(m.NetworkAcls, acl)
+		}
+	}
+	for _, sg := range inv.SecurityGroups {
+		if aws.StringValue(sg.VpcId) == vpcID {
+			m.SecurityGroups = append(m.SecurityGroups, sg)
+		}
+	}
+	sortSubnets(m.Subnets)
+	return m
+}
+
+type builder struct {
+	inv         *Inventory
+	vpcs        map[string]*ec2.Vpc
+	routeTables map[string]*ec2.RouteTable
+	networkAcls map[string]*ec2.NetworkAcl
+	groups      map[string]*ec2.SecurityGroup
+	// VPC IDs by the IDs of their main route table, default network ACL and default security group
+	mainTables    map[string]string
+	defaultAcls   map[string]string
+	defaultGroups map[string]string
+}
+
+func (b *builder) addVpc(r terraform.Resource) {
+	vpc := &ec2.Vpc{
+		VpcId:           aws.String(r.ID()),
+		CidrBlock:       aws.String(r.String("cidr_block")),
+		InstanceTenancy: aws.String(stringOr(r.String("instance_tenancy"), "default")),
+		IsDefault:       aws.Bool(false),
+		State:           aws.String("available"),
+		Tags:            tags(r.Values["tags"]),
+	}
+	b.vpcs[r.ID()] = vpc
+	b.inv.Vpcs = append(b.inv.Vpcs, vpc)
+
+	if id := r.String("main_route_table_id"); id != "" {
+		b.mainTables[id] = r.ID()
+	}
+	if id := r.String("default_network_acl_id"); id != "" {
+		b.defaultAcls[id] = r.ID()
+	}
+	if id := r.String("default_security_group_id"); id != "" {
+		b.defaultGroups[id] = r.ID()
+	}
+}
+
+func (b *builder) addSubnet(r terraform.Resource) {
+	subnet := &ec2.Subnet{
+		SubnetId:            aws.String(r.ID()),
+		VpcId:               aws.String(r.String("vpc_id")),
+		CidrBlock:           aws.String(r.String("cidr_block")),
+		AvailabilityZone:    aws.String(r.String("availability_zone")),
+		MapPublicIpOnLaunch: aws.Bool(boolValue(r.Values["map_public_ip_on_launch"])),
+		DefaultForAz:        aws.Bool(false),
+		State:               aws.String("available"),
+		Tags:                tags(r.Values["tags"]),
+	}
+	if _, ipnet, err := net.ParseCIDR(r.String("cidr_block")); err == nil {
+		ones, bits := ipnet.Mask.Size()
+		// EC2 reserves five addresses in every subnet
+		subnet.AvailableIpAddressCount = aws.Int64(int64(1)<<(bits-ones) - 5)
+	}
+	b.inv.Subnets = append(b.inv.Subnets, subnet)
+}
+
+func (b *builder) addRouteTable(id, vpcID string, r terraform.Resource) *ec2.RouteTable {
+	rt := &ec2.RouteTable{
+		RouteTableId: aws.String(id),
+		VpcId:        aws.String(vpcID),
+		Tags:         tags(r.Values["tags"]),
+	}
+	if vpc, ok := b.vpcs[vpcID]; ok {
+		rt.Routes = append(rt.Routes, localRoute(vpc))
+	}
+	for _, item := range list(r.Values["route"]) {
+		if values, ok := item.(map[string]interface{}); ok {
+			rt.Routes = append(rt.Routes, route(values, "cidr_block"))
+		}
+	}
+	b.routeTables[id] = rt
+	b.inv.RouteTables = append(b.inv.RouteTables, rt)
+	return rt
+}
+
+// setMain makes a route table the main table of its VPC
+func (b *builder) setMain(vpcID, routeTableID string) {
+	for id, vpc := range b.mainTables {
+		if vpc == vpcID {
+			delete(b.mainTables, id)
+		}
+	}
+	b.mainTables[routeTableID] = vpcID
+}
+
+func (b *builder) addNetworkAcl(id, vpcID string, r terraform.Resource) *ec2.NetworkAcl {
+	acl := &ec2.NetworkAcl{
+		NetworkAclId: aws.String(id),
+		VpcId:        aws.String(vpcID),
+		IsDefault:    aws.Bool(false),
+		Tags:         tags(r.Values["tags"]),
+	}
+	for _, direction := range []string{"ingress", "egress"} {
+		for _, item := range list(r.Values[direction]) {
+			values, ok := item.(map[string]interface{})
+			if !ok {
+				continue
+			}
+			entry := aclEntry(values, direction == "egress")
+			entry.RuleAction = aws.String(stringValue(values["action"]))
+			entry.RuleNumber = aws.Int64(int64Value(values["rule_no"]))
+			acl.Entries = append(acl.Entries, entry)
+		}
+	}
+	b.networkAcls[id] = acl
+	b.inv.NetworkAcls = append(b.inv.NetworkAcls, acl)
+
+	for _, subnetID := range list(r.Values["subnet_ids"]) {
+		if s, ok := subnetID.(string); ok {
+			b.associateAcl(id, s)
+		}
+	}
+	return acl
+}
+
+// associateAcl moves a subnet to a network ACL; a subnet has exactly one
+func (b *builder) associateAcl(aclID, subnetID string) {
+	for _, acl := range b.inv.NetworkAcls {
+		kept := acl.Associations[:0]
+		for _, assoc := range acl.Associations {
+			if aws.StringValue(assoc.SubnetId) != subnetID {
+				kept = append(kept, assoc)
+			}
+		}
+		acl.Associations = kept
+	}
+	if acl, ok := b.networkAcls[aclID]; ok {
+		acl.Associations = append(acl.Associations, &ec2.NetworkAclAssociation{
+			NetworkAclAssociationId: aws.String(fmt.Sprintf("aclassoc-%s", subnetID)),
+			NetworkAclId:            aws.String(aclID),
+			SubnetId:                aws.String(subnetID),
+		})
+	}
+}
+
+func (b *builder) addSecurityGroup(id, vpcID string, r terraform.Resource) *ec2.SecurityGroup {
+	sg := &ec2.SecurityGroup{
+		GroupId:     aws.String(id),
+		GroupName:   aws.String(r.String("name")),
+		Description: aws.String(r.String("description")),
+		VpcId:       aws.String(vpcID),
+		OwnerId:     aws.String(r.String("owner_id")),
+		Tags:        tags(r.Values["tags"]),
+	}
+	for _, direction := range []string{"ingress", "egress"} {
+		for _, item := range list(r.Values[direction]) {
+			values, ok := item.(map[string]interface{})
+			if !ok {
+				continue
+			}
+			permission := ipPermission(values, id)
+			for _, group := range list(values["security_groups"]) {
+				if s, ok := group.(string); ok {
+					permission.UserIdGroupPairs = append(permission.UserIdGroupPairs, &ec2.UserIdGroupPair{GroupId: aws.String(s)})
+				}
+			}
+			if direction == "egress" {
+				sg.IpPermissionsEgress = append(sg.IpPermissionsEgress, permission)
+			} else {
+				sg.IpPermissions = append(sg.IpPermissions, permission)
+			}
+		}
+	}
+	b.groups[id] = sg
+	b.inv.SecurityGroups = append(b.inv.SecurityGroups, sg)
+	return sg
+}
+
+// addDefaults adds what EC2 creates with every VPC and the state doesn't
+// manage, and associates subnets without an explicit network ACL with the
+// default one
+func (b *builder) addDefaults() {
+	for _, vpc := range b.inv.Vpcs {
+		vpcID := aws.StringValue(vpc.VpcId)
+
+		mainID := ""
+		for id, owner := range b.mainTables {
+			if owner == vpcID {
+				mainID = id
+			}
+		}
+		if mainID == "" {
+			mainID = "rtb-main-" + vpcID
+		}
+		main, ok := b.routeTables[mainID]
+		if !ok {
+			main = &ec2.RouteTable{
+				RouteTableId: aws.String(mainID),
+				VpcId:        vpc.VpcId,
+				Routes:       []*ec2.Route{localRoute(vpc)},
+			}
+			b.routeTables[mainID] = main
+			b.inv.RouteTables = append(b.inv.RouteTables, main)
+		}
+		main.Associations = append(main.Associations, &ec2.RouteTableAssociation{
+			RouteTableAssociationId: aws.String("rtbassoc-main-" + vpcID),
+			RouteTableId:            main.RouteTableId,
+			Main:                    aws.Bool(true),
+		})
+
+		var defaultAcl *ec2.NetworkAcl
+		for _, acl := range b.inv.NetworkAcls {
+			if aws.StringValue(acl.VpcId) == vpcID && aws.BoolValue(acl.IsDefault) {
+				defaultAcl = acl
+			}
+		}
+		if defaultAcl == nil {
+			id := "acl-default-" + vpcID
+			for aclID, owner := range b.defaultAcls {
+				if owner == vpcID {
+					id = aclID
+				}
+			}
+			defaultAcl = &ec2.NetworkAcl{
+				NetworkAclId: aws.String(id),
+				VpcId:        vpc.VpcId,
+				IsDefault:    aws.Bool(true),
+				Entries: []*ec2.NetworkAclEntry{
+					allowAll(100, false),
+					allowAll(100, true),
+				},
+			}
+			b.networkAcls[id] = defaultAcl
+			b.inv.NetworkAcls = append(b.inv.NetworkAcls, defaultAcl)
+		}
+		for _, subnet := range b.inv.Subnets {
+			if aws.StringValue(subnet.VpcId) == vpcID && !b.hasAcl(aws.StringValue(subnet.SubnetId)) {
+				b.associateAcl(aws.StringValue(defaultAcl.NetworkAclId), aws.StringValue(subnet.SubnetId))
+			}
+		}
+
+		hasDefaultGroup := false
+		for _, sg := range b.inv.SecurityGroups {
+			if aws.StringValue(sg.VpcId) == vpcID && aws.StringValue(sg.GroupName) == "default" {
+				hasDefaultGroup = true
+			}
+		}
+		if !hasDefaultGroup {
+			id := "sg-default-" + vpcID
+			for groupID, owner := range b.defaultGroups {
+				if owner == vpcID {
+					id = groupID
+				}
+			}
+			// The default group allows traffic from its members and all outbound traffic
+			sg := &ec2.SecurityGroup{
+				GroupId:     aws.String(id),
+				GroupName:   aws.String("default"),
+				Description: aws.String("default VPC security group"),
+				VpcId:       vpc.VpcId,
+				IpPermissions: []*ec2.IpPermission{{
+					IpProtocol:       aws.String(allTraffic),
+					UserIdGroupPairs: []*ec2.UserIdGroupPair{{GroupId: aws.String(id)}},
+				}},
+				IpPermissionsEgress: []*ec2.IpPermission{{
+					IpProtocol: aws.String(allTraffic),
+					IpRanges:   []*ec2.IpRange{{CidrIp: aws.String("0.0.0.0/0")}},
+				}},
+			}
+			b.groups[id] = sg
+			b.inv.SecurityGroups = append(b.inv.SecurityGroups, sg)
+		}
+	}
+
+	// Every network ACL ends with the rule that denies what no other rule allowed
+	for _, acl := range b.inv.NetworkAcls {
+		acl.Entries = append(acl.Entries, denyAll(false), denyAll(true))
+		sort.SliceStable(acl.Entries, func(i, j int) bool {
+			return aws.Int64Value(acl.Entries[i].RuleNumber) < aws.Int64Value(acl.Entries[j].RuleNumber)
+		})
+	}
+}
+
+func (b *builder) hasAcl(subnetID string) bool {
+	for _, acl := range b.inv.NetworkAcls {
+		for _, assoc := range acl.Associations {
+			if aws.StringValue(assoc.SubnetId) == subnetID {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func localRoute(vpc *ec2.Vpc) *ec2.Route {
+	return &ec2.Route{
+		DestinationCidrBlock: vpc.CidrBlock,
+		GatewayId:            aws.String("local"),
+		Origin:               aws.String("CreateRouteTable"),
+		State:                aws.String("active"),
+	}
+}
+
+// route converts an inline route block or an aws_route resource; cidrKey
+// names the destination attribute, which differs between the two
+func route(values map[string]interface{}, cidrKey string) *ec2.Route {
+	r := &ec2.Route{
+		DestinationCidrBlock: optionalString(values[cidrKey]),
+		Origin:               aws.String("CreateRoute"),
+		State:                aws.String("active"),
+	}
+	targets := map[string]**string{
+		"gateway_id":                &r.GatewayId,
+		"nat_gateway_id":            &r.NatGatewayId,
+		"transit_gateway_id":        &r.TransitGatewayId,
+		"vpc_peering_connection_id": &r.VpcPeeringConnectionId,
+		"network_interface_id":      &r.NetworkInterfaceId,
+		"egress_only_gateway_id":    &r.EgressOnlyInternetGatewayId,
+	}
+	for key, target := range targets {
+		*target = optionalString(values[key])
+	}
+	if ipv6 := optionalString(values["ipv6_cidr_block"]); ipv6 != nil {
+		r.DestinationIpv6CidrBlock = ipv6
+	}
+	if ipv6 := optionalString(values["destination_ipv6_cidr_block"]); ipv6 != nil {
+		r.DestinationIpv6CidrBlock = ipv6
+	}
+	return r
+}
+
+// aclEntry converts the protocol, ports and CIDR of a network ACL rule
+func aclEntry(values map[string]interface{}, egress bool) *ec2.NetworkAclEntry {
+	entry := &ec2.NetworkAclEntry{
+		Egress:        aws.Bool(egress),
+		Protocol:      aws.String(aclProtocol(stringValue(values["protocol"]))),
+		CidrBlock:     optionalString(values["cidr_block"]),
+		Ipv6CidrBlock: optionalString(values["ipv6_cidr_block"]),
+	}
+	if aws.StringValue(entry.Protocol) != allTraffic {
+		entry.PortRange = &ec2.PortRange{
+			From: aws.Int64(int64Value(values["from_port"])),
+			To:   aws.Int64(int64Value(values["to_port"])),
+		}
+	}
+	return entry
+}
+
+func allowAll(ruleNumber int64, egress bool) *ec2.NetworkAclEntry {
+	return &ec2.NetworkAclEntry{
+		RuleNumber: aws.Int64(ruleNumber),
+		Egress:     aws.Bool(egress),
+		Protocol:   aws.String(allTraffic),
+		RuleAction: aws.String("allow"),
+		CidrBlock:  aws.String("0.0.0.0/0"),
+	}
+}
+
+func denyAll(egress bool) *ec2.NetworkAclEntry {
+	entry := allowAll(32767, egress)
+	entry.RuleAction = aws.String("deny")
+	return entry
+}
+
+// ipPermission converts an inline security group rule or an
+// aws_security_group_rule resource
+func ipPermission(values map[string]interface{}, groupID string) *ec2.IpPermission {
+	permission := &ec2.IpPermission{
+		IpProtocol: aws.String(groupProtocol(stringValue(values["protocol"]))),
+	}
+	if aws.StringValue(permission.IpProtocol) != allTraffic {
+		permission.FromPort = aws.Int64(int64Value(values["from_port"]))
+		permission.ToPort = aws.Int64(int64Value(values["to_port"]))
+	}
+	for _, cidr := range list(values["cidr_blocks"]) {
+		if s, ok := cidr.(string); ok {
+			permission.IpRanges = append(permission.IpRanges, &ec2.IpRange{CidrIp: aws.String(s)})
+		}
+	}
+	for _, cidr := range list(values["ipv6_cidr_blocks"]) {
+		if s, ok := cidr.(string); ok {
+			permission.Ipv6Ranges = append(permission.Ipv6Ranges, &ec2.Ipv6Range{CidrIpv6: aws.String(s)})
+		}
+	}
+	if boolValue(values["self"]) {
+		permission.UserIdGroupPairs = append(permission.UserIdGroupPairs, &ec2.UserIdGroupPair{GroupId: aws.String(groupID)})
+	}
+	return permission
+}
+
+// vpcSecurityGroupRule converts an aws_vpc_security_group_ingress_rule or _egress_rule
+func vpcSecurityGroupRule(values map[string]interface{}) *ec2.IpPermission {
+	permission := &ec2.IpPermission{
+		IpProtocol: aws.String(groupProtocol(stringValue(values["ip_protocol"]))),
+	}
+	if aws.StringValue(permission.IpProtocol) != allTraffic {
+		permission.FromPort = aws.Int64(int64Value(values["from_port"]))
+		permission.ToPort = aws.Int64(int64Value(values["to_port"]))
+	}
+	if cidr := optionalString(values["cidr_ipv4"]); cidr != nil {
+		permission.IpRanges = []*ec2.IpRange{{CidrIp: cidr}}
+	}
+	if cidr := optionalString(values["cidr_ipv6"]); cidr != nil {
+		permission.Ipv6Ranges = []*ec2.Ipv6Range{{CidrIpv6: cidr}}
+	}
+	if group := optionalString(values["referenced_security_group_id"]); group != nil {
+		permission.UserIdGroupPairs = []*ec2.UserIdGroupPair{{GroupId: group}}
+	}
+	return permission
+}
+
+// protocolNumbers maps the protocol names terraform accepts to IANA numbers
+var protocolNumbers = map[string]string{"icmp": "1", "tcp": "6", "udp": "17", "icmpv6": "58"}
+
+// aclProtocol returns a protocol as network ACLs report it: a number, or -1 for all
+func aclProtocol(protocol string) string {
+	if protocol == "" || protocol == "all" {
+		return allTraffic
+	}
+	if number, ok := protocolNumbers[protocol]; ok {
+		return number
+	}
+	return protocol
+}
+
+// groupProtocol returns a protocol as security groups report it: a name for
+// tcp, udp and icmp, or -1 for all
+func groupProtocol(protocol string) string {
+	if protocol == "" || protocol == "all" {
+		return allTraffic
+	}
+	for name, number := range protocolNumbers {
+		if protocol == number {
+			return name
+		}
+	}
+	return protocol
+}
+
+func tags(value interface{}) []*ec2.Tag {
+	m, _ := value.(map[string]interface{})
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	var result []*ec2.Tag
+	for _, key := range keys {
+		result = append(result, &ec2.Tag{Key: aws.String(key), Value: aws.String(stringValue(m[key]))})
+	}
+	return result
+}
+
+func list(value interface{}) []interface{} {
+	items, _ := value.([]interface{})
+	return items
+}
+
+func stringValue(value interface{}) string {
+	switch v := value.(type) {
+	case string:
+		return v
+	case nil:
+		return ""
+	default:
+		return fmt.Sprint(v)
+	}
+}
+
+// optionalString returns nil for missing and empty attributes, as EC2 omits them
+func optionalString(value interface{}) *string {
+	if s := stringValue(value); s != "" {
+		return aws.String(s)
+	}
+	return nil
+}
+
+func stringOr(s, fallback string) string {
+	if s == "" {
+		return fallback
+	}
+	return s
+}
+
+func boolValue(value interface{}) bool {
+	switch v := value.(type) {
+	case bool:
+		return v
+	case string:
+		b, _ := strconv.ParseBool(v)
+		return b
+	}
+	return false
+}
+
+func int64Value(value interface{}) int64 {
+	switch v := value.(type) {
+	case float64:
+		return int64(v)
+	case int:
+		return int64(v)
+	case int64:
+		return v
+	case string:
+		n, _ := strconv.ParseInt(v, 10, 64)
+		return n
+	}
+	return 0
+}
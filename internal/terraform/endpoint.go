@@ -0,0 +1,50 @@
+package terraform
+
+import (
+	"bytes"
+	"errors"
+	"io/fs"
+	"os"
+	"path/filepath"
+)
+
+// staleEndpointOverride is the provider override file earlier versions wrote
+// into the working directory for a local endpoint
+const staleEndpointOverride = "qa_endpoint_override.tf"
+
+// endpointHeader marks the override files those versions generated
+const endpointHeader = "# Generated by qa-test-app for a local AWS endpoint; do not edit or commit.\n"
+
+// LocalCredential is the access key and secret used with a local endpoint; emulators accept any
+const LocalCredential = "test"
+
+// removeStaleEndpointOverride deletes a generated override file left in the
+// working directory, which would send every later run to the emulator
+func (e *Executor) removeStaleEndpointOverride() error {
+	path := filepath.Join(e.WorkingDir, staleEndpointOverride)
+	existing, err := os.ReadFile(path)
+	if err != nil || !bytes.HasPrefix(existing, []byte(endpointHeader)) {
+		return nil
+	}
+	if err := os.Remove(path); err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return err
+	}
+	return nil
+}
+
+// endpointEnv points terraform at Endpoint through AWS_ENDPOINT_URL. It goes
+// in the environment of each command rather than a file in the shared working
+// directory, so runs against different endpoints can overlap. Dummy
+// credentials are only set when the environment has none.
+func (e *Executor) endpointEnv() []string {
+	if e.Endpoint == "" {
+		return nil
+	}
+	env := []string{"AWS_ENDPOINT_URL=" + e.Endpoint}
+	if os.Getenv("AWS_ACCESS_KEY_ID") == "" {
+		env = append(env,
+			"AWS_ACCESS_KEY_ID="+LocalCredential,
+			"AWS_SECRET_ACCESS_KEY="+LocalCredential)
+	}
+	return env
+}
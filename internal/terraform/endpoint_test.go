@@ -0,0 +1,60 @@
+package terraform
+
+import (
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+)
+
+func TestEndpointEnv(t *testing.T) {
+	t.Setenv("AWS_ACCESS_KEY_ID", "")
+	executor := NewExecutor(t.TempDir(), "")
+	if env := executor.endpointEnv(); env != nil {
+		t.Errorf("env = %v without an endpoint, want none", env)
+	}
+
+	executor.Endpoint = "http://localhost:4566"
+	want := []string{"AWS_ENDPOINT_URL=http://localhost:4566", "AWS_ACCESS_KEY_ID=test", "AWS_SECRET_ACCESS_KEY=test"}
+	if env := executor.endpointEnv(); !slices.Equal(env, want) {
+		t.Errorf("env = %v, want %v", env, want)
+	}
+
+	// Credentials from the environment are left alone
+	t.Setenv("AWS_ACCESS_KEY_ID", "real")
+	want = []string{"AWS_ENDPOINT_URL=http://localhost:4566"}
+	if env := executor.endpointEnv(); !slices.Equal(env, want) {
+		t.Errorf("env = %v, want %v", env, want)
+	}
+}
+
+func TestRemoveStaleEndpointOverride(t *testing.T) {
+	for _, tc := range []struct {
+		name    string
+		content string
+		removed bool
+	}{
+		{"generated", endpointHeader + "provider \"aws\" {}\n", true},
+		{"written by hand", "provider \"aws\" {}\n", false},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			dir := t.TempDir()
+			path := filepath.Join(dir, staleEndpointOverride)
+			if err := os.WriteFile(path, []byte(tc.content), 0o644); err != nil {
+				t.Fatal(err)
+			}
+
+			if err := NewExecutor(dir, "").removeStaleEndpointOverride(); err != nil {
+				t.Fatal(err)
+			}
+			_, err := os.Stat(path)
+			if removed := os.IsNotExist(err); removed != tc.removed {
+				t.Errorf("removed = %v, want %v", removed, tc.removed)
+			}
+		})
+	}
+
+	if err := NewExecutor(t.TempDir(), "").removeStaleEndpointOverride(); err != nil {
+		t.Errorf("error = %v without an override file, want none", err)
+	}
+}
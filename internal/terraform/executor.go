@@ -2,6 +2,8 @@ package terraform
 
 import (
 	"bytes"
+	"context"
+	"errors"
 	"fmt"
 	"io"
 	"os"
@@ -15,8 +17,40 @@ type Executor struct {
 	TfvarsFile      string
 	CurrentWorkspace string
 	TestName        string
+	// RunsDir holds a directory of run artifacts per workspace
+	RunsDir         string
+	// Timeouts bounds each terraform phase; zero means no limit
+	Timeouts        Timeouts
+	// GracePeriod is how long terraform has to exit after an interrupt before it is killed
+	GracePeriod     time.Duration
+	// PlanFile is the plan saved by the last successful Plan
+	PlanFile        string
+	// Endpoint sends the aws provider to a local emulator such as LocalStack when
+	// set, through AWS_ENDPOINT_URL
+	Endpoint        string
+
+	// runTfvars is the per-run tfvars file owned by this executor, if any
+	runTfvars string
+	// defaultTfvars is the TfvarsFile the executor was created with, used again
+	// once a workspace's run tfvars are gone
+	defaultTfvars string
+}
+
+// Timeouts configures per-phase time limits for terraform commands
+type Timeouts struct {
+	Init    time.Duration
+	Plan    time.Duration
+	Apply   time.Duration
+	Destroy time.Duration
 }
 
+// DefaultGracePeriod is how long terraform gets to release its state lock after an interrupt
+const DefaultGracePeriod = 30 * time.Second
+
+// ErrDestroyFailed is wrapped by CleanupTestEnvironment when terraform destroy
+// fails, which leaves the workspace selected and its state in place
+var ErrDestroyFailed = errors.New("destroy failed")
+
 type ExecutionResult struct {
 	Success bool
 	Output  string
@@ -26,28 +60,30 @@ type ExecutionResult struct {
 // NewExecutor creates a new terraform executor
 func NewExecutor(workingDir, tfvarsFile string) *Executor {
 	return &Executor{
-		WorkingDir: workingDir,
-		TfvarsFile: tfvarsFile,
+		WorkingDir:  workingDir,
+		TfvarsFile:  tfvarsFile,
+		RunsDir:     DefaultRunsDir,
+		GracePeriod: DefaultGracePeriod,
+
+		defaultTfvars: tfvarsFile,
 	}
 }
 
 // SetupTestEnvironment creates isolated workspace for test
-func (e *Executor) SetupTestEnvironment(testName string) error {
+func (e *Executor) SetupTestEnvironment(ctx context.Context, testName string) error {
 	e.TestName = testName
 	
 	// Create unique workspace name
 	timestamp := time.Now().Unix()
-	workspaceName := fmt.Sprintf("test-%s-%d", 
-		strings.ToLower(strings.ReplaceAll(testName, " ", "-")), 
-		timestamp)
+	workspaceName := fmt.Sprintf("%s%d", WorkspacePrefix(testName), timestamp)
 	
 	// Initialize if needed
-	if _, err := e.Init(); err != nil {
+	if _, err := e.Init(ctx); err != nil {
 		return fmt.Errorf("init failed: %w", err)
 	}
 	
 	// Create and select workspace
-	result, err := e.runCommand("workspace", "new", workspaceName)
+	result, err := e.runCommand(ctx, "workspace", "new", workspaceName)
 	if err != nil {
 		return fmt.Errorf("workspace creation failed: %w", err)
 	}
@@ -60,35 +96,56 @@ func (e *Executor) SetupTestEnvironment(testName string) error {
 	return nil
 }
 
+// WorkspacePrefix returns the prefix shared by all workspaces created for a test
+func WorkspacePrefix(testName string) string {
+	return fmt.Sprintf("test-%s-", strings.ToLower(strings.ReplaceAll(testName, " ", "-")))
+}
+
+// IsTestWorkspace reports whether workspace was created by SetupTestEnvironment
+// for testName. The prefix alone is not enough: "vpc" would also match the
+// workspaces of a test named "vpc plan".
+func IsTestWorkspace(testName, workspace string) bool {
+	timestamp, ok := strings.CutPrefix(workspace, WorkspacePrefix(testName))
+	if !ok || timestamp == "" {
+		return false
+	}
+	for _, r := range timestamp {
+		if r < '0' || r > '9' {
+			return false
+		}
+	}
+	return true
+}
+
 // CleanupTestEnvironment destroys resources and removes workspace
-func (e *Executor) CleanupTestEnvironment() error {
+func (e *Executor) CleanupTestEnvironment(ctx context.Context) error {
 	if e.CurrentWorkspace == "" {
 		return fmt.Errorf("no active workspace to cleanup")
 	}
 	
 	// Destroy resources first
-	destroyResult, err := e.Destroy()
-	if err != nil || !destroyResult.Success {
-		return fmt.Errorf("destroy failed: %v, %s", err, destroyResult.Error)
+	if err := e.check(e.Destroy(ctx)); err != nil {
+		return fmt.Errorf("%w: %w", ErrDestroyFailed, err)
 	}
 	
 	// Switch to default workspace
-	if _, err := e.runCommand("workspace", "select", "default"); err != nil {
+	if err := e.check(e.runCommand(ctx, "workspace", "select", "default")); err != nil {
 		return fmt.Errorf("failed to switch to default workspace: %w", err)
 	}
 	
 	// Delete test workspace
-	if _, err := e.runCommand("workspace", "delete", e.CurrentWorkspace); err != nil {
+	if err := e.check(e.runCommand(ctx, "workspace", "delete", e.CurrentWorkspace)); err != nil {
 		return fmt.Errorf("failed to delete workspace: %w", err)
 	}
 	
+	e.removeRunTfvars()
 	e.CurrentWorkspace = ""
 	return nil
 }
 
 // ValidateState checks if state is consistent
-func (e *Executor) ValidateState() error {
-	result, err := e.runCommand("validate")
+func (e *Executor) ValidateState(ctx context.Context) error {
+	result, err := e.runCommand(ctx, "validate")
 	if err != nil {
 		return fmt.Errorf("validation failed: %w", err)
 	}
@@ -100,45 +157,57 @@ func (e *Executor) ValidateState() error {
 	return nil
 }
 
-// HasResources checks if workspace has any resources
-func (e *Executor) HasResources() (bool, error) {
-	result, err := e.runCommand("state", "list")
+// HasResources checks if workspace has any managed resources
+func (e *Executor) HasResources(ctx context.Context) (bool, error) {
+	state, err := e.GetState(ctx)
 	if err != nil {
 		return false, err
 	}
 	
-	return strings.TrimSpace(result.Output) != "", nil
+	return !state.Empty(), nil
 }
 
-// ForceCleanup removes workspace even with resources (emergency cleanup)
-func (e *Executor) ForceCleanup() error {
+// ForceCleanup removes workspace even with resources (emergency cleanup).
+// It doesn't destroy anything; call it after CleanupTestEnvironment failed.
+func (e *Executor) ForceCleanup(ctx context.Context) error {
 	if e.CurrentWorkspace == "" {
 		return nil
 	}
 	
-	// Try normal cleanup first
-	if err := e.CleanupTestEnvironment(); err == nil {
-		return nil
+	if err := e.check(e.runCommand(ctx, "workspace", "select", "default")); err != nil {
+		return fmt.Errorf("failed to switch to default workspace: %w", err)
 	}
-	
-	// Force cleanup if normal cleanup fails
-	e.runCommand("workspace", "select", "default")
-	e.runCommand("workspace", "delete", "-force", e.CurrentWorkspace)
+	if err := e.check(e.runCommand(ctx, "workspace", "delete", "-force", e.CurrentWorkspace)); err != nil {
+		return fmt.Errorf("failed to force-delete workspace: %w", err)
+	}
+	e.removeRunTfvars()
 	e.CurrentWorkspace = ""
 	
 	return nil
 }
 
+// check turns a command that ran but failed into an error
+func (e *Executor) check(result *ExecutionResult, err error) error {
+	if err != nil {
+		return err
+	}
+	if !result.Success {
+		return fmt.Errorf("%s", result.Error)
+	}
+	return nil
+}
+
 // GetWorkspaceInfo returns current workspace details
-func (e *Executor) GetWorkspaceInfo() (map[string]interface{}, error) {
+func (e *Executor) GetWorkspaceInfo(ctx context.Context) (map[string]interface{}, error) {
 	info := map[string]interface{}{
 		"current_workspace": e.CurrentWorkspace,
 		"test_name":        e.TestName,
 		"working_dir":      e.WorkingDir,
+		"tfvars_file":      e.TfvarsFile,
 	}
 	
 	// Check if workspace has resources
-	hasResources, err := e.HasResources()
+	hasResources, err := e.HasResources(ctx)
 	if err != nil {
 		info["has_resources"] = "unknown"
 		info["error"] = err.Error()
@@ -150,43 +219,111 @@ func (e *Executor) GetWorkspaceInfo() (map[string]interface{}, error) {
 }
 
 // Init runs terraform init
-func (e *Executor) Init() (*ExecutionResult, error) {
-	return e.runCommand("init")
+func (e *Executor) Init(ctx context.Context) (*ExecutionResult, error) {
+	ctx, cancel := withTimeout(ctx, e.Timeouts.Init)
+	defer cancel()
+	return e.runCommand(ctx, "init", "-input=false")
 }
 
-// Plan runs terraform plan
-func (e *Executor) Plan() (*ExecutionResult, error) {
-	return e.runCommand("plan", "-var-file="+e.TfvarsFile)
+// Plan runs terraform plan and saves the plan in the run directory for Apply.
+// Output is captured rather than streamed; use PlanJSON to inspect the plan.
+func (e *Executor) Plan(ctx context.Context) (*ExecutionResult, error) {
+	ctx, cancel := withTimeout(ctx, e.Timeouts.Plan)
+	defer cancel()
+
+	planFile, err := e.planFilePath()
+	if err != nil {
+		return nil, err
+	}
+	// Fingerprint the inputs before planning so later edits make the plan stale
+	digest, err := e.inputsDigest()
+	if err != nil {
+		return nil, err
+	}
+
+	result, err := e.captureCommand(ctx, "plan", "-input=false", "-var-file="+e.TfvarsFile, "-out="+planFile)
+	if err != nil || !result.Success {
+		return result, err
+	}
+
+	if err := e.savePlan(planFile, digest); err != nil {
+		return nil, err
+	}
+	return result, nil
 }
 
-// Apply runs terraform apply
-func (e *Executor) Apply() (*ExecutionResult, error) {
-	return e.runCommand("apply", "-auto-approve", "-var-file="+e.TfvarsFile)
+// Apply applies exactly the plan saved by Plan. It refuses to run without a
+// saved plan or when the tfvars or configuration changed since planning;
+// terraform itself rejects the plan if the state moved on.
+func (e *Executor) Apply(ctx context.Context) (*ExecutionResult, error) {
+	if err := e.checkPlanFresh(); err != nil {
+		return nil, err
+	}
+
+	ctx, cancel := withTimeout(ctx, e.Timeouts.Apply)
+	defer cancel()
+	return e.runCommand(ctx, "apply", "-auto-approve", "-input=false", e.PlanFile)
 }
 
 // Destroy runs terraform destroy
-func (e *Executor) Destroy() (*ExecutionResult, error) {
-	return e.runCommand("destroy", "-auto-approve", "-var-file="+e.TfvarsFile)
+func (e *Executor) Destroy(ctx context.Context) (*ExecutionResult, error) {
+	ctx, cancel := withTimeout(ctx, e.Timeouts.Destroy)
+	defer cancel()
+	return e.runCommand(ctx, "destroy", "-auto-approve", "-input=false", "-var-file="+e.TfvarsFile)
+}
+
+// runCommand executes terraform with given arguments and streams output to terminal.
+// Terraform runs without a terminal for input, so commands that could prompt pass -input=false.
+// When ctx is done terraform is sent an interrupt so it can stop cleanly and
+// release its state lock; it is killed if still running after GracePeriod.
+func (e *Executor) runCommand(ctx context.Context, args ...string) (*ExecutionResult, error) {
+	return e.execute(ctx, true, args...)
+}
+
+// captureCommand executes terraform like runCommand but only captures its output
+func (e *Executor) captureCommand(ctx context.Context, args ...string) (*ExecutionResult, error) {
+	return e.execute(ctx, false, args...)
 }
 
-// runCommand executes terraform with given arguments and streams output to terminal
-func (e *Executor) runCommand(args ...string) (*ExecutionResult, error) {
-	cmd := exec.Command("terraform", args...)
+func (e *Executor) execute(ctx context.Context, stream bool, args ...string) (*ExecutionResult, error) {
+	// An override file from an earlier version would send this run to its emulator
+	if err := e.removeStaleEndpointOverride(); err != nil {
+		return nil, fmt.Errorf("stale endpoint override: %w", err)
+	}
+
+	cmd := exec.CommandContext(ctx, "terraform", args...)
 	cmd.Dir = e.WorkingDir
-	cmd.Env = os.Environ()
+	cmd.Env = append(os.Environ(), e.endpointEnv()...)
+	cmd.Cancel = func() error {
+		if err := cmd.Process.Signal(os.Interrupt); err != nil {
+			return cmd.Process.Kill()
+		}
+		return nil
+	}
+	cmd.WaitDelay = e.GracePeriod
+	// Run terraform in its own process group so a terminal Ctrl-C reaches it
+	// only once, through Cancel; a second interrupt makes terraform abort
+	setProcessGroup(cmd)
 	
 	// Create buffers to capture output
 	var outBuf, errBuf bytes.Buffer
 	
 	// Create multi-writers to stream to both terminal and buffer
-	cmd.Stdout = io.MultiWriter(os.Stdout, &outBuf)
-	cmd.Stderr = io.MultiWriter(os.Stderr, &errBuf)
+	cmd.Stdout, cmd.Stderr = &outBuf, &errBuf
+	if stream {
+		cmd.Stdout = io.MultiWriter(os.Stdout, &outBuf)
+		cmd.Stderr = io.MultiWriter(os.Stderr, &errBuf)
+	}
 	
 	// Run command
 	err := cmd.Run()
 	
 	// Combine output
 	combinedOutput := outBuf.String() + errBuf.String()
+	if !stream {
+		// Keep captured output parseable; diagnostics are reported through Error
+		combinedOutput = outBuf.String()
+	}
 	
 	result := &ExecutionResult{
 		Success: err == nil,
@@ -195,38 +332,33 @@ func (e *Executor) runCommand(args ...string) (*ExecutionResult, error) {
 	
 	if err != nil {
 		result.Error = err.Error()
+		if diagnostics := strings.TrimSpace(errBuf.String()); !stream && diagnostics != "" {
+			result.Error += ": " + diagnostics
+		}
 	}
 	
+	if ctxErr := ctx.Err(); ctxErr != nil {
+		return result, fmt.Errorf("terraform %s: %w", args[0], ctxErr)
+	}
 	return result, nil
 }
 
-// GetState returns current terraform state info
-func (e *Executor) GetState() (map[string]interface{}, error) {
-	result, err := e.runCommand("show", "-json")
-	if err != nil {
-		return nil, err
+// withTimeout bounds ctx by timeout when one is configured
+func withTimeout(ctx context.Context, timeout time.Duration) (context.Context, context.CancelFunc) {
+	if timeout <= 0 {
+		return ctx, func() {}
 	}
-	
-	if !result.Success {
-		return nil, fmt.Errorf("terraform show failed: %s", result.Error)
-	}
-	
-	state := map[string]interface{}{
-		"has_resources": strings.Contains(result.Output, `"resources"`),
-		"output":       result.Output,
-	}
-	
-	return state, nil
+	return context.WithTimeout(ctx, timeout)
 }
 
 // Validate checks if terraform configuration is valid
-func (e *Executor) Validate() (*ExecutionResult, error) {
-	return e.runCommand("validate")
+func (e *Executor) Validate(ctx context.Context) (*ExecutionResult, error) {
+	return e.runCommand(ctx, "validate")
 }
 
 // WorkspaceList returns available workspaces
-func (e *Executor) WorkspaceList() ([]string, error) {
-	result, err := e.runCommand("workspace", "list")
+func (e *Executor) WorkspaceList(ctx context.Context) ([]string, error) {
+	result, err := e.runCommand(ctx, "workspace", "list")
 	if err != nil {
 		return nil, err
 	}
@@ -252,21 +384,17 @@ func (e *Executor) WorkspaceList() ([]string, error) {
 }
 
 // SelectWorkspace selects or creates a workspace
-func (e *Executor) SelectWorkspace(name string) (*ExecutionResult, error) {
-	result, err := e.runCommand("workspace", "select", name)
+func (e *Executor) SelectWorkspace(ctx context.Context, name string) (*ExecutionResult, error) {
+	result, err := e.runCommand(ctx, "workspace", "select", name)
 	if err != nil {
 		return result, err
 	}
 	
 	if result.Success {
 		e.CurrentWorkspace = name
+		e.useExistingRunTfvars()
 		return result, nil
 	}
 	
-	return e.runCommand("workspace", "new", name)
-}
-
-// GetOutputs returns terraform outputs as JSON
-func (e *Executor) GetOutputs() (*ExecutionResult, error) {
-	return e.runCommand("output", "-json")
+	return e.runCommand(ctx, "workspace", "new", name)
 }
@@ -0,0 +1,24 @@
+package terraform
+
+import "testing"
+
+func TestIsTestWorkspace(t *testing.T) {
+	for _, tc := range []struct {
+		testName  string
+		workspace string
+		want      bool
+	}{
+		{"VPC", "test-vpc-1760659200", true},
+		{"vpc plan", "test-vpc-plan-1760659200", true},
+		// Another test whose name starts with the same words
+		{"VPC", "test-vpc-plan-1760659200", false},
+		{"VPC", "test-vpc-", false},
+		{"VPC", "test-vpc-12a", false},
+		{"VPC", "test-vpc", false},
+		{"VPC", "default", false},
+	} {
+		if got := IsTestWorkspace(tc.testName, tc.workspace); got != tc.want {
+			t.Errorf("IsTestWorkspace(%q, %q) = %v, want %v", tc.testName, tc.workspace, got, tc.want)
+		}
+	}
+}
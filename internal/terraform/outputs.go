@@ -0,0 +1,117 @@
+package terraform
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+
+	"github.com/hashicorp/hcl/v2/ext/typeexpr"
+	ctyjson "github.com/zclconf/go-cty/cty/json"
+)
+
+// Output is a root module output as printed by terraform output -json
+type Output struct {
+	Value     interface{}     `json:"value"`
+	Type      json.RawMessage `json:"type"`
+	Sensitive bool            `json:"sensitive"`
+}
+
+// TypeString returns the output's type as it would be written in HCL
+func (o Output) TypeString() string {
+	ty, err := ctyjson.UnmarshalType(o.Type)
+	if err != nil {
+		return string(o.Type)
+	}
+	return typeexpr.TypeString(ty)
+}
+
+// Outputs are the root module outputs of a workspace by name
+type Outputs map[string]Output
+
+// Get returns an output by name
+func (o Outputs) Get(name string) (Output, error) {
+	output, ok := o[name]
+	if !ok {
+		return Output{}, fmt.Errorf("output %q not found", name)
+	}
+	return output, nil
+}
+
+// String returns a string output
+func (o Outputs) String(name string) (string, error) {
+	output, err := o.Get(name)
+	if err != nil {
+		return "", err
+	}
+	s, ok := output.Value.(string)
+	if !ok {
+		return "", fmt.Errorf("output %q is %s, not a string", name, output.TypeString())
+	}
+	return s, nil
+}
+
+// StringList returns a list, set or tuple output whose elements are all strings
+func (o Outputs) StringList(name string) ([]string, error) {
+	output, err := o.Get(name)
+	if err != nil {
+		return nil, err
+	}
+	items, ok := output.Value.([]interface{})
+	if !ok {
+		return nil, fmt.Errorf("output %q is %s, not a list of strings", name, output.TypeString())
+	}
+	list := make([]string, len(items))
+	for i, item := range items {
+		s, ok := item.(string)
+		if !ok {
+			return nil, fmt.Errorf("output %q: element %d is not a string", name, i)
+		}
+		list[i] = s
+	}
+	return list, nil
+}
+
+// Map returns a map or object output
+func (o Outputs) Map(name string) (map[string]interface{}, error) {
+	output, err := o.Get(name)
+	if err != nil {
+		return nil, err
+	}
+	m, ok := output.Value.(map[string]interface{})
+	if !ok {
+		return nil, fmt.Errorf("output %q is %s, not a map", name, output.TypeString())
+	}
+	return m, nil
+}
+
+// Values returns the plain value of every output, sensitive ones included
+func (o Outputs) Values() map[string]interface{} {
+	values := make(map[string]interface{}, len(o))
+	for name, output := range o {
+		values[name] = output.Value
+	}
+	return values
+}
+
+// GetOutputs returns the outputs of the current workspace
+func (e *Executor) GetOutputs(ctx context.Context) (Outputs, error) {
+	result, err := e.captureCommand(ctx, "output", "-json")
+	if err != nil {
+		return nil, err
+	}
+
+	if !result.Success {
+		return nil, fmt.Errorf("terraform output failed: %s", result.Error)
+	}
+
+	return ParseOutputs([]byte(result.Output))
+}
+
+// ParseOutputs decodes the output of terraform output -json
+func ParseOutputs(data []byte) (Outputs, error) {
+	var outputs Outputs
+	if err := json.Unmarshal(data, &outputs); err != nil {
+		return nil, fmt.Errorf("failed to parse outputs JSON: %w", err)
+	}
+	return outputs, nil
+}
@@ -0,0 +1,266 @@
+package terraform
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// Plan is the machine-readable form of a saved plan, as printed by terraform show -json
+type Plan struct {
+	FormatVersion    string                  `json:"format_version"`
+	TerraformVersion string                  `json:"terraform_version"`
+	Variables        map[string]PlanVariable `json:"variables"`
+	ResourceChanges  []ResourceChange        `json:"resource_changes"`
+	OutputChanges    map[string]Change       `json:"output_changes"`
+}
+
+// PlanVariable is the value a variable had when the plan was made
+type PlanVariable struct {
+	Value interface{} `json:"value"`
+}
+
+// ResourceChange is the planned change to a single resource instance
+type ResourceChange struct {
+	Address       string      `json:"address"`
+	ModuleAddress string      `json:"module_address"`
+	Mode          string      `json:"mode"`
+	Type          string      `json:"type"`
+	Name          string      `json:"name"`
+	Index         interface{} `json:"index"`
+	ProviderName  string      `json:"provider_name"`
+	Change        Change      `json:"change"`
+	ActionReason  string      `json:"action_reason"`
+}
+
+// Change describes an object before and after a planned change. Attributes
+// that are only known after apply are absent from After and marked in AfterUnknown.
+type Change struct {
+	Actions         Actions     `json:"actions"`
+	Before          interface{} `json:"before"`
+	After           interface{} `json:"after"`
+	AfterUnknown    interface{} `json:"after_unknown"`
+	BeforeSensitive interface{} `json:"before_sensitive"`
+	AfterSensitive  interface{} `json:"after_sensitive"`
+}
+
+// Actions is the list of actions terraform plans for a change
+type Actions []string
+
+// Terraform plan actions
+const (
+	ActionNoOp   = "no-op"
+	ActionCreate = "create"
+	ActionRead   = "read"
+	ActionUpdate = "update"
+	ActionDelete = "delete"
+)
+
+func (a Actions) is(actions ...string) bool {
+	if len(a) != len(actions) {
+		return false
+	}
+	for i := range a {
+		if a[i] != actions[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// NoOp reports that nothing changes
+func (a Actions) NoOp() bool { return a.is(ActionNoOp) }
+
+// Read reports that a data source is read during apply
+func (a Actions) Read() bool { return a.is(ActionRead) }
+
+// Create reports that a new object is created
+func (a Actions) Create() bool { return a.is(ActionCreate) }
+
+// Update reports that an object is updated in place
+func (a Actions) Update() bool { return a.is(ActionUpdate) }
+
+// Delete reports that an object is destroyed
+func (a Actions) Delete() bool { return a.is(ActionDelete) }
+
+// Replace reports that an object is destroyed and created again, in either order
+func (a Actions) Replace() bool {
+	return a.is(ActionDelete, ActionCreate) || a.is(ActionCreate, ActionDelete)
+}
+
+// Symbol returns the marker terraform uses for the action in its plan output
+func (a Actions) Symbol() string {
+	switch {
+	case a.Create():
+		return "+"
+	case a.Update():
+		return "~"
+	case a.Delete():
+		return "-"
+	case a.is(ActionDelete, ActionCreate):
+		return "-/+"
+	case a.is(ActionCreate, ActionDelete):
+		return "+/-"
+	case a.Read():
+		return "<="
+	default:
+		return " "
+	}
+}
+
+func (a Actions) String() string {
+	return strings.Join(a, ", ")
+}
+
+// AfterValues returns the planned attributes of the object, nil if it is deleted
+func (c Change) AfterValues() map[string]interface{} {
+	values, _ := c.After.(map[string]interface{})
+	return values
+}
+
+// BeforeValues returns the attributes of the object before the change, nil if it is created
+func (c Change) BeforeValues() map[string]interface{} {
+	values, _ := c.Before.(map[string]interface{})
+	return values
+}
+
+// AfterUnknownKeys returns the top-level attributes that are only known after apply
+func (c Change) AfterUnknownKeys() []string {
+	unknown, _ := c.AfterUnknown.(map[string]interface{})
+	var keys []string
+	for _, key := range sortedKeys(unknown) {
+		if known, ok := unknown[key].(bool); !ok || known {
+			keys = append(keys, key)
+		}
+	}
+	return keys
+}
+
+// Changes returns the managed resources that the plan changes, skipping no-ops and reads
+func (p *Plan) Changes() []ResourceChange {
+	var changes []ResourceChange
+	for _, rc := range p.ResourceChanges {
+		if rc.Mode == "data" || rc.Change.Actions.NoOp() || rc.Change.Actions.Read() {
+			continue
+		}
+		changes = append(changes, rc)
+	}
+	return changes
+}
+
+// ResourceChangesByType returns the changes to resources of the given type, such as "aws_subnet"
+func (p *Plan) ResourceChangesByType(resourceType string) []ResourceChange {
+	var changes []ResourceChange
+	for _, rc := range p.ResourceChanges {
+		if rc.Mode != "data" && rc.Type == resourceType {
+			changes = append(changes, rc)
+		}
+	}
+	return changes
+}
+
+// ResourceChange returns the change for a resource address
+func (p *Plan) ResourceChange(address string) (ResourceChange, bool) {
+	for _, rc := range p.ResourceChanges {
+		if rc.Address == address {
+			return rc, true
+		}
+	}
+	return ResourceChange{}, false
+}
+
+// PlanSummary counts planned changes the way terraform's "Plan:" line does;
+// replacements are counted both as an add and a destroy
+type PlanSummary struct {
+	Add     int
+	Change  int
+	Destroy int
+	Replace int
+}
+
+// Summary counts the resource changes in the plan
+func (p *Plan) Summary() PlanSummary {
+	var s PlanSummary
+	for _, rc := range p.Changes() {
+		actions := rc.Change.Actions
+		switch {
+		case actions.Create():
+			s.Add++
+		case actions.Update():
+			s.Change++
+		case actions.Delete():
+			s.Destroy++
+		case actions.Replace():
+			s.Add++
+			s.Destroy++
+			s.Replace++
+		}
+	}
+	return s
+}
+
+// Empty reports that the plan changes nothing
+func (s PlanSummary) Empty() bool {
+	return s.Add == 0 && s.Change == 0 && s.Destroy == 0
+}
+
+func (s PlanSummary) String() string {
+	text := fmt.Sprintf("%d to add, %d to change, %d to destroy", s.Add, s.Change, s.Destroy)
+	if s.Replace > 0 {
+		text += fmt.Sprintf(" (%d replaced)", s.Replace)
+	}
+	return text
+}
+
+// VariableValues returns the value of every variable the plan was made with,
+// including defaults
+func (p *Plan) VariableValues() map[string]interface{} {
+	values := make(map[string]interface{}, len(p.Variables))
+	for name, v := range p.Variables {
+		values[name] = v.Value
+	}
+	return values
+}
+
+// OutputNames returns the names of the outputs the plan changes, sorted
+func (p *Plan) OutputNames() []string {
+	names := make([]string, 0, len(p.OutputChanges))
+	for name, change := range p.OutputChanges {
+		if !change.Actions.NoOp() {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	return names
+}
+
+// PlanJSON converts the plan saved by Plan into a Plan
+func (e *Executor) PlanJSON(ctx context.Context) (*Plan, error) {
+	if e.PlanFile == "" {
+		return nil, fmt.Errorf("no saved plan to show, run Plan first")
+	}
+
+	result, err := e.captureCommand(ctx, "show", "-json", e.PlanFile)
+	if err != nil {
+		return nil, err
+	}
+	if !result.Success {
+		return nil, fmt.Errorf("terraform show failed: %s", result.Error)
+	}
+
+	return ParsePlan([]byte(result.Output))
+}
+
+// ParsePlan decodes the output of terraform show -json for a saved plan
+func ParsePlan(data []byte) (*Plan, error) {
+	var plan Plan
+	if err := json.Unmarshal(data, &plan); err != nil {
+		return nil, fmt.Errorf("failed to parse plan JSON: %w", err)
+	}
+	if plan.FormatVersion == "" {
+		return nil, fmt.Errorf("failed to parse plan JSON: missing format_version")
+	}
+	return &plan, nil
+}
@@ -0,0 +1,173 @@
+package terraform
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// ErrStalePlan is returned by Apply when the saved plan no longer matches its inputs
+var ErrStalePlan = errors.New("saved plan is stale")
+
+// planMeta is saved next to a plan file to detect changes made after planning
+type planMeta struct {
+	Workspace  string    `json:"workspace"`
+	TfvarsFile string    `json:"tfvars_file"`
+	Digest     string    `json:"digest"`
+	CreatedAt  time.Time `json:"created_at"`
+}
+
+// planFilePath returns <run dir>/<workspace>.tfplan as an absolute path and
+// creates the run directory
+func (e *Executor) planFilePath() (string, error) {
+	if e.CurrentWorkspace == "" {
+		return "", fmt.Errorf("no active workspace for plan file")
+	}
+
+	dir, err := filepath.Abs(e.RunDir())
+	if err != nil {
+		return "", err
+	}
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return "", fmt.Errorf("failed to create run directory %s: %w", dir, err)
+	}
+	return filepath.Join(dir, e.CurrentWorkspace+".tfplan"), nil
+}
+
+func planMetaPath(planFile string) string {
+	return planFile + ".meta.json"
+}
+
+// savePlan records planFile as the plan to apply, along with the digest of
+// the inputs it was made from
+func (e *Executor) savePlan(planFile, digest string) error {
+	meta := planMeta{
+		Workspace:  e.CurrentWorkspace,
+		TfvarsFile: e.TfvarsFile,
+		Digest:     digest,
+		CreatedAt:  time.Now().UTC(),
+	}
+	data, err := json.MarshalIndent(meta, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.WriteFile(planMetaPath(planFile), data, 0644); err != nil {
+		return fmt.Errorf("failed to save plan metadata: %w", err)
+	}
+
+	e.PlanFile = planFile
+	return nil
+}
+
+// checkPlanFresh verifies the saved plan belongs to the current workspace and
+// that neither the tfvars nor the configuration changed since it was made
+func (e *Executor) checkPlanFresh() error {
+	if e.PlanFile == "" {
+		return fmt.Errorf("no saved plan to apply, run Plan first")
+	}
+
+	data, err := os.ReadFile(planMetaPath(e.PlanFile))
+	if err != nil {
+		return fmt.Errorf("%w: %v", ErrStalePlan, err)
+	}
+	var meta planMeta
+	if err := json.Unmarshal(data, &meta); err != nil {
+		return fmt.Errorf("%w: invalid plan metadata: %v", ErrStalePlan, err)
+	}
+
+	if meta.Workspace != e.CurrentWorkspace {
+		return fmt.Errorf("%w: planned in workspace %q, current workspace is %q",
+			ErrStalePlan, meta.Workspace, e.CurrentWorkspace)
+	}
+	if meta.TfvarsFile != e.TfvarsFile {
+		return fmt.Errorf("%w: planned with %s, current tfvars file is %s",
+			ErrStalePlan, meta.TfvarsFile, e.TfvarsFile)
+	}
+
+	digest, err := e.inputsDigest()
+	if err != nil {
+		return err
+	}
+	if digest != meta.Digest {
+		return fmt.Errorf("%w: tfvars or configuration changed since %s, plan again",
+			ErrStalePlan, meta.CreatedAt.Format(time.RFC3339))
+	}
+	return nil
+}
+
+// inputsDigest hashes the tfvars file and the configuration files of the
+// working directory and every local module terraform init installed
+func (e *Executor) inputsDigest() (string, error) {
+	files := []string{e.tfvarsPath()}
+
+	dirs := append([]string{e.WorkingDir}, e.localModuleDirs()...)
+	for _, dir := range dirs {
+		for _, pattern := range []string{"*.tf", "*.tf.json"} {
+			matches, err := filepath.Glob(filepath.Join(dir, pattern))
+			if err != nil {
+				return "", err
+			}
+			files = append(files, matches...)
+		}
+	}
+	sort.Strings(files)
+
+	h := sha256.New()
+	for _, path := range files {
+		f, err := os.Open(path)
+		if err != nil {
+			return "", fmt.Errorf("failed to hash plan input: %w", err)
+		}
+		fmt.Fprintf(h, "%s\x00", filepath.ToSlash(path))
+		_, err = io.Copy(h, f)
+		f.Close()
+		if err != nil {
+			return "", err
+		}
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// tfvarsPath resolves TfvarsFile the way terraform does, relative to WorkingDir
+func (e *Executor) tfvarsPath() string {
+	if filepath.IsAbs(e.TfvarsFile) {
+		return e.TfvarsFile
+	}
+	return filepath.Join(e.WorkingDir, e.TfvarsFile)
+}
+
+// localModuleDirs lists the module directories recorded by terraform init
+func (e *Executor) localModuleDirs() []string {
+	data, err := os.ReadFile(filepath.Join(e.WorkingDir, ".terraform", "modules", "modules.json"))
+	if err != nil {
+		return nil
+	}
+
+	var manifest struct {
+		Modules []struct {
+			Key string `json:"Key"`
+			Dir string `json:"Dir"`
+		} `json:"Modules"`
+	}
+	if err := json.Unmarshal(data, &manifest); err != nil {
+		return nil
+	}
+
+	var dirs []string
+	for _, m := range manifest.Modules {
+		if m.Key == "" || m.Dir == "" || strings.HasPrefix(m.Dir, ".terraform") {
+			// The root module, or a downloaded module that can't change under us
+			continue
+		}
+		dirs = append(dirs, filepath.Join(e.WorkingDir, m.Dir))
+	}
+	return dirs
+}
@@ -0,0 +1,14 @@
+//go:build !windows
+
+package terraform
+
+import (
+	"os/exec"
+	"syscall"
+)
+
+// setProcessGroup starts cmd in a new process group so terminal signals aimed
+// at qa-test-app are not also delivered to terraform
+func setProcessGroup(cmd *exec.Cmd) {
+	cmd.SysProcAttr = &syscall.SysProcAttr{Setpgid: true}
+}
@@ -0,0 +1,9 @@
+//go:build windows
+
+package terraform
+
+import "os/exec"
+
+// setProcessGroup is a no-op on Windows, where interrupts can't be forwarded
+// and cancellation falls back to killing the process
+func setProcessGroup(cmd *exec.Cmd) {}
@@ -0,0 +1,81 @@
+package terraform
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+)
+
+// DefaultRunsDir is where per-workspace run artifacts are kept, relative to the
+// directory qa-test-app is started from
+const DefaultRunsDir = ".qa/runs"
+
+// RunFileName returns the name of the per-run tfvars file for the format
+func (f TfvarsFormat) RunFileName() string {
+	if f == TfvarsJSON {
+		return "vars.tfvars.json"
+	}
+	return "vars.tfvars"
+}
+
+// RunDir returns the artifact directory of the current workspace
+func (e *Executor) RunDir() string {
+	return filepath.Join(e.RunsDir, e.CurrentWorkspace)
+}
+
+// RunTfvarsPath points TfvarsFile at a tfvars file in the current workspace's
+// run directory and returns its path. Each workspace gets its own file, so runs
+// sharing a checkout can't overwrite each other's variables. The file is
+// removed when the workspace is cleaned up.
+func (e *Executor) RunTfvarsPath(format TfvarsFormat) (string, error) {
+	if e.CurrentWorkspace == "" {
+		return "", fmt.Errorf("no active workspace for run tfvars")
+	}
+
+	// terraform runs in WorkingDir, so -var-file needs an absolute path
+	path, err := filepath.Abs(filepath.Join(e.RunDir(), format.RunFileName()))
+	if err != nil {
+		return "", err
+	}
+
+	e.TfvarsFile = path
+	e.runTfvars = path
+	return path, nil
+}
+
+// useExistingRunTfvars picks up the tfvars a previous run wrote for the current
+// workspace, so it can be destroyed with the variables it was applied with.
+// Without one, TfvarsFile goes back to the executor's default rather than
+// keeping the file of a previously selected workspace.
+func (e *Executor) useExistingRunTfvars() {
+	for _, format := range []TfvarsFormat{TfvarsHCL, TfvarsJSON} {
+		path, err := filepath.Abs(filepath.Join(e.RunDir(), format.RunFileName()))
+		if err != nil {
+			continue
+		}
+		if _, err := os.Stat(path); err == nil {
+			e.TfvarsFile = path
+			e.runTfvars = path
+			return
+		}
+	}
+	e.resetRunTfvars()
+}
+
+// removeRunTfvars deletes the per-run tfvars file and its run directory once empty
+func (e *Executor) removeRunTfvars() {
+	if e.runTfvars == "" {
+		return
+	}
+	os.Remove(e.runTfvars)
+	os.Remove(filepath.Dir(e.runTfvars))
+	e.resetRunTfvars()
+}
+
+// resetRunTfvars forgets the per-run tfvars file and points TfvarsFile back at the default
+func (e *Executor) resetRunTfvars() {
+	if e.runTfvars != "" && e.TfvarsFile == e.runTfvars {
+		e.TfvarsFile = e.defaultTfvars
+	}
+	e.runTfvars = ""
+}
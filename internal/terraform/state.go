@@ -0,0 +1,205 @@
+package terraform
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+)
+
+// State is the machine-readable form of a workspace's state, as printed by terraform show -json
+type State struct {
+	FormatVersion    string       `json:"format_version"`
+	TerraformVersion string       `json:"terraform_version"`
+	Values           *StateValues `json:"values"`
+}
+
+// StateValues holds the outputs and the root module of a state; it is nil for an empty state
+type StateValues struct {
+	Outputs    Outputs `json:"outputs"`
+	RootModule Module  `json:"root_module"`
+}
+
+// Module is a module instance and the resources it manages
+type Module struct {
+	// Address is empty for the root module, e.g. module.vpc otherwise
+	Address      string     `json:"address"`
+	Resources    []Resource `json:"resources"`
+	ChildModules []Module   `json:"child_modules"`
+}
+
+// Resource is a single resource instance; resources with count or for_each
+// appear once per instance with Index set
+type Resource struct {
+	Address         string                 `json:"address"`
+	Mode            string                 `json:"mode"`
+	Type            string                 `json:"type"`
+	Name            string                 `json:"name"`
+	Index           interface{}            `json:"index"`
+	ProviderName    string                 `json:"provider_name"`
+	SchemaVersion   int                    `json:"schema_version"`
+	Values          map[string]interface{} `json:"values"`
+	SensitiveValues map[string]interface{} `json:"sensitive_values"`
+	DependsOn       []string               `json:"depends_on"`
+	Tainted         bool                   `json:"tainted"`
+	DeposedKey      string                 `json:"deposed_key"`
+}
+
+// Managed reports that the resource is managed rather than a data source
+func (r Resource) Managed() bool {
+	return r.Mode == "managed"
+}
+
+// ID returns the provider ID of the resource
+func (r Resource) ID() string {
+	return r.String("id")
+}
+
+// String returns a top-level string attribute, or "" if it is not a string
+func (r Resource) String(attr string) string {
+	s, _ := r.Values[attr].(string)
+	return s
+}
+
+// Tags returns the tags attribute as strings
+func (r Resource) Tags() map[string]string {
+	raw, _ := r.Values["tags"].(map[string]interface{})
+	tags := make(map[string]string, len(raw))
+	for key, value := range raw {
+		if s, ok := value.(string); ok {
+			tags[key] = s
+		}
+	}
+	return tags
+}
+
+// Modules returns every module in the state, the root module first
+func (s *State) Modules() []Module {
+	if s.Values == nil {
+		return nil
+	}
+	var modules []Module
+	var walk func(m Module)
+	walk = func(m Module) {
+		modules = append(modules, m)
+		for _, child := range m.ChildModules {
+			walk(child)
+		}
+	}
+	walk(s.Values.RootModule)
+	return modules
+}
+
+// Module returns the module with the given address, "" for the root module
+func (s *State) Module(address string) (Module, bool) {
+	for _, m := range s.Modules() {
+		if m.Address == address {
+			return m, true
+		}
+	}
+	return Module{}, false
+}
+
+// Resources returns every resource instance in every module, data sources included
+func (s *State) Resources() []Resource {
+	var resources []Resource
+	for _, m := range s.Modules() {
+		resources = append(resources, m.Resources...)
+	}
+	return resources
+}
+
+// ManagedResources returns every managed resource instance
+func (s *State) ManagedResources() []Resource {
+	var resources []Resource
+	for _, r := range s.Resources() {
+		if r.Managed() {
+			resources = append(resources, r)
+		}
+	}
+	return resources
+}
+
+// ResourcesByType returns the managed resources of the given type, such as "aws_subnet"
+func (s *State) ResourcesByType(resourceType string) []Resource {
+	var resources []Resource
+	for _, r := range s.ManagedResources() {
+		if r.Type == resourceType {
+			resources = append(resources, r)
+		}
+	}
+	return resources
+}
+
+// Resource returns the resource instance with the given address
+func (s *State) Resource(address string) (Resource, bool) {
+	for _, r := range s.Resources() {
+		if r.Address == address {
+			return r, true
+		}
+	}
+	return Resource{}, false
+}
+
+// ResourceByID returns the managed resource of the given type with the provider ID
+func (s *State) ResourceByID(resourceType, id string) (Resource, bool) {
+	for _, r := range s.ResourcesByType(resourceType) {
+		if r.ID() == id {
+			return r, true
+		}
+	}
+	return Resource{}, false
+}
+
+// Dependents returns the resources that depend on the given address
+func (s *State) Dependents(address string) []Resource {
+	var resources []Resource
+	for _, r := range s.Resources() {
+		for _, dep := range r.DependsOn {
+			if dep == address || strings.HasPrefix(address, dep+"[") {
+				resources = append(resources, r)
+				break
+			}
+		}
+	}
+	return resources
+}
+
+// Empty reports that the state has no managed resources
+func (s *State) Empty() bool {
+	return len(s.ManagedResources()) == 0
+}
+
+// Outputs returns the root module outputs
+func (s *State) Outputs() Outputs {
+	if s.Values == nil {
+		return nil
+	}
+	return s.Values.Outputs
+}
+
+// GetState returns the parsed state of the current workspace
+func (e *Executor) GetState(ctx context.Context) (*State, error) {
+	result, err := e.captureCommand(ctx, "show", "-json")
+	if err != nil {
+		return nil, err
+	}
+
+	if !result.Success {
+		return nil, fmt.Errorf("terraform show failed: %s", result.Error)
+	}
+
+	return ParseState([]byte(result.Output))
+}
+
+// ParseState decodes the output of terraform show -json for a workspace's state
+func ParseState(data []byte) (*State, error) {
+	var state State
+	if err := json.Unmarshal(data, &state); err != nil {
+		return nil, fmt.Errorf("failed to parse state JSON: %w", err)
+	}
+	if state.FormatVersion == "" {
+		return nil, fmt.Errorf("failed to parse state JSON: missing format_version")
+	}
+	return &state, nil
+}
@@ -1,92 +1,418 @@
 package terraform
 
 import (
+	"bytes"
+	"errors"
 	"fmt"
+	"math"
+	"math/big"
 	"os"
 	"path/filepath"
+	"strconv"
 	"strings"
 	"time"
+	"unicode"
+
+	"github.com/hashicorp/hcl/v2"
+	"github.com/hashicorp/hcl/v2/hclsyntax"
+	"github.com/hashicorp/hcl/v2/hclwrite"
+	"github.com/zclconf/go-cty/cty"
 )
 
-// GenerateTfvarsFile creates a .tfvars file from YAML terraform section with test tags
-func GenerateTfvarsFile(tfvars map[string]interface{}, testName, workspace string, outputPath string) error {
+// ErrTfvarsOutOfDate is returned by CheckTfvarsFile when the file on disk differs
+var ErrTfvarsOutOfDate = errors.New("tfvars file is out of date")
+
+// TfvarsSpec describes the tfvars generated for one test case
+type TfvarsSpec struct {
+	TfVars   map[string]interface{}
+	TestName string
+	// Source is the test case file named in the header comment
+	Source string
+	// Workspace adds the run-specific TestWorkspace and TestTimestamp tags when set
+	Workspace string
+	// Format selects HCL or JSON output; the zero value is HCL
+	Format TfvarsFormat
+}
+
+// RenderTfvars returns the tfvars file content for spec. Keys are sorted at
+// every level and HCL output is formatted like terraform fmt, so the same
+// spec always renders to the same bytes.
+func RenderTfvars(spec TfvarsSpec) ([]byte, error) {
+	vars := withTestTags(spec.TfVars, generateTestTags(spec.TestName, spec.Workspace))
+
+	switch spec.Format {
+	case TfvarsHCL, "":
+		return renderHCL(spec, vars)
+	case TfvarsJSON:
+		return renderJSON(vars)
+	default:
+		return nil, fmt.Errorf("unknown tfvars format %q", spec.Format)
+	}
+}
+
+// renderHCL writes vars as HCL attributes below a header comment
+func renderHCL(spec TfvarsSpec, vars map[string]interface{}) ([]byte, error) {
 	var content strings.Builder
-	
-	// Add original tfvars
-	for key, value := range tfvars {
-		line := formatTfvar(key, value)
+	content.WriteString(tfvarsHeader(spec))
+	for _, key := range sortedKeys(vars) {
+		line, err := formatTfvar(key, vars[key])
+		if err != nil {
+			return nil, err
+		}
 		content.WriteString(line + "\n")
 	}
-	
-	// Add test identification tags
-	testTags := generateTestTags(testName, workspace)
-	content.WriteString(formatTfvar("common_tags", testTags) + "\n")
-	
+
+	// Parse the result back so an encoding bug fails here rather than in terraform
+	if err := verifyTfvars([]byte(content.String()), spec.Source, vars); err != nil {
+		return nil, err
+	}
+
+	return hclwrite.Format([]byte(content.String())), nil
+}
+
+// GenerateTfvarsFile creates a tfvars file from YAML terraform section with test tags
+func GenerateTfvarsFile(spec TfvarsSpec, outputPath string) error {
+	content, err := RenderTfvars(spec)
+	if err != nil {
+		return err
+	}
+
 	// Ensure directory exists
 	dir := filepath.Dir(outputPath)
 	if err := os.MkdirAll(dir, 0755); err != nil {
 		return fmt.Errorf("failed to create directory %s: %w", dir, err)
 	}
-	
-	return os.WriteFile(outputPath, []byte(content.String()), 0644)
+
+	return os.WriteFile(outputPath, content, 0644)
+}
+
+// CheckTfvarsFile compares the file at path with what GenerateTfvarsFile would
+// write for spec and returns an error wrapping ErrTfvarsOutOfDate if they differ
+func CheckTfvarsFile(spec TfvarsSpec, path string) error {
+	want, err := RenderTfvars(spec)
+	if err != nil {
+		return err
+	}
+
+	got, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+
+	if line, ok := firstDifference(got, want); ok {
+		return fmt.Errorf("%w: %s differs from generated content at line %d", ErrTfvarsOutOfDate, path, line)
+	}
+	return nil
+}
+
+// firstDifference returns the first line number at which a and b differ
+func firstDifference(a, b []byte) (int, bool) {
+	if bytes.Equal(a, b) {
+		return 0, false
+	}
+	aLines := strings.Split(string(a), "\n")
+	bLines := strings.Split(string(b), "\n")
+	for i := 0; i < len(aLines) && i < len(bLines); i++ {
+		if aLines[i] != bLines[i] {
+			return i + 1, true
+		}
+	}
+	return min(len(aLines), len(bLines)) + 1, true
+}
+
+func tfvarsHeader(spec TfvarsSpec) string {
+	header := fmt.Sprintf("# Generated by qa-test-app from test case %q", spec.TestName)
+	if spec.Source != "" {
+		header += fmt.Sprintf(" (%s)", filepath.ToSlash(spec.Source))
+	}
+	return header + ". DO NOT EDIT.\n\n"
 }
 
-// generateTestTags creates common tags for test identification
+// generateTestTags creates common tags for test identification.
+// Run-specific tags are only added when a workspace is given.
 func generateTestTags(testName, workspace string) map[string]interface{} {
-	timestamp := time.Now().Format("2006-01-02T15:04:05Z")
-	
-	return map[string]interface{}{
-		"TestCase":        testName,
-		"TestWorkspace":   workspace,
-		"TestTimestamp":   timestamp,
-		"CreatedBy":       "qa-test-app",
-		"AutoCleanup":     "true",
-		"Environment":     "test",
+	tags := map[string]interface{}{
+		"TestCase":    testName,
+		"CreatedBy":   "qa-test-app",
+		"AutoCleanup": "true",
+		"Environment": "test",
+	}
+
+	if workspace != "" {
+		tags["TestWorkspace"] = workspace
+		tags["TestTimestamp"] = time.Now().UTC().Format("2006-01-02T15:04:05Z")
+	}
+	return tags
+}
+
+// withTestTags returns a copy of tfvars with the test tags merged into common_tags.
+// Tags already set in the test case are kept unless a test tag overrides them.
+func withTestTags(tfvars map[string]interface{}, testTags map[string]interface{}) map[string]interface{} {
+	vars := make(map[string]interface{}, len(tfvars)+1)
+	for k, v := range tfvars {
+		vars[k] = v
+	}
+
+	tags := make(map[string]interface{}, len(testTags))
+	if existing, ok := normalizeMap(vars["common_tags"]); ok {
+		for k, v := range existing {
+			tags[k] = v
+		}
 	}
+	for k, v := range testTags {
+		tags[k] = v
+	}
+	vars["common_tags"] = tags
+	return vars
 }
 
 // formatTfvar formats a single terraform variable
-func formatTfvar(key string, value interface{}) string {
+func formatTfvar(key string, value interface{}) (string, error) {
+	if !hclsyntax.ValidIdentifier(key) {
+		return "", fmt.Errorf("invalid variable name %q", key)
+	}
+
+	var b strings.Builder
+	if err := writeHCLValue(&b, value, 0); err != nil {
+		return "", fmt.Errorf("variable %q: %w", key, err)
+	}
+	return fmt.Sprintf("%s = %s", key, b.String()), nil
+}
+
+// writeHCLValue writes the HCL literal for a value decoded from YAML.
+// Objects and lists containing collections are written over multiple lines.
+func writeHCLValue(b *strings.Builder, value interface{}, indent int) error {
+	if m, ok := normalizeMap(value); ok {
+		return writeHCLObject(b, m, indent)
+	}
+
 	switch v := value.(type) {
+	case nil:
+		b.WriteString("null")
 	case string:
-		return fmt.Sprintf(`%s = "%s"`, key, v)
-	case []interface{}:
-		return fmt.Sprintf(`%s = %s`, key, formatList(v))
-	case map[string]interface{}:
-		return fmt.Sprintf(`%s = %s`, key, formatMap(v))
+		b.WriteString(quoteHCLString(v))
 	case bool:
-		return fmt.Sprintf(`%s = %t`, key, v)
-	case int, int64, float64:
-		return fmt.Sprintf(`%s = %v`, key, v)
+		b.WriteString(strconv.FormatBool(v))
+	case int:
+		b.WriteString(strconv.Itoa(v))
+	case int64:
+		b.WriteString(strconv.FormatInt(v, 10))
+	case uint64:
+		b.WriteString(strconv.FormatUint(v, 10))
+	case float64:
+		if math.IsNaN(v) || math.IsInf(v, 0) {
+			return fmt.Errorf("number %v cannot be represented in HCL", v)
+		}
+		b.WriteString(strconv.FormatFloat(v, 'g', -1, 64))
+	case []interface{}:
+		return writeHCLTuple(b, v, indent)
 	default:
-		return fmt.Sprintf(`%s = "%v"`, key, v)
+		return fmt.Errorf("unsupported value type %T", value)
 	}
+	return nil
 }
 
-// formatList formats a list for terraform
-func formatList(items []interface{}) string {
-	var elements []string
+func writeHCLTuple(b *strings.Builder, items []interface{}, indent int) error {
+	if len(items) == 0 {
+		b.WriteString("[]")
+		return nil
+	}
+
+	// Lists of scalars stay on one line
+	if !containsCollection(items) {
+		b.WriteString("[")
+		for i, item := range items {
+			if i > 0 {
+				b.WriteString(", ")
+			}
+			if err := writeHCLValue(b, item, indent); err != nil {
+				return err
+			}
+		}
+		b.WriteString("]")
+		return nil
+	}
+
+	pad := strings.Repeat("  ", indent+1)
+	b.WriteString("[\n")
 	for _, item := range items {
-		switch v := item.(type) {
-		case string:
-			elements = append(elements, fmt.Sprintf(`"%s"`, v))
-		default:
-			elements = append(elements, fmt.Sprintf(`%v`, v))
+		b.WriteString(pad)
+		if err := writeHCLValue(b, item, indent+1); err != nil {
+			return err
+		}
+		b.WriteString(",\n")
+	}
+	b.WriteString(strings.Repeat("  ", indent) + "]")
+	return nil
+}
+
+func writeHCLObject(b *strings.Builder, m map[string]interface{}, indent int) error {
+	if len(m) == 0 {
+		b.WriteString("{}")
+		return nil
+	}
+
+	pad := strings.Repeat("  ", indent+1)
+	b.WriteString("{\n")
+	for _, key := range sortedKeys(m) {
+		value := m[key]
+		// Keys are always quoted so words like "for" or "if" can't start an expression
+		b.WriteString(pad + quoteHCLString(key) + " = ")
+		if err := writeHCLValue(b, value, indent+1); err != nil {
+			return fmt.Errorf("key %q: %w", key, err)
 		}
+		b.WriteString("\n")
 	}
-	return fmt.Sprintf(`[%s]`, strings.Join(elements, ", "))
+	b.WriteString(strings.Repeat("  ", indent) + "}")
+	return nil
 }
 
-// formatMap formats a map for terraform
-func formatMap(m map[string]interface{}) string {
-	var pairs []string
-	for k, v := range m {
-		switch val := v.(type) {
-		case string:
-			pairs = append(pairs, fmt.Sprintf(`  "%s" = "%s"`, k, val))
+// quoteHCLString returns s as a quoted HCL string literal. Besides the usual
+// escapes, template sequences are doubled ($${ and %%{) so values are never
+// interpolated by terraform.
+func quoteHCLString(s string) string {
+	var b strings.Builder
+	b.WriteByte('"')
+	for i, r := range s {
+		switch r {
+		case '"':
+			b.WriteString(`\"`)
+		case '\\':
+			b.WriteString(`\\`)
+		case '\n':
+			b.WriteString(`\n`)
+		case '\r':
+			b.WriteString(`\r`)
+		case '\t':
+			b.WriteString(`\t`)
+		case '$', '%':
+			b.WriteRune(r)
+			if strings.HasPrefix(s[i+1:], "{") {
+				b.WriteRune(r)
+			}
 		default:
-			pairs = append(pairs, fmt.Sprintf(`  "%s" = "%v"`, k, val))
+			if unicode.IsControl(r) {
+				fmt.Fprintf(&b, `\u%04x`, r)
+			} else {
+				b.WriteRune(r)
+			}
+		}
+	}
+	b.WriteByte('"')
+	return b.String()
+}
+
+func containsCollection(items []interface{}) bool {
+	for _, item := range items {
+		if _, ok := item.([]interface{}); ok {
+			return true
+		}
+		if _, ok := normalizeMap(item); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// normalizeMap returns value as a string-keyed map if it is a YAML mapping
+func normalizeMap(value interface{}) (map[string]interface{}, bool) {
+	switch v := value.(type) {
+	case map[string]interface{}:
+		return v, true
+	case map[interface{}]interface{}:
+		m := make(map[string]interface{}, len(v))
+		for key, item := range v {
+			m[fmt.Sprint(key)] = item
+		}
+		return m, true
+	}
+	return nil, false
+}
+
+// verifyTfvars parses generated tfvars content and checks every attribute
+// evaluates back to the value it was generated from
+func verifyTfvars(content []byte, filename string, vars map[string]interface{}) error {
+	file, diags := hclsyntax.ParseConfig(content, filename, hcl.InitialPos)
+	if diags.HasErrors() {
+		return fmt.Errorf("generated tfvars are not valid HCL: %s", diags.Error())
+	}
+
+	attrs, diags := file.Body.JustAttributes()
+	if diags.HasErrors() {
+		return fmt.Errorf("generated tfvars are not valid HCL: %s", diags.Error())
+	}
+	if len(attrs) != len(vars) {
+		return fmt.Errorf("generated tfvars contain %d variables, expected %d", len(attrs), len(vars))
+	}
+
+	for name, attr := range attrs {
+		val, diags := attr.Expr.Value(nil)
+		if diags.HasErrors() {
+			return fmt.Errorf("generated tfvars: variable %q: %s", name, diags.Error())
+		}
+		if !sameValue(val, vars[name]) {
+			return fmt.Errorf("generated tfvars: variable %q does not round-trip", name)
+		}
+	}
+	return nil
+}
+
+// sameValue reports whether a value parsed back from generated tfvars equals
+// the YAML value it was generated from. Integers are compared exactly, so one
+// above 2^53 isn't taken for another that rounds to the same float64.
+func sameValue(val cty.Value, want interface{}) bool {
+	if !val.IsKnown() {
+		return false
+	}
+	if val.IsNull() {
+		return want == nil
+	}
+
+	ty := val.Type()
+	if m, ok := normalizeMap(want); ok {
+		if !ty.IsObjectType() && !ty.IsMapType() || val.LengthInt() != len(m) {
+			return false
+		}
+		for it := val.ElementIterator(); it.Next(); {
+			k, v := it.Element()
+			item, ok := m[k.AsString()]
+			if !ok || !sameValue(v, item) {
+				return false
+			}
+		}
+		return true
+	}
+
+	switch w := want.(type) {
+	case string:
+		return ty == cty.String && val.AsString() == w
+	case bool:
+		return ty == cty.Bool && val.True() == w
+	case int:
+		return ty == cty.Number && val.AsBigFloat().Cmp(new(big.Float).SetInt64(int64(w))) == 0
+	case int64:
+		return ty == cty.Number && val.AsBigFloat().Cmp(new(big.Float).SetInt64(w)) == 0
+	case uint64:
+		return ty == cty.Number && val.AsBigFloat().Cmp(new(big.Float).SetUint64(w)) == 0
+	case float64:
+		// Floats only have to come back as the same float64
+		if ty != cty.Number {
+			return false
+		}
+		f, _ := val.AsBigFloat().Float64()
+		return f == w
+	case []interface{}:
+		if !ty.IsTupleType() && !ty.IsListType() || val.LengthInt() != len(w) {
+			return false
+		}
+		i := 0
+		for it := val.ElementIterator(); it.Next(); i++ {
+			_, v := it.Element()
+			if !sameValue(v, w[i]) {
+				return false
+			}
 		}
+		return true
 	}
-	return fmt.Sprintf("{\n%s\n}", strings.Join(pairs, "\n"))
+	return false
 }
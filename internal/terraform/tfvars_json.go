@@ -0,0 +1,82 @@
+package terraform
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+)
+
+// TfvarsFormat selects how GenerateTfvarsFile writes variables
+type TfvarsFormat string
+
+const (
+	TfvarsHCL  TfvarsFormat = "hcl"
+	TfvarsJSON TfvarsFormat = "json"
+)
+
+// ParseTfvarsFormat validates a format name from a flag or test case
+func ParseTfvarsFormat(name string) (TfvarsFormat, error) {
+	switch f := TfvarsFormat(name); f {
+	case TfvarsHCL, TfvarsJSON:
+		return f, nil
+	default:
+		return "", fmt.Errorf("unknown tfvars format %q, must be %q or %q", name, TfvarsHCL, TfvarsJSON)
+	}
+}
+
+// FileName returns the generated tfvars file name for the format
+func (f TfvarsFormat) FileName() string {
+	if f == TfvarsJSON {
+		return "generated.auto.tfvars.json"
+	}
+	return "generated.tfvars"
+}
+
+// renderJSON writes vars as a terraform .tfvars.json document. JSON has no
+// comments, so unlike the HCL output there is no header.
+func renderJSON(vars map[string]interface{}) ([]byte, error) {
+	value, err := jsonValue(vars)
+	if err != nil {
+		return nil, err
+	}
+
+	// encoding/json sorts map keys, so output is deterministic
+	content, err := json.MarshalIndent(value, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	return append(content, '\n'), nil
+}
+
+// jsonValue converts YAML mappings with non-string keys so they can be marshalled
+func jsonValue(value interface{}) (interface{}, error) {
+	if m, ok := normalizeMap(value); ok {
+		out := make(map[string]interface{}, len(m))
+		for k, v := range m {
+			item, err := jsonValue(v)
+			if err != nil {
+				return nil, fmt.Errorf("key %q: %w", k, err)
+			}
+			out[k] = item
+		}
+		return out, nil
+	}
+
+	switch v := value.(type) {
+	case []interface{}:
+		out := make([]interface{}, len(v))
+		for i, item := range v {
+			elem, err := jsonValue(item)
+			if err != nil {
+				return nil, err
+			}
+			out[i] = elem
+		}
+		return out, nil
+	case float64:
+		if math.IsNaN(v) || math.IsInf(v, 0) {
+			return nil, fmt.Errorf("number %v cannot be represented in JSON", v)
+		}
+	}
+	return value, nil
+}
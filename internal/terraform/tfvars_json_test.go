@@ -0,0 +1,23 @@
+package terraform
+
+import (
+	"testing"
+
+	hcljson "github.com/hashicorp/hcl/v2/json"
+)
+
+func TestRenderTfvarsJSONRoundTrip(t *testing.T) {
+	for _, tc := range tfvarsCases {
+		t.Run(tc.name, func(t *testing.T) {
+			content, err := RenderTfvars(TfvarsSpec{TfVars: tc.vars, TestName: "round trip", Format: TfvarsJSON})
+			if err != nil {
+				t.Fatalf("RenderTfvars: %v", err)
+			}
+			file, diags := hcljson.Parse(content, "generated.auto.tfvars.json")
+			if diags.HasErrors() {
+				t.Fatalf("parse: %s\n%s", diags.Error(), content)
+			}
+			assertRoundTrip(t, file, tc.vars, content)
+		})
+	}
+}
@@ -0,0 +1,136 @@
+package terraform
+
+import (
+	"math"
+	"testing"
+
+	"github.com/hashicorp/hcl/v2"
+	"github.com/hashicorp/hcl/v2/hclsyntax"
+)
+
+// tfvarsCases are variables that must come back unchanged from a generated tfvars file
+var tfvarsCases = []struct {
+	name string
+	vars map[string]interface{}
+}{
+	{"quotes", map[string]interface{}{"v": `say "hi" and ""`}},
+	{"backslashes", map[string]interface{}{"v": `C:\temp\new \"x\" \\`}},
+	{"interpolation", map[string]interface{}{"v": "${var.region}-${upper(\"a\")}"}},
+	{"directive", map[string]interface{}{"v": "%{if true}yes%{endif}"}},
+	{"escaped template", map[string]interface{}{"v": "$${literal} %%{literal}"}},
+	{"lone template characters", map[string]interface{}{"v": "100% of $5 ends in $ or %"}},
+	{"newlines", map[string]interface{}{"v": "line 1\nline 2\r\n\ttabbed"}},
+	{"control characters", map[string]interface{}{"v": "bell\a null\x00"}},
+	{"non-ASCII values", map[string]interface{}{"v": "zürich 東京 🚀"}},
+	{"non-ASCII variable name", map[string]interface{}{"région": "eu-north-1"}},
+	{"non-ASCII keys", map[string]interface{}{"tags": map[string]interface{}{"Größe": "L", "名前": "vpc", "emoji 🚀": "x"}}},
+	{"keyword keys", map[string]interface{}{"m": map[string]interface{}{"for": 1, "if": "x", "null": true, "in": []interface{}{}}}},
+	{"keys needing quotes", map[string]interface{}{"m": map[string]interface{}{"a b": 1, "a.b": 2, "a\"b": 3, "${x}": 4, "": 5}}},
+	{"null", map[string]interface{}{"v": nil, "m": map[string]interface{}{"k": nil}, "l": []interface{}{nil, "a"}}},
+	{"numbers", map[string]interface{}{"int": 42, "negative": -7, "int64": int64(1) << 40, "uint64": uint64(1) << 50, "float": 3.25, "exponent": 1e21, "tiny": 1.5e-7, "zero": 0}},
+	{"integers beyond float64", map[string]interface{}{"account": int64(123456789012345678), "odd": int64(1)<<53 + 1, "max": int64(math.MaxInt64), "min": int64(math.MinInt64), "uint64": uint64(math.MaxUint64)}},
+	{"bools", map[string]interface{}{"yes": true, "no": false, "list": []interface{}{true, false}}},
+	{"empty collections", map[string]interface{}{"list": []interface{}{}, "map": map[string]interface{}{}}},
+	{"nested lists of maps", map[string]interface{}{"rules": []interface{}{
+		map[string]interface{}{"name": "web", "ports": []interface{}{80, 443}, "cidrs": []interface{}{"0.0.0.0/0"}},
+		map[string]interface{}{"name": "ssh", "tags": map[string]interface{}{"Owner": "ops"}, "nested": []interface{}{[]interface{}{1, 2}, []interface{}{}}},
+	}}},
+	{"YAML mapping keys", map[string]interface{}{"m": map[interface{}]interface{}{1: "one", true: "yes", "k": map[interface{}]interface{}{2: "two"}}}},
+}
+
+func TestRenderTfvarsHCLRoundTrip(t *testing.T) {
+	for _, tc := range tfvarsCases {
+		t.Run(tc.name, func(t *testing.T) {
+			content, err := RenderTfvars(TfvarsSpec{TfVars: tc.vars, TestName: "round trip", Format: TfvarsHCL})
+			if err != nil {
+				t.Fatalf("RenderTfvars: %v", err)
+			}
+			file, diags := hclsyntax.ParseConfig(content, "generated.tfvars", hcl.InitialPos)
+			if diags.HasErrors() {
+				t.Fatalf("parse: %s\n%s", diags.Error(), content)
+			}
+			assertRoundTrip(t, file, tc.vars, content)
+		})
+	}
+}
+
+// assertRoundTrip evaluates every attribute of file the way terraform reads
+// tfvars and compares it with the variable it was generated from
+func assertRoundTrip(t *testing.T, file *hcl.File, vars map[string]interface{}, content []byte) {
+	t.Helper()
+	attrs, diags := file.Body.JustAttributes()
+	if diags.HasErrors() {
+		t.Fatalf("attributes: %s\n%s", diags.Error(), content)
+	}
+	// common_tags is added by the generator
+	if len(attrs) != len(vars)+1 {
+		t.Errorf("got %d variables, want %d\n%s", len(attrs), len(vars)+1, content)
+	}
+	for name, want := range vars {
+		attr, ok := attrs[name]
+		if !ok {
+			t.Errorf("variable %q missing\n%s", name, content)
+			continue
+		}
+		val, diags := attr.Expr.Value(nil)
+		if diags.HasErrors() {
+			t.Errorf("variable %q: %s\n%s", name, diags.Error(), content)
+			continue
+		}
+		if !sameValue(val, want) {
+			t.Errorf("variable %q = %#v, want %#v\n%s", name, val, want, content)
+		}
+	}
+}
+
+func TestRenderTfvarsRejects(t *testing.T) {
+	for _, tc := range []struct {
+		name string
+		vars map[string]interface{}
+	}{
+		{"invalid variable name", map[string]interface{}{"not valid": 1}},
+		{"unsupported type", map[string]interface{}{"v": struct{}{}}},
+		{"NaN", map[string]interface{}{"v": nanValue()}},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			if _, err := RenderTfvars(TfvarsSpec{TfVars: tc.vars, Format: TfvarsHCL}); err == nil {
+				t.Error("RenderTfvars succeeded, want an error")
+			}
+		})
+	}
+}
+
+func TestVerifyTfvarsComparesIntegersExactly(t *testing.T) {
+	// Both round to 2^53 as float64, but terraform would read a different number
+	content := []byte("v = 9007199254740993\n")
+	if err := verifyTfvars(content, "generated.tfvars", map[string]interface{}{"v": int64(1) << 53}); err == nil {
+		t.Error("verifyTfvars accepted 2^53+1 for 2^53")
+	}
+	if err := verifyTfvars(content, "generated.tfvars", map[string]interface{}{"v": int64(1)<<53 + 1}); err != nil {
+		t.Errorf("verifyTfvars: %v", err)
+	}
+	if err := verifyTfvars([]byte("v = 0.1\n"), "generated.tfvars", map[string]interface{}{"v": 0.1}); err != nil {
+		t.Errorf("verifyTfvars: %v", err)
+	}
+}
+
+func TestRenderTfvarsDeterministic(t *testing.T) {
+	vars := map[string]interface{}{"b": map[string]interface{}{"z": 1, "a": 2}, "a": []interface{}{"x"}}
+	for _, format := range []TfvarsFormat{TfvarsHCL, TfvarsJSON} {
+		first, err := RenderTfvars(TfvarsSpec{TfVars: vars, Format: format})
+		if err != nil {
+			t.Fatalf("%s: %v", format, err)
+		}
+		for i := 0; i < 10; i++ {
+			again, _ := RenderTfvars(TfvarsSpec{TfVars: vars, Format: format})
+			if string(again) != string(first) {
+				t.Fatalf("%s: output differs between renders:\n%s\n---\n%s", format, first, again)
+			}
+		}
+	}
+}
+
+func nanValue() float64 {
+	zero := 0.0
+	return zero / zero
+}
@@ -0,0 +1,262 @@
+package terraform
+
+import (
+	"fmt"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/hashicorp/hcl/v2"
+	"github.com/hashicorp/hcl/v2/ext/typeexpr"
+	"github.com/hashicorp/hcl/v2/hclparse"
+	"github.com/zclconf/go-cty/cty"
+	"github.com/zclconf/go-cty/cty/convert"
+)
+
+// Variable is a variable block declared by the terraform module
+type Variable struct {
+	Name        string
+	Description string
+	Type        cty.Type
+	Required    bool
+	// Nullable is false when the block sets nullable = false
+	Nullable bool
+	Range    hcl.Range
+}
+
+// TypeString returns the type constraint as it would be written in HCL
+func (v *Variable) TypeString() string {
+	return typeexpr.TypeString(v.Type)
+}
+
+var fileSchema = &hcl.BodySchema{
+	Blocks: []hcl.BlockHeaderSchema{
+		{Type: "variable", LabelNames: []string{"name"}},
+	},
+}
+
+var variableSchema = &hcl.BodySchema{
+	Attributes: []hcl.AttributeSchema{
+		{Name: "type"},
+		{Name: "default"},
+		{Name: "description"},
+		{Name: "nullable"},
+	},
+}
+
+// LoadVariables parses every *.tf file in dir and returns its variable blocks by name
+func LoadVariables(dir string) (map[string]*Variable, error) {
+	files, err := filepath.Glob(filepath.Join(dir, "*.tf"))
+	if err != nil {
+		return nil, err
+	}
+
+	parser := hclparse.NewParser()
+	variables := make(map[string]*Variable)
+	var diags hcl.Diagnostics
+
+	for _, path := range files {
+		file, fileDiags := parser.ParseHCLFile(path)
+		diags = append(diags, fileDiags...)
+		if file == nil {
+			continue
+		}
+
+		content, _, contentDiags := file.Body.PartialContent(fileSchema)
+		diags = append(diags, contentDiags...)
+
+		for _, block := range content.Blocks {
+			v, varDiags := decodeVariable(block)
+			diags = append(diags, varDiags...)
+			if v != nil {
+				variables[v.Name] = v
+			}
+		}
+	}
+
+	if diags.HasErrors() {
+		return nil, fmt.Errorf("failed to load variables from %s: %s", dir, diags.Error())
+	}
+	return variables, nil
+}
+
+// decodeVariable reads the type, default, description and nullable of a variable block
+func decodeVariable(block *hcl.Block) (*Variable, hcl.Diagnostics) {
+	content, _, diags := block.Body.PartialContent(variableSchema)
+
+	v := &Variable{
+		Name:     block.Labels[0],
+		Type:     cty.DynamicPseudoType,
+		Required: true,
+		Nullable: true,
+		Range:    block.DefRange,
+	}
+
+	if attr, ok := content.Attributes["type"]; ok {
+		ty, typeDiags := typeexpr.TypeConstraint(attr.Expr)
+		diags = append(diags, typeDiags...)
+		v.Type = ty
+	}
+	if _, ok := content.Attributes["default"]; ok {
+		v.Required = false
+	}
+	if attr, ok := content.Attributes["nullable"]; ok {
+		val, valDiags := attr.Expr.Value(nil)
+		diags = append(diags, valDiags...)
+		if !valDiags.HasErrors() && val.Type() == cty.Bool && val.IsKnown() && !val.IsNull() {
+			v.Nullable = val.True()
+		}
+	}
+	if attr, ok := content.Attributes["description"]; ok {
+		if val, valDiags := attr.Expr.Value(nil); !valDiags.HasErrors() && val.Type() == cty.String && val.IsKnown() && !val.IsNull() {
+			v.Description = val.AsString()
+		}
+	}
+
+	return v, diags
+}
+
+// TfvarsError lists every mismatch between tfvars and the module's variables
+type TfvarsError struct {
+	Dir      string
+	Problems []string
+}
+
+func (e *TfvarsError) Error() string {
+	return fmt.Sprintf("tfvars do not match variables declared in %s:\n  %s",
+		e.Dir, strings.Join(e.Problems, "\n  "))
+}
+
+// CheckTfvars validates tfvars against the variables declared in the working directory.
+// It reports unknown variables, missing required variables and type mismatches.
+func (e *Executor) CheckTfvars(tfvars map[string]interface{}) error {
+	variables, err := LoadVariables(e.WorkingDir)
+	if err != nil {
+		return err
+	}
+	return CheckTfvars(variables, tfvars, e.WorkingDir)
+}
+
+// CheckTfvars compares tfvars against declared variables; dir is only used in the error message
+func CheckTfvars(variables map[string]*Variable, tfvars map[string]interface{}, dir string) error {
+	var problems []string
+
+	for _, name := range sortedKeys(tfvars) {
+		v, ok := variables[name]
+		if !ok {
+			problems = append(problems, fmt.Sprintf("unknown variable %q", name))
+			continue
+		}
+
+		val, err := toCtyValue(tfvars[name])
+		if err != nil {
+			problems = append(problems, fmt.Sprintf("variable %q: %v", name, err))
+			continue
+		}
+		if _, err := convert.Convert(val, v.Type); err != nil {
+			problems = append(problems, fmt.Sprintf("variable %q: expected %s, got %s: %s",
+				name, v.TypeString(), describeType(val.Type()), err))
+		}
+		// Required variables set to null are reported as missing below
+		if tfvars[name] == nil && !v.Nullable && !v.Required {
+			problems = append(problems, fmt.Sprintf("variable %q is not nullable: terraform would use its default instead of null", name))
+		}
+	}
+
+	// Terraform treats a required variable set to null as not set at all
+	for _, name := range sortedKeys(variables) {
+		if !variables[name].Required {
+			continue
+		}
+		if value, ok := tfvars[name]; !ok {
+			problems = append(problems, fmt.Sprintf("missing required variable %q (%s)",
+				name, variables[name].TypeString()))
+		} else if value == nil {
+			problems = append(problems, fmt.Sprintf("missing required variable %q (%s): it is set to null",
+				name, variables[name].TypeString()))
+		}
+	}
+
+	if len(problems) > 0 {
+		return &TfvarsError{Dir: dir, Problems: problems}
+	}
+	return nil
+}
+
+// toCtyValue converts a value decoded from YAML into the equivalent cty value
+func toCtyValue(value interface{}) (cty.Value, error) {
+	switch v := value.(type) {
+	case nil:
+		return cty.NullVal(cty.DynamicPseudoType), nil
+	case string:
+		return cty.StringVal(v), nil
+	case bool:
+		return cty.BoolVal(v), nil
+	case int:
+		return cty.NumberIntVal(int64(v)), nil
+	case int64:
+		return cty.NumberIntVal(v), nil
+	case uint64:
+		return cty.NumberUIntVal(v), nil
+	case float64:
+		return cty.NumberFloatVal(v), nil
+	case []interface{}:
+		if len(v) == 0 {
+			return cty.EmptyTupleVal, nil
+		}
+		elems := make([]cty.Value, len(v))
+		for i, item := range v {
+			elem, err := toCtyValue(item)
+			if err != nil {
+				return cty.NilVal, err
+			}
+			elems[i] = elem
+		}
+		return cty.TupleVal(elems), nil
+	case map[string]interface{}:
+		if len(v) == 0 {
+			return cty.EmptyObjectVal, nil
+		}
+		attrs := make(map[string]cty.Value, len(v))
+		for key, item := range v {
+			attr, err := toCtyValue(item)
+			if err != nil {
+				return cty.NilVal, err
+			}
+			attrs[key] = attr
+		}
+		return cty.ObjectVal(attrs), nil
+	case map[interface{}]interface{}:
+		m := make(map[string]interface{}, len(v))
+		for key, item := range v {
+			m[fmt.Sprint(key)] = item
+		}
+		return toCtyValue(m)
+	default:
+		return cty.NilVal, fmt.Errorf("unsupported value type %T", value)
+	}
+}
+
+// describeType names the shape of a YAML-derived value for error messages
+func describeType(ty cty.Type) string {
+	switch {
+	case ty.IsTupleType():
+		return "list"
+	case ty.IsObjectType():
+		return "map"
+	case ty == cty.DynamicPseudoType:
+		return "null"
+	default:
+		return ty.FriendlyName()
+	}
+}
+
+// sortedKeys returns the keys of m in lexical order
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
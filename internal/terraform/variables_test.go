@@ -0,0 +1,125 @@
+package terraform
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+const testVariables = `
+variable "vpc_cidr" {
+  type = string
+}
+
+variable "public_subnets" {
+  type = list(string)
+}
+
+variable "environment" {
+  type    = string
+  default = "qa"
+}
+
+variable "common_tags" {
+  type    = map(string)
+  default = null
+}
+
+variable "az_count" {
+  type     = number
+  default  = 2
+  nullable = false
+}
+
+variable "region" {
+  type     = string
+  nullable = false
+}
+`
+
+func loadTestVariables(t *testing.T) map[string]*Variable {
+	t.Helper()
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "variables.tf"), []byte(testVariables), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	variables, err := LoadVariables(dir)
+	if err != nil {
+		t.Fatalf("LoadVariables: %v", err)
+	}
+	return variables
+}
+
+func TestCheckTfvars(t *testing.T) {
+	variables := loadTestVariables(t)
+	for _, tc := range []struct {
+		name   string
+		tfvars map[string]interface{}
+		want   []string
+	}{
+		{
+			name:   "valid",
+			tfvars: map[string]interface{}{"vpc_cidr": "10.0.0.0/16", "public_subnets": []interface{}{"10.0.1.0/24"}, "region": "eu-north-1", "az_count": 3},
+		},
+		{
+			name:   "optional variables set to null",
+			tfvars: map[string]interface{}{"vpc_cidr": "10.0.0.0/16", "public_subnets": []interface{}{}, "region": "eu-north-1", "environment": nil, "common_tags": nil},
+		},
+		{
+			name:   "non-nullable variable set to null",
+			tfvars: map[string]interface{}{"vpc_cidr": "10.0.0.0/16", "public_subnets": []interface{}{}, "region": "eu-north-1", "az_count": nil},
+			want:   []string{`variable "az_count" is not nullable: terraform would use its default instead of null`},
+		},
+		{
+			name:   "required non-nullable variable set to null",
+			tfvars: map[string]interface{}{"vpc_cidr": "10.0.0.0/16", "public_subnets": []interface{}{}, "region": nil},
+			want:   []string{`missing required variable "region" (string): it is set to null`},
+		},
+		{
+			name:   "missing required variable",
+			tfvars: map[string]interface{}{"vpc_cidr": "10.0.0.0/16", "region": "eu-north-1"},
+			want:   []string{`missing required variable "public_subnets" (list(string))`},
+		},
+		{
+			name:   "required variable set to null",
+			tfvars: map[string]interface{}{"vpc_cidr": nil, "public_subnets": []interface{}{"10.0.1.0/24"}, "region": "eu-north-1"},
+			want:   []string{`missing required variable "vpc_cidr" (string): it is set to null`},
+		},
+		{
+			name:   "unknown variable and wrong type",
+			tfvars: map[string]interface{}{"vpc_cidr": "10.0.0.0/16", "public_subnets": "10.0.1.0/24", "region": "eu-north-1", "zone": "a"},
+			want: []string{
+				`variable "public_subnets": expected list(string), got string: list of string required`,
+				`unknown variable "zone"`,
+			},
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			err := CheckTfvars(variables, tc.tfvars, "module")
+			if tc.want == nil {
+				if err != nil {
+					t.Fatalf("CheckTfvars: %v", err)
+				}
+				return
+			}
+			var tfvarsErr *TfvarsError
+			if !errors.As(err, &tfvarsErr) {
+				t.Fatalf("CheckTfvars error = %v, want a TfvarsError", err)
+			}
+			if !reflect.DeepEqual(tfvarsErr.Problems, tc.want) {
+				t.Errorf("problems = %q, want %q", tfvarsErr.Problems, tc.want)
+			}
+		})
+	}
+}
+
+func TestLoadVariablesNullable(t *testing.T) {
+	variables := loadTestVariables(t)
+	for name, want := range map[string]bool{"vpc_cidr": true, "az_count": false, "region": false} {
+		if got := variables[name].Nullable; got != want {
+			t.Errorf("%s: Nullable = %v, want %v", name, got, want)
+		}
+	}
+}
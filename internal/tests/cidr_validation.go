@@ -4,11 +4,38 @@ import (
 	"context"
 	"fmt"
 	"net"
+
+	"qa-test-app/internal/network"
+)
+
+func init() {
+	RegisterBuiltin(&CIDRValidationTest{})
+}
+
+// AWS limits on the prefix length of VPC and subnet CIDR blocks
+const (
+	MinCIDRPrefix = 16
+	MaxCIDRPrefix = 28
 )
 
+// SubnetCIDRKeys are the output and tfvars names subnet CIDRs are read from,
+// by kind and in order; outputs are searched before tfvars
+var SubnetCIDRKeys = map[string][]string{
+	"public":  {"public_subnet_cidrs", "public_subnets"},
+	"private": {"private_subnet_cidrs", "private_subnets"},
+}
+
 // CIDRValidationTest validates CIDR ranges don't overlap
 type CIDRValidationTest struct{}
 
+// subnetCIDR is a subnet CIDR under the name it is reported by, e.g. public[0]
+type subnetCIDR struct {
+	name    string
+	kind    string
+	cidr    string
+	network *net.IPNet
+}
+
 func (t *CIDRValidationTest) Name() string {
 	return "validate_cidr_ranges"
 }
@@ -18,11 +45,16 @@ func (t *CIDRValidationTest) Description() string {
 }
 
 func (t *CIDRValidationTest) Execute(ctx context.Context, tfOutputs map[string]interface{}) TestResult {
+	tfvars := EnvironmentFrom(ctx).TfVars
+
 	vpcCIDR, ok := tfOutputs["vpc_cidr_block"].(string)
+	if !ok {
+		vpcCIDR, ok = tfvars["vpc_cidr"].(string)
+	}
 	if !ok {
 		return TestResult{
 			Success: false,
-			Message: "VPC CIDR block not found in outputs",
+			Message: "VPC CIDR block not found in outputs or tfvars",
 		}
 	}
 
@@ -35,25 +67,141 @@ func (t *CIDRValidationTest) Execute(ctx context.Context, tfOutputs map[string]i
 		}
 	}
 
-	details := map[string]interface{}{
-		"vpc_cidr": vpcCIDR,
-		"checks": []string{},
-	}
-
 	checks := []string{
 		fmt.Sprintf("VPC CIDR %s is valid", vpcCIDR),
 		fmt.Sprintf("VPC network size: %d addresses", getNetworkSize(vpcNet)),
 	}
+	var violations []string
+	if msg := prefixViolation(vpcNet); msg != "" {
+		violations = append(violations, fmt.Sprintf("VPC CIDR %s: %s", vpcCIDR, msg))
+	}
+
+	subnets, sources := subnetCIDRs(tfOutputs, tfvars)
+	var valid []subnetCIDR
+	for _, subnet := range subnets {
+		_, subnetNet, err := net.ParseCIDR(subnet.cidr)
+		if err != nil {
+			violations = append(violations, fmt.Sprintf("%s: invalid CIDR %q", subnet.name, subnet.cidr))
+			continue
+		}
+		subnet.network = subnetNet
+		valid = append(valid, subnet)
+
+		if msg := prefixViolation(subnetNet); msg != "" {
+			violations = append(violations, fmt.Sprintf("%s %s: %s", subnet.name, subnet.cidr, msg))
+		}
+		if !network.Contains(vpcNet, subnetNet) {
+			violations = append(violations, fmt.Sprintf("%s %s is outside VPC CIDR %s", subnet.name, subnet.cidr, vpcNet))
+			continue
+		}
+		checks = append(checks, fmt.Sprintf("%s %s is inside %s", subnet.name, subnet.cidr, vpcNet))
+	}
+
+	// Every overlapping pair is reported, not just the first one found
+	overlaps := []map[string]string{}
+	for i, a := range valid {
+		for _, b := range valid[i+1:] {
+			if !overlapNets(a.network, b.network) {
+				continue
+			}
+			kind := "overlap"
+			if a.kind != b.kind {
+				kind = "public/private collision"
+			}
+			overlaps = append(overlaps, map[string]string{
+				"first":  fmt.Sprintf("%s %s", a.name, a.cidr),
+				"second": fmt.Sprintf("%s %s", b.name, b.cidr),
+				"kind":   kind,
+			})
+			violations = append(violations, fmt.Sprintf("%s %s and %s %s: %s", a.name, a.cidr, b.name, b.cidr, kind))
+		}
+	}
+	if len(valid) > 1 && len(overlaps) == 0 {
+		checks = append(checks, fmt.Sprintf("No overlaps between %d subnet CIDR(s)", len(valid)))
+	}
 
-	details["checks"] = checks
+	details := map[string]interface{}{
+		"vpc_cidr":     vpcCIDR,
+		"subnet_count": len(subnets),
+		"sources":      sources,
+		"checks":       checks,
+	}
+	if len(overlaps) > 0 {
+		details["overlapping_pairs"] = overlaps
+	}
+	if len(violations) > 0 {
+		details["violations"] = violations
+		return TestResult{
+			Success: false,
+			Message: fmt.Sprintf("%d CIDR violation(s)", len(violations)),
+			Details: details,
+		}
+	}
 
+	if len(subnets) == 0 {
+		return TestResult{
+			Success: true,
+			Message: "CIDR validation passed; no subnet CIDRs found in outputs or tfvars",
+			Details: details,
+		}
+	}
 	return TestResult{
 		Success: true,
-		Message: "CIDR validation passed",
+		Message: fmt.Sprintf("CIDR validation passed for %d subnet(s)", len(subnets)),
 		Details: details,
 	}
 }
 
+// subnetCIDRs collects the public and private subnet CIDRs, each kind from
+// the first SubnetCIDRKeys entry found in outputs and then tfvars. sources
+// names where each kind was read from.
+func subnetCIDRs(outputs, tfvars map[string]interface{}) (subnets []subnetCIDR, sources map[string]string) {
+	sources = map[string]string{}
+	for _, kind := range []string{"public", "private"} {
+	search:
+		for _, values := range []struct {
+			name string
+			vars map[string]interface{}
+		}{{"outputs", outputs}, {"tfvars", tfvars}} {
+			for _, key := range SubnetCIDRKeys[kind] {
+				cidrs, ok := stringList(values.vars[key])
+				if !ok {
+					continue
+				}
+				for i, cidr := range cidrs {
+					subnets = append(subnets, subnetCIDR{
+						name: fmt.Sprintf("%s[%d]", kind, i),
+						kind: kind,
+						cidr: cidr,
+					})
+				}
+				sources[kind] = values.name + "." + key
+				break search
+			}
+		}
+	}
+	return subnets, sources
+}
+
+// prefixViolation describes how a network breaks the AWS prefix limits, or returns ""
+func prefixViolation(network *net.IPNet) string {
+	ones, bits := network.Mask.Size()
+	switch {
+	case bits != 32:
+		return "only IPv4 CIDR blocks are supported"
+	case ones < MinCIDRPrefix:
+		return fmt.Sprintf("/%d is larger than the AWS maximum of /%d", ones, MinCIDRPrefix)
+	case ones > MaxCIDRPrefix:
+		return fmt.Sprintf("/%d is smaller than the AWS minimum of /%d", ones, MaxCIDRPrefix)
+	}
+	return ""
+}
+
+// overlapNets reports whether two networks share any address
+func overlapNets(a, b *net.IPNet) bool {
+	return a.Contains(b.IP) || b.Contains(a.IP)
+}
+
 // Helper function to calculate network size
 func getNetworkSize(network *net.IPNet) int {
 	ones, bits := network.Mask.Size()
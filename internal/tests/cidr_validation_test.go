@@ -0,0 +1,46 @@
+package tests
+
+import "testing"
+
+func TestCIDRValidationFixture(t *testing.T) {
+	fake, outputs := loadFixture(t)
+	result := runAgainst(fake, fixtureTfvars(), &CIDRValidationTest{}, outputs)
+	assertPasses(t, result)
+	if got := result.Details["subnet_count"]; got != 4 {
+		t.Errorf("subnet_count = %v, want 4", got)
+	}
+}
+
+func TestCIDRValidationViolations(t *testing.T) {
+	for _, tc := range []struct {
+		name    string
+		public  []interface{}
+		private []interface{}
+		want    string
+	}{
+		{"overlap", []interface{}{"10.0.101.0/24", "10.0.101.128/25"}, []interface{}{"10.0.1.0/24"}, "public[0] 10.0.101.0/24 and public[1] 10.0.101.128/25: overlap"},
+		{"duplicate", []interface{}{"10.0.101.0/24"}, []interface{}{"10.0.1.0/24", "10.0.1.0/24"}, "private[0] 10.0.1.0/24 and private[1] 10.0.1.0/24: overlap"},
+		{"public/private collision", []interface{}{"10.0.0.0/20"}, []interface{}{"10.0.1.0/24"}, "public/private collision"},
+		{"outside the VPC", []interface{}{"10.1.101.0/24"}, []interface{}{"10.0.1.0/24"}, "public[0] 10.1.101.0/24 is outside VPC CIDR 10.0.0.0/16"},
+		{"too small", []interface{}{"10.0.101.0/29"}, []interface{}{"10.0.1.0/24"}, "smaller than the AWS minimum of /28"},
+		{"invalid", []interface{}{"10.0.101.0"}, []interface{}{"10.0.1.0/24"}, `public[0]: invalid CIDR "10.0.101.0"`},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			fake, outputs := loadFixture(t)
+			tfvars := fixtureTfvars()
+			tfvars["public_subnets"] = tc.public
+			tfvars["private_subnets"] = tc.private
+			assertFails(t, runAgainst(fake, tfvars, &CIDRValidationTest{}, outputs), "violations", tc.want)
+		})
+	}
+}
+
+func TestCIDRValidationOutputsOverTfvars(t *testing.T) {
+	fake, outputs := loadFixture(t)
+	outputs["public_subnet_cidrs"] = []interface{}{"10.0.101.0/24", "10.0.101.0/24"}
+	result := runAgainst(fake, fixtureTfvars(), &CIDRValidationTest{}, outputs)
+	assertFails(t, result, "violations", "public[0] 10.0.101.0/24 and public[1] 10.0.101.0/24: overlap")
+	if got := result.Details["sources"].(map[string]string)["public"]; got != "outputs.public_subnet_cidrs" {
+		t.Errorf("public CIDRs read from %q, want outputs.public_subnet_cidrs", got)
+	}
+}
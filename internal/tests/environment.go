@@ -0,0 +1,116 @@
+package tests
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"qa-test-app/internal/network"
+	"qa-test-app/internal/terraform"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/ec2/ec2iface"
+)
+
+// Environment is what a test function can inspect besides terraform outputs
+type Environment struct {
+	TestName  string
+	Workspace string
+	TfVars    map[string]interface{}
+	// Plan is the plan that was applied; nil when tests run against an existing workspace
+	Plan *terraform.Plan
+	// Outputs are the typed outputs of the applied workspace; nil before apply
+	Outputs terraform.Outputs
+	// State is the workspace state after apply; nil before apply
+	State *terraform.State
+	// AWS hands out clients for the test case's account and region; nil when there is no AWS access
+	AWS ClientProvider
+}
+
+// ClientProvider hands out AWS clients configured for a test case, so test
+// functions share one session and can be pointed at a fake
+type ClientProvider interface {
+	EC2() (ec2iface.EC2API, error)
+}
+
+// ec2Client returns the EC2 client of the environment carried by ctx
+func ec2Client(ctx context.Context) (ec2iface.EC2API, error) {
+	provider := EnvironmentFrom(ctx).AWS
+	if provider == nil {
+		return nil, fmt.Errorf("no AWS client provider configured")
+	}
+	return provider.EC2()
+}
+
+// networkPollInterval is how often loadNetwork describes the VPC again
+var networkPollInterval = DefaultPollInterval
+
+// loadNetwork describes a VPC, polling with Eventually while the describe
+// calls fail or the subnets named in tfOutputs aren't visible yet, since EC2
+// can lag behind apply. Checks run once on the returned model, so a
+// misconfiguration fails straight away. The returned result is only set when
+// loading failed, with the attempts in its details.
+func loadNetwork(ctx context.Context, vpcID string, tfOutputs map[string]interface{}) (*network.Model, []Attempt, *TestResult) {
+	ec2Svc, err := ec2Client(ctx)
+	if err != nil {
+		return nil, nil, &TestResult{
+			Success: false,
+			Message: fmt.Sprintf("No EC2 client: %v", err),
+		}
+	}
+
+	var model *network.Model
+	result := Eventually(ctx, networkPollInterval, func(ctx context.Context) TestResult {
+		m, err := network.Load(ctx, ec2Svc, vpcID)
+		if err != nil {
+			return TestResult{Success: false, Message: err.Error()}
+		}
+		if missing := missingSubnets(m, tfOutputs); len(missing) > 0 {
+			return TestResult{
+				Success: false,
+				Message: fmt.Sprintf("Subnet(s) %s not found in VPC %s", strings.Join(missing, ", "), vpcID),
+			}
+		}
+		model = m
+		return TestResult{Success: true}
+	})
+	attempts, _ := result.Details["attempts"].([]Attempt)
+	if !result.Success {
+		return nil, attempts, &result
+	}
+	return model, attempts, nil
+}
+
+// missingSubnets returns the subnets in the public_subnet_ids and
+// private_subnet_ids outputs that the model doesn't have
+func missingSubnets(model *network.Model, tfOutputs map[string]interface{}) []string {
+	found := make(map[string]bool, len(model.Subnets))
+	for _, subnet := range model.Subnets {
+		found[aws.StringValue(subnet.SubnetId)] = true
+	}
+	var missing []string
+	for _, key := range []string{"public_subnet_ids", "private_subnet_ids"} {
+		ids, _ := stringList(tfOutputs[key])
+		for _, id := range ids {
+			if !found[id] {
+				missing = append(missing, id)
+			}
+		}
+	}
+	return missing
+}
+
+type environmentKey struct{}
+
+// WithEnvironment returns a context carrying env to test functions
+func WithEnvironment(ctx context.Context, env *Environment) context.Context {
+	return context.WithValue(ctx, environmentKey{}, env)
+}
+
+// EnvironmentFrom returns the environment carried by ctx, or an empty one
+func EnvironmentFrom(ctx context.Context) *Environment {
+	if env, ok := ctx.Value(environmentKey{}).(*Environment); ok && env != nil {
+		return env
+	}
+	return &Environment{}
+}
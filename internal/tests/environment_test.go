@@ -0,0 +1,113 @@
+package tests
+
+import (
+	"context"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/request"
+	"github.com/aws/aws-sdk-go/service/ec2"
+	"github.com/aws/aws-sdk-go/service/ec2/ec2iface"
+)
+
+// laggingEC2 hides a subnet from the first describe calls, like EC2 right after apply
+type laggingEC2 struct {
+	ec2iface.EC2API
+	hidden string
+	// lagCalls is how many DescribeSubnets calls leave the subnet out
+	lagCalls int32
+	calls    atomic.Int32
+}
+
+func (l *laggingEC2) DescribeSubnetsWithContext(ctx context.Context, input *ec2.DescribeSubnetsInput, opts ...request.Option) (*ec2.DescribeSubnetsOutput, error) {
+	out, err := l.EC2API.DescribeSubnetsWithContext(ctx, input, opts...)
+	if err != nil || l.calls.Add(1) > l.lagCalls {
+		return out, err
+	}
+	var subnets []*ec2.Subnet
+	for _, subnet := range out.Subnets {
+		if aws.StringValue(subnet.SubnetId) != l.hidden {
+			subnets = append(subnets, subnet)
+		}
+	}
+	return &ec2.DescribeSubnetsOutput{Subnets: subnets}, nil
+}
+
+// clients hands out an EC2 client that isn't a *fakeaws.EC2
+type clients struct {
+	ec2 ec2iface.EC2API
+}
+
+func (c clients) EC2() (ec2iface.EC2API, error) {
+	return c.ec2, nil
+}
+
+// withFastPolling shortens the interval between attempts to load a VPC
+func withFastPolling(t *testing.T) {
+	t.Helper()
+	interval := networkPollInterval
+	networkPollInterval = 10 * time.Millisecond
+	t.Cleanup(func() { networkPollInterval = interval })
+}
+
+// networkChecks are the test functions that load the VPC from EC2
+func networkChecks() []Function {
+	return []Function{&RouteTableTest{}, connectivityTest(nil, fixtureExpectations())}
+}
+
+func TestLoadNetworkWaitsForSubnets(t *testing.T) {
+	withFastPolling(t)
+	for _, fn := range networkChecks() {
+		t.Run(fn.Name(), func(t *testing.T) {
+			fake, outputs := loadFixture(t)
+			lagging := &laggingEC2{EC2API: fake, hidden: "subnet-private-2", lagCalls: 2}
+			ctx := WithEnvironment(context.Background(), &Environment{AWS: clients{lagging}})
+
+			result := fn.(TestFunction).Execute(ctx, outputs)
+			assertPasses(t, result)
+			if attempts, _ := result.Details["attempts"].([]Attempt); len(attempts) != 3 {
+				t.Errorf("attempts = %+v, want 3", attempts)
+			}
+		})
+	}
+}
+
+func TestLoadNetworkGivesUp(t *testing.T) {
+	withFastPolling(t)
+	fake, outputs := loadFixture(t)
+	lagging := &laggingEC2{EC2API: fake, hidden: "subnet-private-2", lagCalls: 1 << 30}
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	ctx = WithEnvironment(ctx, &Environment{AWS: clients{lagging}})
+
+	result := (&RouteTableTest{}).Execute(ctx, outputs)
+	if result.Success || !strings.HasPrefix(result.Message, "Subnet(s) subnet-private-2 not found in VPC vpc-fixture (still failing after") {
+		t.Errorf("result = %q, want the missing subnet", result.Message)
+	}
+}
+
+func TestNetworkChecksDontPollMisconfigurations(t *testing.T) {
+	for _, fn := range networkChecks() {
+		t.Run(fn.Name(), func(t *testing.T) {
+			fake, outputs := loadFixture(t)
+			withoutDefaultRoute(routeTable(t, fake, "rtb-public"))
+			counting := &laggingEC2{EC2API: fake}
+			// No deadline: a polled failure would take DefaultEventuallyTimeout
+			ctx := WithEnvironment(context.Background(), &Environment{AWS: clients{counting}})
+
+			result := fn.(TestFunction).Execute(ctx, outputs)
+			if result.Success {
+				t.Fatal("passed without a default route in the public route table")
+			}
+			if calls := counting.calls.Load(); calls != 1 {
+				t.Errorf("VPC loaded %d times, want once", calls)
+			}
+			if _, ok := result.Details["attempts"]; ok {
+				t.Errorf("attempts recorded for a single load: %v", result.Details["attempts"])
+			}
+		})
+	}
+}
@@ -0,0 +1,255 @@
+package tests
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// DefaultExternalTimeout bounds an external test function when no timeout is configured
+const DefaultExternalTimeout = time.Minute
+
+// InputFileEnv names the environment variable holding the path of a file with
+// the same JSON an external test function receives on stdin
+const InputFileEnv = "QA_TEST_INPUT"
+
+// maxStderr is how much of an external program's stderr is kept in results
+const maxStderr = 4096
+
+// ExternalTest adapts an executable, such as a script in the tests/ directory,
+// to TestFunction. The program receives an ExternalInput as JSON on stdin and
+// in the file named by $QA_TEST_INPUT, and must print a JSON result such as
+//
+//	{"success": true, "message": "...", "details": {...}}
+//
+// on stdout. Anything it writes to stderr is kept in the result details.
+type ExternalTest struct {
+	name    string
+	Path    string
+	Timeout time.Duration
+}
+
+// ExternalInput is what an external test function receives
+type ExternalInput struct {
+	TestName  string                 `json:"test_name"`
+	TestCase  string                 `json:"test_case"`
+	Workspace string                 `json:"workspace"`
+	Outputs   map[string]interface{} `json:"outputs"`
+	TfVars    map[string]interface{} `json:"tfvars"`
+}
+
+// externalResult is the part of TestResult an external program reports
+type externalResult struct {
+	Success *bool                  `json:"success"`
+	Message string                 `json:"message"`
+	Details map[string]interface{} `json:"details"`
+}
+
+// NewExternalTest creates a test function named after the file, without its extension
+func NewExternalTest(path string, timeout time.Duration) *ExternalTest {
+	base := filepath.Base(path)
+	return &ExternalTest{
+		name:    strings.TrimSuffix(base, filepath.Ext(base)),
+		Path:    path,
+		Timeout: timeout,
+	}
+}
+
+func (t *ExternalTest) Name() string {
+	return t.name
+}
+
+func (t *ExternalTest) Description() string {
+	return fmt.Sprintf("Runs the external program %s", t.Path)
+}
+
+func (t *ExternalTest) Execute(ctx context.Context, tfOutputs map[string]interface{}) TestResult {
+	env := EnvironmentFrom(ctx)
+	input, err := json.Marshal(ExternalInput{
+		TestName:  t.name,
+		TestCase:  env.TestName,
+		Workspace: env.Workspace,
+		Outputs:   tfOutputs,
+		TfVars:    env.TfVars,
+	})
+	if err != nil {
+		return TestResult{
+			Success: false,
+			Message: fmt.Sprintf("Failed to encode input: %v", err),
+		}
+	}
+
+	inputFile, err := writeInputFile(input)
+	if err != nil {
+		return TestResult{
+			Success: false,
+			Message: fmt.Sprintf("Failed to write input file: %v", err),
+		}
+	}
+	defer os.Remove(inputFile)
+
+	timeout := t.Timeout
+	if timeout <= 0 {
+		timeout = DefaultExternalTimeout
+	}
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	path, err := filepath.Abs(t.Path)
+	if err != nil {
+		path = t.Path
+	}
+	cmd := exec.CommandContext(ctx, path)
+	cmd.Env = append(os.Environ(), InputFileEnv+"="+inputFile)
+	cmd.Stdin = bytes.NewReader(input)
+	var stdout, stderr bytes.Buffer
+	cmd.Stdout, cmd.Stderr = &stdout, &stderr
+	// Don't wait forever for children that inherited stdout after the program was killed
+	cmd.WaitDelay = time.Second
+
+	start := time.Now()
+	runErr := cmd.Run()
+
+	details := map[string]interface{}{
+		"program": t.Path,
+	}
+	if s := strings.TrimSpace(stderr.String()); s != "" {
+		if len(s) > maxStderr {
+			s = s[len(s)-maxStderr:]
+		}
+		details["stderr"] = s
+	}
+
+	// The deadline may be this function's timeout or one set by the caller
+	if err := ctx.Err(); err != nil {
+		message := "Cancelled before the program finished"
+		if errors.Is(err, context.DeadlineExceeded) {
+			message = fmt.Sprintf("Timed out after %v", time.Since(start).Round(time.Millisecond))
+		}
+		return TestResult{
+			Success: false,
+			Message: message,
+			Details: details,
+		}
+	}
+
+	var exitErr *exec.ExitError
+	if runErr != nil && !errors.As(runErr, &exitErr) {
+		return TestResult{
+			Success: false,
+			Message: fmt.Sprintf("Failed to run %s: %v", t.Path, runErr),
+			Details: details,
+		}
+	}
+
+	var reported externalResult
+	if err := json.Unmarshal(stdout.Bytes(), &reported); err != nil || reported.Success == nil {
+		message := "Program did not print a JSON result with a success field"
+		if exitErr != nil {
+			message = fmt.Sprintf("Program exited with status %d and no JSON result", exitErr.ExitCode())
+		}
+		if out := strings.TrimSpace(stdout.String()); out != "" {
+			details["stdout"] = out
+		}
+		return TestResult{
+			Success: false,
+			Message: message,
+			Details: details,
+		}
+	}
+
+	for key, value := range reported.Details {
+		details[key] = value
+	}
+	result := TestResult{
+		Success: *reported.Success,
+		Message: reported.Message,
+		Details: details,
+	}
+	// A failing exit status overrides a reported success
+	if exitErr != nil {
+		details["exit_code"] = exitErr.ExitCode()
+		if result.Success {
+			result.Success = false
+			result.Message = fmt.Sprintf("Program reported success but exited with status %d", exitErr.ExitCode())
+		}
+	}
+	return result
+}
+
+// writeInputFile saves the input for programs that prefer reading a file to stdin
+func writeInputFile(input []byte) (string, error) {
+	f, err := os.CreateTemp("", "qa-test-input-*.json")
+	if err != nil {
+		return "", err
+	}
+	if _, err := f.Write(input); err != nil {
+		f.Close()
+		os.Remove(f.Name())
+		return "", err
+	}
+	return f.Name(), f.Close()
+}
+
+// LoadExternalTests returns a test function for every executable file in dir,
+// sorted by name. Hidden files, directories and non-executable files such as a
+// README are skipped.
+func LoadExternalTests(dir string, timeout time.Duration) ([]*ExternalTest, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	var externals []*ExternalTest
+	seen := make(map[string]string)
+	for _, entry := range entries {
+		if entry.IsDir() || strings.HasPrefix(entry.Name(), ".") {
+			continue
+		}
+		info, err := entry.Info()
+		if err != nil {
+			return nil, err
+		}
+		if info.Mode()&0111 == 0 {
+			continue
+		}
+
+		test := NewExternalTest(filepath.Join(dir, entry.Name()), timeout)
+		if other, dup := seen[test.Name()]; dup {
+			return nil, fmt.Errorf("%s and %s both define test function %q", other, test.Path, test.Name())
+		}
+		seen[test.Name()] = test.Path
+		externals = append(externals, test)
+	}
+	sort.Slice(externals, func(i, j int) bool {
+		return externals[i].Name() < externals[j].Name()
+	})
+	return externals, nil
+}
+
+// RegisterExternal registers every executable in dir as a test function. A
+// program may not take the name of a function that is already registered.
+func (te *TestExecutor) RegisterExternal(dir string, timeout time.Duration) ([]string, error) {
+	externals, err := LoadExternalTests(dir, timeout)
+	if err != nil {
+		return nil, err
+	}
+
+	names := make([]string, 0, len(externals))
+	for _, test := range externals {
+		if _, taken := te.lookup(test.Name()); taken {
+			return nil, fmt.Errorf("%s: test function %q is already registered", test.Path, test.Name())
+		}
+		te.Register(test)
+		names = append(names, test.Name())
+	}
+	return names, nil
+}
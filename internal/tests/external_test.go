@@ -0,0 +1,142 @@
+package tests
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+	"time"
+)
+
+// runScript runs a script from testdata/external as an external test function
+func runScript(t *testing.T, script string, timeout time.Duration) TestResult {
+	t.Helper()
+	if runtime.GOOS == "windows" {
+		t.Skip("the external test programs are shell scripts")
+	}
+	fn := NewExternalTest(filepath.Join("testdata", "external", script), timeout)
+	ctx := WithEnvironment(context.Background(), &Environment{
+		TestName:  "vpc",
+		Workspace: "qa-test-vpc-1",
+		TfVars:    map[string]interface{}{"region": "eu-west-1"},
+	})
+	return fn.Execute(ctx, map[string]interface{}{"vpc_id": "vpc-1"})
+}
+
+func TestExternalInput(t *testing.T) {
+	result := runScript(t, "echo_input.sh", 0)
+	assertPasses(t, result)
+
+	for _, source := range []string{"stdin", "file"} {
+		input, ok := result.Details[source].(map[string]interface{})
+		if !ok {
+			t.Fatalf("details[%s] = %v, want the input", source, result.Details[source])
+		}
+		if input["test_name"] != "echo_input" || input["test_case"] != "vpc" || input["workspace"] != "qa-test-vpc-1" {
+			t.Errorf("%s input = %v, want the test function, case and workspace", source, input)
+		}
+		if outputs, _ := input["outputs"].(map[string]interface{}); outputs["vpc_id"] != "vpc-1" {
+			t.Errorf("%s outputs = %v, want vpc_id", source, input["outputs"])
+		}
+		if tfvars, _ := input["tfvars"].(map[string]interface{}); tfvars["region"] != "eu-west-1" {
+			t.Errorf("%s tfvars = %v, want region", source, input["tfvars"])
+		}
+	}
+}
+
+func TestExternalTimeout(t *testing.T) {
+	start := time.Now()
+	result := runScript(t, "hangs.sh", 50*time.Millisecond)
+	if result.Success || !strings.HasPrefix(result.Message, "Timed out after") {
+		t.Errorf("result = %q, want a timeout", result.Message)
+	}
+	if elapsed := time.Since(start); elapsed > 5*time.Second {
+		t.Errorf("took %v, want the program killed at the timeout", elapsed)
+	}
+}
+
+func TestExternalExitStatusOverridesSuccess(t *testing.T) {
+	result := runScript(t, "exit_after_success.sh", 0)
+	if result.Success || result.Message != "Program reported success but exited with status 3" {
+		t.Errorf("result = %q (success %v), want the exit status to fail it", result.Message, result.Success)
+	}
+	if got := result.Details["exit_code"]; got != 3 {
+		t.Errorf("exit_code = %v, want 3", got)
+	}
+}
+
+func TestExternalNotJSON(t *testing.T) {
+	result := runScript(t, "not_json.sh", 0)
+	if result.Success || result.Message != "Program did not print a JSON result with a success field" {
+		t.Errorf("result = %q (success %v), want a missing result", result.Message, result.Success)
+	}
+	if got := result.Details["stdout"]; got != "all good" {
+		t.Errorf("stdout = %v, want what the program printed", got)
+	}
+}
+
+func TestExternalStderrTruncated(t *testing.T) {
+	result := runScript(t, "noisy.sh", 0)
+	assertPasses(t, result)
+
+	stderr, _ := result.Details["stderr"].(string)
+	if len(stderr) != maxStderr {
+		t.Errorf("stderr is %d bytes, want the last %d", len(stderr), maxStderr)
+	}
+	if !strings.HasSuffix(stderr, "x last line") || strings.Contains(stderr, "first line") {
+		t.Errorf("stderr = ...%q, want its end kept and its start dropped", stderr[len(stderr)-20:])
+	}
+}
+
+// writeProgram creates a file in dir with the given mode
+func writeProgram(t *testing.T, dir, name string, mode os.FileMode) {
+	t.Helper()
+	if err := os.WriteFile(filepath.Join(dir, name), []byte("#!/bin/sh\n"), mode); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestLoadExternalTests(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("windows has no executable bit")
+	}
+	dir := t.TempDir()
+	writeProgram(t, dir, "route_check.sh", 0o755)
+	writeProgram(t, dir, "acl_check", 0o755)
+	writeProgram(t, dir, "README.md", 0o644)
+	writeProgram(t, dir, ".hidden.sh", 0o755)
+	if err := os.Mkdir(filepath.Join(dir, "lib"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+
+	externals, err := LoadExternalTests(dir, time.Second)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var names []string
+	for _, test := range externals {
+		names = append(names, test.Name())
+	}
+	if got := strings.Join(names, ","); got != "acl_check,route_check" {
+		t.Errorf("loaded %s, want only the executables, sorted", got)
+	}
+	if externals[1].Timeout != time.Second {
+		t.Errorf("timeout = %v, want 1s", externals[1].Timeout)
+	}
+}
+
+func TestLoadExternalTestsDuplicateNames(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("windows has no executable bit")
+	}
+	dir := t.TempDir()
+	writeProgram(t, dir, "route_check.sh", 0o755)
+	writeProgram(t, dir, "route_check.py", 0o755)
+
+	_, err := LoadExternalTests(dir, 0)
+	if err == nil || !strings.Contains(err.Error(), `both define test function "route_check"`) {
+		t.Errorf("error = %v, want the duplicate name reported", err)
+	}
+}
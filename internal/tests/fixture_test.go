@@ -0,0 +1,102 @@
+package tests
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"qa-test-app/internal/fakeaws"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/ec2"
+)
+
+// fixtureTfvars are the tfvars the VPC in fixtures/vpc.yaml was laid out from
+func fixtureTfvars() map[string]interface{} {
+	return map[string]interface{}{
+		"vpc_cidr":        "10.0.0.0/16",
+		"public_subnets":  []interface{}{"10.0.101.0/24", "10.0.102.0/24"},
+		"private_subnets": []interface{}{"10.0.1.0/24", "10.0.2.0/24"},
+	}
+}
+
+// loadFixture returns a fake EC2 describing fixtures/vpc.yaml, and the fixture's outputs
+func loadFixture(t *testing.T) (*fakeaws.EC2, map[string]interface{}) {
+	t.Helper()
+	state, err := fakeaws.LoadState("../../fixtures/vpc.yaml")
+	if err != nil {
+		t.Fatalf("LoadState: %v", err)
+	}
+	fake, err := fakeaws.FromState(state)
+	if err != nil {
+		t.Fatalf("FromState: %v", err)
+	}
+	return fake, state.Outputs().Values()
+}
+
+// runAgainst executes fn with fake as its AWS account, the way -mock does.
+// Loading a VPC whose subnets are missing is retried until the context ends,
+// so it is kept short.
+func runAgainst(fake *fakeaws.EC2, tfvars map[string]interface{}, fn Function, outputs map[string]interface{}) TestResult {
+	ctx, cancel := context.WithTimeout(context.Background(), 200*time.Millisecond)
+	defer cancel()
+	ctx = WithEnvironment(ctx, &Environment{TfVars: tfvars, AWS: fakeaws.Provider{Fake: fake}})
+	return fn.(TestFunction).Execute(ctx, outputs)
+}
+
+// assertPasses fails the test unless result succeeded
+func assertPasses(t *testing.T, result TestResult) {
+	t.Helper()
+	if !result.Success {
+		t.Fatalf("failed: %s\nproblems: %v", result.Message, result.Details["problems"])
+	}
+}
+
+// assertFails fails the test unless result failed with a detail under key containing want
+func assertFails(t *testing.T, result TestResult, key, want string) {
+	t.Helper()
+	if result.Success {
+		t.Fatalf("passed, want a failure mentioning %q: %s", want, result.Message)
+	}
+	problems, _ := result.Details[key].([]string)
+	for _, problem := range problems {
+		if strings.Contains(problem, want) {
+			return
+		}
+	}
+	t.Fatalf("no %s mention %q: %s %q", key, want, result.Message, problems)
+}
+
+func routeTable(t *testing.T, fake *fakeaws.EC2, id string) *ec2.RouteTable {
+	t.Helper()
+	for _, rt := range fake.RouteTables {
+		if aws.StringValue(rt.RouteTableId) == id {
+			return rt
+		}
+	}
+	t.Fatalf("route table %s not in the fixture", id)
+	return nil
+}
+
+func networkAcl(t *testing.T, fake *fakeaws.EC2, id string) *ec2.NetworkAcl {
+	t.Helper()
+	for _, acl := range fake.NetworkAcls {
+		if aws.StringValue(acl.NetworkAclId) == id {
+			return acl
+		}
+	}
+	t.Fatalf("network ACL %s not in the fixture", id)
+	return nil
+}
+
+// withoutDefaultRoute removes the 0.0.0.0/0 route of a route table
+func withoutDefaultRoute(rt *ec2.RouteTable) {
+	var routes []*ec2.Route
+	for _, route := range rt.Routes {
+		if aws.StringValue(route.DestinationCidrBlock) != "0.0.0.0/0" {
+			routes = append(routes, route)
+		}
+	}
+	rt.Routes = routes
+}
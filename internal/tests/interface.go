@@ -3,14 +3,49 @@ package tests
 
 import (
 	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
 	"time"
+
+	"qa-test-app/internal/terraform"
+	"qa-test-app/internal/yaml"
 )
 
+// Function is what every test function implements; test cases refer to it by Name
+type Function interface {
+	Name() string
+	Description() string
+}
+
 // TestFunction defines the interface for all test functions
 type TestFunction interface {
-	Name() string
+	Function
 	Execute(ctx context.Context, tfOutputs map[string]interface{}) TestResult
-	Description() string
+}
+
+// PlanTestFunction checks a plan before anything is applied. It receives the
+// parsed plan and the tfvars the plan was made with, so it needs no AWS access.
+type PlanTestFunction interface {
+	Function
+	ExecutePlan(ctx context.Context, plan *terraform.Plan, tfvars map[string]interface{}) TestResult
+}
+
+// Configurable is implemented by test functions that accept params from the
+// test case. Params are decoded strictly into the struct NewParams returns, so
+// the struct's yaml tags declare what the function accepts.
+type Configurable interface {
+	// NewParams returns a pointer to a params struct holding the defaults
+	NewParams() interface{}
+	// WithParams returns a copy of the function that uses params, as returned by NewParams
+	WithParams(params interface{}) Function
+}
+
+// ParamsValidator is implemented by params structs that check their own values
+type ParamsValidator interface {
+	Validate() error
 }
 
 // TestResult represents the result of a test execution
@@ -23,21 +58,41 @@ type TestResult struct {
 	Timestamp time.Time              `json:"timestamp"`
 }
 
+// builtins are registered with every TestExecutor created after them
+var builtins []Function
+
+// RegisterBuiltin makes fn available to every TestExecutor created afterwards.
+// It is meant to be called from init and panics if fn is neither a
+// TestFunction nor a PlanTestFunction.
+func RegisterBuiltin(fn Function) {
+	switch fn.(type) {
+	case TestFunction, PlanTestFunction:
+		builtins = append(builtins, fn)
+	default:
+		panic(fmt.Sprintf("tests: %T is neither a TestFunction nor a PlanTestFunction", fn))
+	}
+}
+
 // TestExecutor manages and runs test functions
 type TestExecutor struct {
-	functions map[string]TestFunction
+	functions     map[string]TestFunction
+	planFunctions map[string]PlanTestFunction
 }
 
-// NewTestExecutor creates a new test executor with default functions
+// NewTestExecutor creates a new test executor with the builtin functions
 func NewTestExecutor() *TestExecutor {
 	executor := &TestExecutor{
-		functions: make(map[string]TestFunction),
+		functions:     make(map[string]TestFunction),
+		planFunctions: make(map[string]PlanTestFunction),
 	}
 	
-	// Register default test functions
-	executor.Register(&CIDRValidationTest{})
-	executor.Register(&SubnetConnectivityTest{})
-	executor.Register(&RouteTableTest{})
+	for _, fn := range builtins {
+		if planFn, ok := fn.(PlanTestFunction); ok {
+			executor.RegisterPlan(planFn)
+		} else {
+			executor.Register(fn.(TestFunction))
+		}
+	}
 	
 	return executor
 }
@@ -47,44 +102,295 @@ func (te *TestExecutor) Register(fn TestFunction) {
 	te.functions[fn.Name()] = fn
 }
 
-// Execute runs a test function by name
-func (te *TestExecutor) Execute(ctx context.Context, testName string, tfOutputs map[string]interface{}) (TestResult, error) {
-	fn, exists := te.functions[testName]
-	if !exists {
+// RegisterPlan adds a plan test function to the executor
+func (te *TestExecutor) RegisterPlan(fn PlanTestFunction) {
+	te.planFunctions[fn.Name()] = fn
+}
+
+// Split separates test function entries into plan-phase and apply-phase functions.
+// Unknown names are returned with the apply phase, where they are reported as not found.
+func (te *TestExecutor) Split(refs []yaml.TestFunctionRef) (planRefs, applyRefs []yaml.TestFunctionRef) {
+	for _, ref := range refs {
+		if _, ok := te.planFunctions[ref.Name]; ok {
+			planRefs = append(planRefs, ref)
+		} else {
+			applyRefs = append(applyRefs, ref)
+		}
+	}
+	return planRefs, applyRefs
+}
+
+// Validate checks that every test function of a test case is registered and
+// that its params decode into what the function accepts
+func (te *TestExecutor) Validate(tc *yaml.TestCase) error {
+	verr := &yaml.ValidationError{File: tc.Source}
+	for _, ref := range tc.TestFunctions {
+		_, errs := te.configure(ref)
+		verr.Errors = append(verr.Errors, errs...)
+	}
+	if len(verr.Errors) > 0 {
+		return verr
+	}
+	return nil
+}
+
+// lookup returns the registered function for a name
+func (te *TestExecutor) lookup(name string) (Function, bool) {
+	if fn, ok := te.planFunctions[name]; ok {
+		return fn, true
+	}
+	fn, ok := te.functions[name]
+	return fn, ok
+}
+
+// configure returns the function an entry refers to, set up with the entry's params
+func (te *TestExecutor) configure(ref yaml.TestFunctionRef) (Function, []yaml.FieldError) {
+	fn, ok := te.lookup(ref.Name)
+	if !ok {
+		return nil, []yaml.FieldError{ref.Errorf("unknown test function %q, available: %s",
+			ref.Name, strings.Join(te.ListAvailable(), ", "))}
+	}
+
+	configurable, ok := fn.(Configurable)
+	if !ok {
+		if ref.HasParams() {
+			return nil, []yaml.FieldError{ref.Errorf("test function %q accepts no params", ref.Name)}
+		}
+		return fn, nil
+	}
+
+	// Configurable functions always get params, so defaults apply without a params entry
+	params := configurable.NewParams()
+	if errs := ref.DecodeParams(params); len(errs) > 0 {
+		return nil, errs
+	}
+	if v, ok := params.(ParamsValidator); ok {
+		if err := v.Validate(); err != nil {
+			return nil, []yaml.FieldError{ref.Errorf("invalid params: %v", err)}
+		}
+	}
+	return configurable.WithParams(params), nil
+}
+
+// Execute runs the test function an entry refers to
+func (te *TestExecutor) Execute(ctx context.Context, ref yaml.TestFunctionRef, tfOutputs map[string]interface{}) (TestResult, error) {
+	configured, errs := te.configure(ref)
+	fn, isTest := configured.(TestFunction)
+	if len(errs) > 0 || !isTest {
+		message := "Test function not found"
+		if len(errs) > 0 {
+			message = errs[0].Msg
+		}
+		return TestResult{
+			Success:   false,
+			Message:   message,
+			TestName:  ref.Name,
+			Timestamp: time.Now(),
+		}, nil
+	}
+	
+	start := time.Now()
+	result := te.executeAttempts(ctx, ref, fn, tfOutputs)
+	result.Duration = time.Since(start)
+	result.TestName = ref.Name
+	result.Timestamp = time.Now()
+	
+	return result, nil
+}
+
+// executeAttempts runs fn once, plus up to ref.Retries more times while it
+// fails. Each attempt is bounded by ref.Timeout; with retries, every attempt
+// is recorded in the result details.
+func (te *TestExecutor) executeAttempts(ctx context.Context, ref yaml.TestFunctionRef, fn TestFunction, tfOutputs map[string]interface{}) TestResult {
+	var attempts []Attempt
+	for {
+		start := time.Now()
+		attemptCtx, cancel := withTimeout(ctx, ref.Timeout)
+		result := executeWithDeadline(attemptCtx, fn, tfOutputs)
+		cancel()
+
+		if ref.Retries == 0 {
+			return result
+		}
+		attempts = append(attempts, Attempt{
+			Attempt:  len(attempts) + 1,
+			Success:  result.Success,
+			Message:  result.Message,
+			Duration: time.Since(start),
+			Time:     start,
+		})
+		if result.Success || len(attempts) > ref.Retries || !sleep(ctx, ref.RetryInterval) {
+			return withAttempts(result, attempts)
+		}
+	}
+}
+
+// Serial is implemented by test functions that must not run alongside others
+type Serial interface {
+	Serial() bool
+}
+
+// ExecuteOptions control how ExecuteAll runs a list of test functions
+type ExecuteOptions struct {
+	// Concurrency is how many test functions run at once; below 1 means 1
+	Concurrency int
+	// Timeout is the deadline of each test function's context; zero means none
+	Timeout time.Duration
+	// FailFast cancels the remaining test functions after the first failure
+	FailFast bool
+}
+
+// ExecuteAll runs the test functions on a pool of opts.Concurrency workers and
+// returns their results in the order of refs. Functions that declare
+// themselves Serial run alone. With FailFast, the first failure cancels the
+// functions still running and the rest are reported as not run, as they are
+// when ctx is cancelled.
+func (te *TestExecutor) ExecuteAll(ctx context.Context, refs []yaml.TestFunctionRef, tfOutputs map[string]interface{}, opts ExecuteOptions) []TestResult {
+	workers := opts.Concurrency
+	if workers < 1 {
+		workers = 1
+	}
+	if workers > len(refs) {
+		workers = len(refs)
+	}
+
+	ctx, cancel := context.WithCancelCause(ctx)
+	defer cancel(nil)
+
+	results := make([]TestResult, len(refs))
+	indexes := make(chan int)
+	// Parallel functions share the lock, serial ones hold it exclusively
+	var exclusive sync.RWMutex
+	var failed sync.Once
+	var wg sync.WaitGroup
+
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range indexes {
+				ref := refs[i]
+				if te.isSerial(ref) {
+					exclusive.Lock()
+				} else {
+					exclusive.RLock()
+				}
+
+				if ctx.Err() != nil {
+					results[i] = notRun(ref, skipReason(context.Cause(ctx)))
+				} else {
+					testCtx, cancelTest := withTimeout(ctx, opts.Timeout)
+					results[i], _ = te.Execute(testCtx, ref, tfOutputs)
+					cancelTest()
+					if !results[i].Success && opts.FailFast {
+						failed.Do(func() { cancel(errFailedFast) })
+					}
+				}
+
+				if te.isSerial(ref) {
+					exclusive.Unlock()
+				} else {
+					exclusive.RUnlock()
+				}
+			}
+		}()
+	}
+
+	for i := range refs {
+		indexes <- i
+	}
+	close(indexes)
+	wg.Wait()
+
+	return results
+}
+
+// isSerial reports whether the function an entry refers to must run alone
+func (te *TestExecutor) isSerial(ref yaml.TestFunctionRef) bool {
+	fn, ok := te.lookup(ref.Name)
+	if !ok {
+		return false
+	}
+	serial, ok := fn.(Serial)
+	return ok && serial.Serial()
+}
+
+// errFailedFast is the cause of a run cancelled by FailFast
+var errFailedFast = errors.New("an earlier test function failed")
+
+// skipReason explains why a test function was not run
+func skipReason(cause error) string {
+	if errors.Is(cause, errFailedFast) {
+		return "Not run: " + cause.Error()
+	}
+	return fmt.Sprintf("Not run: the run was stopped (%v)", cause)
+}
+
+// notRun is the result of a test function that was skipped
+func notRun(ref yaml.TestFunctionRef, message string) TestResult {
+	return TestResult{
+		Success:   false,
+		Message:   message,
+		TestName:  ref.Name,
+		Timestamp: time.Now(),
+	}
+}
+
+// withTimeout bounds ctx by timeout when one is set
+func withTimeout(ctx context.Context, timeout time.Duration) (context.Context, context.CancelFunc) {
+	if timeout <= 0 {
+		return context.WithCancel(ctx)
+	}
+	return context.WithTimeout(ctx, timeout)
+}
+
+// ExecutePlan runs the plan test function an entry refers to
+func (te *TestExecutor) ExecutePlan(ctx context.Context, ref yaml.TestFunctionRef, plan *terraform.Plan, tfvars map[string]interface{}) (TestResult, error) {
+	configured, errs := te.configure(ref)
+	fn, isPlan := configured.(PlanTestFunction)
+	if len(errs) > 0 || !isPlan {
+		message := "Plan test function not found"
+		if len(errs) > 0 {
+			message = errs[0].Msg
+		}
 		return TestResult{
 			Success:   false,
-			Message:   "Test function not found",
-			TestName:  testName,
+			Message:   message,
+			TestName:  ref.Name,
 			Timestamp: time.Now(),
 		}, nil
 	}
 	
 	start := time.Now()
-	result := fn.Execute(ctx, tfOutputs)
+	result := fn.ExecutePlan(ctx, plan, tfvars)
 	result.Duration = time.Since(start)
-	result.TestName = testName
+	result.TestName = ref.Name
 	result.Timestamp = time.Now()
 	
 	return result, nil
 }
 
-// ExecuteAll runs all specified test functions
-func (te *TestExecutor) ExecuteAll(ctx context.Context, testNames []string, tfOutputs map[string]interface{}) []TestResult {
-	results := make([]TestResult, 0, len(testNames))
+// ExecutePlanAll runs all specified plan test functions
+func (te *TestExecutor) ExecutePlanAll(ctx context.Context, refs []yaml.TestFunctionRef, plan *terraform.Plan, tfvars map[string]interface{}) []TestResult {
+	results := make([]TestResult, 0, len(refs))
 	
-	for _, testName := range testNames {
-		result, _ := te.Execute(ctx, testName, tfOutputs)
+	for _, ref := range refs {
+		result, _ := te.ExecutePlan(ctx, ref, plan, tfvars)
 		results = append(results, result)
 	}
 	
 	return results
 }
 
-// ListAvailable returns names of all registered test functions
+// ListAvailable returns names of all registered test functions, sorted
 func (te *TestExecutor) ListAvailable() []string {
-	names := make([]string, 0, len(te.functions))
+	names := make([]string, 0, len(te.functions)+len(te.planFunctions))
 	for name := range te.functions {
 		names = append(names, name)
 	}
+	for name := range te.planFunctions {
+		names = append(names, name)
+	}
+	sort.Strings(names)
 	return names
 }
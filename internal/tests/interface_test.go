@@ -0,0 +1,243 @@
+package tests
+
+import (
+	"context"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"qa-test-app/internal/yaml"
+)
+
+// stubFunction is a test function whose behaviour is set by the test
+type stubFunction struct {
+	name   string
+	serial bool
+	run    func(ctx context.Context) TestResult
+	calls  atomic.Int32
+}
+
+func (s *stubFunction) Name() string        { return s.name }
+func (s *stubFunction) Description() string { return "stub" }
+func (s *stubFunction) Serial() bool        { return s.serial }
+
+func (s *stubFunction) Execute(ctx context.Context, tfOutputs map[string]interface{}) TestResult {
+	s.calls.Add(1)
+	return s.run(ctx)
+}
+
+func passing(ctx context.Context) TestResult { return TestResult{Success: true, Message: "ok"} }
+func failing(ctx context.Context) TestResult { return TestResult{Message: "broken"} }
+
+// sleeping passes after d unless ctx is done first
+func sleeping(d time.Duration) func(ctx context.Context) TestResult {
+	return func(ctx context.Context) TestResult {
+		if !sleep(ctx, d) {
+			return TestResult{Message: ctx.Err().Error()}
+		}
+		return TestResult{Success: true}
+	}
+}
+
+// executorWith registers the stubs and returns entries referring to them, in order
+func executorWith(stubs ...*stubFunction) (*TestExecutor, []yaml.TestFunctionRef) {
+	executor := NewTestExecutor()
+	var refs []yaml.TestFunctionRef
+	for _, stub := range stubs {
+		executor.Register(stub)
+		refs = append(refs, yaml.TestFunctionRef{Name: stub.name})
+	}
+	return executor, refs
+}
+
+func TestExecuteAllKeepsOrder(t *testing.T) {
+	// Later functions finish first
+	executor, refs := executorWith(
+		&stubFunction{name: "slow", run: sleeping(30 * time.Millisecond)},
+		&stubFunction{name: "medium", run: sleeping(15 * time.Millisecond)},
+		&stubFunction{name: "fast", run: passing},
+	)
+	results := executor.ExecuteAll(context.Background(), refs, nil, ExecuteOptions{Concurrency: 3})
+
+	if len(results) != len(refs) {
+		t.Fatalf("got %d results, want %d", len(results), len(refs))
+	}
+	for i, result := range results {
+		if result.TestName != refs[i].Name || !result.Success {
+			t.Errorf("result %d = %s (success %v), want %s to pass", i, result.TestName, result.Success, refs[i].Name)
+		}
+	}
+}
+
+// overlap records whether a serial function ran alongside another one
+type overlap struct {
+	mu      sync.Mutex
+	running int
+	serial  bool
+	peak    int
+	seen    bool
+}
+
+func (o *overlap) track(serial bool) func(ctx context.Context) TestResult {
+	return func(ctx context.Context) TestResult {
+		o.mu.Lock()
+		if o.serial || (serial && o.running > 0) {
+			o.seen = true
+		}
+		o.running++
+		o.serial = serial
+		if o.running > o.peak {
+			o.peak = o.running
+		}
+		o.mu.Unlock()
+
+		sleep(ctx, 20*time.Millisecond)
+
+		o.mu.Lock()
+		o.running--
+		o.serial = false
+		o.mu.Unlock()
+		return TestResult{Success: true}
+	}
+}
+
+func TestExecuteAllRunsSerialFunctionsAlone(t *testing.T) {
+	o := &overlap{}
+	executor, refs := executorWith(
+		&stubFunction{name: "p1", run: o.track(false)},
+		&stubFunction{name: "p2", run: o.track(false)},
+		&stubFunction{name: "serial", serial: true, run: o.track(true)},
+		&stubFunction{name: "p3", run: o.track(false)},
+		&stubFunction{name: "p4", run: o.track(false)},
+	)
+	results := executor.ExecuteAll(context.Background(), refs, nil, ExecuteOptions{Concurrency: 4})
+
+	for _, result := range results {
+		if !result.Success {
+			t.Errorf("%s failed: %s", result.TestName, result.Message)
+		}
+	}
+	if o.seen {
+		t.Error("the serial function ran alongside another function")
+	}
+	if o.peak < 2 {
+		t.Errorf("at most %d function(s) ran at once, want the parallel ones to overlap", o.peak)
+	}
+}
+
+func TestExecuteAllFailFast(t *testing.T) {
+	last := &stubFunction{name: "last", run: passing}
+	executor, refs := executorWith(
+		&stubFunction{name: "first", run: passing},
+		&stubFunction{name: "broken", run: failing},
+		last,
+	)
+
+	results := executor.ExecuteAll(context.Background(), refs, nil, ExecuteOptions{Concurrency: 1, FailFast: true})
+	if !results[0].Success || results[1].Success {
+		t.Fatalf("results = %+v, want first to pass and broken to fail", results)
+	}
+	if results[2].Success || results[2].Message != "Not run: an earlier test function failed" {
+		t.Errorf("last = %q, want it skipped after the failure", results[2].Message)
+	}
+	if last.calls.Load() != 0 {
+		t.Error("last ran after the failure")
+	}
+
+	// Without fail-fast every function runs
+	results = executor.ExecuteAll(context.Background(), refs, nil, ExecuteOptions{Concurrency: 1})
+	if !results[2].Success || last.calls.Load() != 1 {
+		t.Errorf("last = %q, want it to run without fail-fast", results[2].Message)
+	}
+}
+
+func TestExecuteAllCancelled(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	// The first function stands in for Ctrl-C arriving while it runs
+	executor, refs := executorWith(
+		&stubFunction{name: "interrupted", run: func(context.Context) TestResult {
+			cancel()
+			return TestResult{Success: true}
+		}},
+		&stubFunction{name: "skipped", run: passing},
+	)
+
+	results := executor.ExecuteAll(ctx, refs, nil, ExecuteOptions{Concurrency: 1, FailFast: true})
+	message := results[1].Message
+	if results[1].Success || !strings.HasPrefix(message, "Not run: the run was stopped") {
+		t.Errorf("skipped = %q, want it reported as stopped", message)
+	}
+	if strings.Contains(message, "failed") {
+		t.Errorf("skipped = %q, want no mention of a failure", message)
+	}
+}
+
+func TestExecuteAllTimeout(t *testing.T) {
+	// The timeout applies to each function, not to the whole list
+	executor, refs := executorWith(
+		&stubFunction{name: "a", run: sleeping(20 * time.Millisecond)},
+		&stubFunction{name: "b", run: sleeping(20 * time.Millisecond)},
+		&stubFunction{name: "hangs", run: sleeping(time.Minute)},
+	)
+	results := executor.ExecuteAll(context.Background(), refs, nil, ExecuteOptions{Concurrency: 1, Timeout: 30 * time.Millisecond})
+
+	if !results[0].Success || !results[1].Success {
+		t.Errorf("results = %+v, want a and b to finish within their own timeout", results[:2])
+	}
+	if results[2].Success || !strings.HasPrefix(results[2].Message, "Timed out after") {
+		t.Errorf("hangs = %q, want a timeout", results[2].Message)
+	}
+}
+
+func TestExecuteAllRetries(t *testing.T) {
+	flaky := &stubFunction{name: "flaky"}
+	flaky.run = func(context.Context) TestResult {
+		if flaky.calls.Load() < 3 {
+			return TestResult{Message: "not yet"}
+		}
+		return TestResult{Success: true}
+	}
+	broken := &stubFunction{name: "broken", run: failing}
+	executor, refs := executorWith(flaky, broken)
+	for i := range refs {
+		refs[i].Retries = 3
+		refs[i].RetryInterval = time.Millisecond
+	}
+	refs[1].Retries = 1
+
+	results := executor.ExecuteAll(context.Background(), refs, nil, ExecuteOptions{Concurrency: 2})
+
+	if !results[0].Success {
+		t.Errorf("flaky = %q, want it to pass on the third attempt", results[0].Message)
+	}
+	if attempts := results[0].Details["attempts"].([]Attempt); len(attempts) != 3 || attempts[0].Success {
+		t.Errorf("flaky attempts = %+v, want two failures and a success", attempts)
+	}
+	if results[1].Success || broken.calls.Load() != 2 {
+		t.Errorf("broken ran %d time(s) and passed %v, want 2 failing attempts", broken.calls.Load(), results[1].Success)
+	}
+}
+
+func TestExecuteAllRetryTimeout(t *testing.T) {
+	// A function's own timeout bounds each attempt
+	slow := &stubFunction{name: "slow"}
+	slow.run = func(ctx context.Context) TestResult {
+		if slow.calls.Load() == 1 {
+			return sleeping(time.Minute)(ctx)
+		}
+		return TestResult{Success: true}
+	}
+	executor, refs := executorWith(slow)
+	refs[0].Timeout = 20 * time.Millisecond
+	refs[0].Retries = 1
+	refs[0].RetryInterval = time.Millisecond
+
+	result := executor.ExecuteAll(context.Background(), refs, nil, ExecuteOptions{})[0]
+	attempts := result.Details["attempts"].([]Attempt)
+	if !result.Success || len(attempts) != 2 || !strings.HasPrefix(attempts[0].Message, "Timed out after") {
+		t.Errorf("result = %q with attempts %+v, want the first attempt to time out and the retry to pass", result.Message, attempts)
+	}
+}
@@ -0,0 +1,345 @@
+package tests
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"sort"
+	"strings"
+
+	"qa-test-app/internal/network"
+	"qa-test-app/internal/terraform"
+)
+
+func init() {
+	RegisterBuiltin(&PlanCIDRTest{})
+	RegisterBuiltin(&PlanSubnetAZTest{})
+	RegisterBuiltin(&PlanTagsTest{})
+}
+
+// PlanCIDRTest checks that every planned subnet lies inside its VPC's CIDR block
+type PlanCIDRTest struct{}
+
+func (t *PlanCIDRTest) Name() string {
+	return "validate_plan_cidrs"
+}
+
+func (t *PlanCIDRTest) Description() string {
+	return "Validates that planned subnet CIDRs are contained in the VPC CIDR"
+}
+
+func (t *PlanCIDRTest) ExecutePlan(ctx context.Context, plan *terraform.Plan, tfvars map[string]interface{}) TestResult {
+	// VPC CIDRs by module, so subnets are checked against the VPC they belong to
+	vpcs := make(map[string]*net.IPNet)
+	for _, rc := range plan.ResourceChangesByType("aws_vpc") {
+		cidr, ok := rc.Change.AfterValues()["cidr_block"].(string)
+		if !ok {
+			continue
+		}
+		_, vpcNet, err := net.ParseCIDR(cidr)
+		if err != nil {
+			return TestResult{
+				Success: false,
+				Message: fmt.Sprintf("Invalid VPC CIDR in %s: %v", rc.Address, err),
+			}
+		}
+		vpcs[rc.ModuleAddress] = vpcNet
+	}
+
+	var fallback *net.IPNet
+	if cidr, ok := tfvars["vpc_cidr"].(string); ok {
+		_, fallback, _ = net.ParseCIDR(cidr)
+	}
+	if len(vpcs) == 0 && fallback == nil {
+		return TestResult{
+			Success: false,
+			Message: "No VPC CIDR block found in plan or tfvars",
+		}
+	}
+
+	var checks, violations, unknown []string
+	for _, rc := range plan.ResourceChangesByType("aws_subnet") {
+		if rc.Change.Actions.Delete() {
+			continue
+		}
+		cidr, ok := rc.Change.AfterValues()["cidr_block"].(string)
+		if !ok {
+			unknown = append(unknown, rc.Address)
+			continue
+		}
+		_, subnet, err := net.ParseCIDR(cidr)
+		if err != nil {
+			violations = append(violations, fmt.Sprintf("%s: invalid CIDR %q", rc.Address, cidr))
+			continue
+		}
+
+		vpcNet, ok := vpcs[rc.ModuleAddress]
+		if !ok {
+			vpcNet = fallback
+		}
+		if vpcNet == nil {
+			unknown = append(unknown, rc.Address)
+			continue
+		}
+		if !network.Contains(vpcNet, subnet) {
+			violations = append(violations, fmt.Sprintf("%s: %s is outside VPC CIDR %s", rc.Address, subnet, vpcNet))
+			continue
+		}
+		checks = append(checks, fmt.Sprintf("%s: %s is inside %s", rc.Address, subnet, vpcNet))
+	}
+
+	details := map[string]interface{}{
+		"checks": checks,
+	}
+	if len(unknown) > 0 {
+		details["unknown_until_apply"] = unknown
+	}
+	if len(violations) > 0 {
+		details["violations"] = violations
+		return TestResult{
+			Success: false,
+			Message: fmt.Sprintf("%d subnet(s) outside the VPC CIDR", len(violations)),
+			Details: details,
+		}
+	}
+
+	return TestResult{
+		Success: true,
+		Message: fmt.Sprintf("All %d planned subnet CIDR(s) are inside the VPC CIDR", len(checks)),
+		Details: details,
+	}
+}
+
+// PlanSubnetAZTest checks that each group of planned subnets puts one subnet in every availability zone
+type PlanSubnetAZTest struct{}
+
+func (t *PlanSubnetAZTest) Name() string {
+	return "verify_plan_subnet_azs"
+}
+
+func (t *PlanSubnetAZTest) Description() string {
+	return "Verifies planned subnet counts and placement against the availability zones"
+}
+
+func (t *PlanSubnetAZTest) ExecutePlan(ctx context.Context, plan *terraform.Plan, tfvars map[string]interface{}) TestResult {
+	zones, ok := stringList(tfvars["availability_zones"])
+	if !ok || len(zones) == 0 {
+		return TestResult{
+			Success: false,
+			Message: "availability_zones not found in tfvars",
+		}
+	}
+	allowed := make(map[string]bool, len(zones))
+	for _, zone := range zones {
+		allowed[zone] = true
+	}
+
+	// Group subnet instances by resource, e.g. module.vpc.aws_subnet.public
+	groups := make(map[string][]terraform.ResourceChange)
+	for _, rc := range plan.ResourceChangesByType("aws_subnet") {
+		if rc.Change.Actions.Delete() {
+			continue
+		}
+		group := strings.TrimPrefix(rc.ModuleAddress+"."+rc.Type+"."+rc.Name, ".")
+		groups[group] = append(groups[group], rc)
+	}
+	if len(groups) == 0 {
+		return TestResult{
+			Success: false,
+			Message: "No subnets found in plan",
+		}
+	}
+
+	var checks, violations, unknown []string
+	names := make([]string, 0, len(groups))
+	for name := range groups {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		subnets := groups[name]
+		if len(subnets) != len(zones) {
+			violations = append(violations, fmt.Sprintf("%s: %d subnet(s) for %d availability zone(s)",
+				name, len(subnets), len(zones)))
+		}
+
+		used := make(map[string]string)
+		for _, rc := range subnets {
+			zone, ok := rc.Change.AfterValues()["availability_zone"].(string)
+			if !ok {
+				unknown = append(unknown, rc.Address)
+				continue
+			}
+			if !allowed[zone] {
+				violations = append(violations, fmt.Sprintf("%s: availability zone %s is not in %v",
+					rc.Address, zone, zones))
+			}
+			if other, dup := used[zone]; dup {
+				violations = append(violations, fmt.Sprintf("%s: shares availability zone %s with %s",
+					rc.Address, zone, other))
+			}
+			used[zone] = rc.Address
+		}
+		checks = append(checks, fmt.Sprintf("%s: %d subnet(s) across %d zone(s)", name, len(subnets), len(used)))
+	}
+
+	details := map[string]interface{}{
+		"availability_zones": zones,
+		"checks":             checks,
+	}
+	if len(unknown) > 0 {
+		details["unknown_until_apply"] = unknown
+	}
+	if len(violations) > 0 {
+		details["violations"] = violations
+		return TestResult{
+			Success: false,
+			Message: fmt.Sprintf("%d subnet placement problem(s)", len(violations)),
+			Details: details,
+		}
+	}
+
+	return TestResult{
+		Success: true,
+		Message: fmt.Sprintf("Every subnet group covers all %d availability zone(s)", len(zones)),
+		Details: details,
+	}
+}
+
+// PlanTagsTest checks that every taggable planned resource carries the common tags and a Name
+type PlanTagsTest struct {
+	Params PlanTagsParams
+}
+
+// PlanTagsParams configures verify_plan_tags
+type PlanTagsParams struct {
+	// RequiredTags are required in addition to Name and the keys of common_tags
+	RequiredTags []string `yaml:"required_tags"`
+	// ResourceTypes limits the check to these resource types; empty checks every type
+	ResourceTypes []string `yaml:"resource_types"`
+}
+
+func (t *PlanTagsTest) NewParams() interface{} {
+	return &PlanTagsParams{}
+}
+
+func (t *PlanTagsTest) WithParams(params interface{}) Function {
+	return &PlanTagsTest{Params: *params.(*PlanTagsParams)}
+}
+
+func (t *PlanTagsTest) Name() string {
+	return "verify_plan_tags"
+}
+
+func (t *PlanTagsTest) Description() string {
+	return "Verifies planned resources carry the Name tag and every key of common_tags"
+}
+
+func (t *PlanTagsTest) ExecutePlan(ctx context.Context, plan *terraform.Plan, tfvars map[string]interface{}) TestResult {
+	seen := map[string]bool{"Name": true}
+	var extra []string
+	for _, key := range t.Params.RequiredTags {
+		if !seen[key] {
+			seen[key] = true
+			extra = append(extra, key)
+		}
+	}
+	if common, ok := tfvars["common_tags"].(map[string]interface{}); ok {
+		for key := range common {
+			if !seen[key] {
+				seen[key] = true
+				extra = append(extra, key)
+			}
+		}
+	}
+	sort.Strings(extra)
+	required := append([]string{"Name"}, extra...)
+
+	types := make(map[string]bool, len(t.Params.ResourceTypes))
+	for _, resourceType := range t.Params.ResourceTypes {
+		types[resourceType] = true
+	}
+
+	var checked int
+	var violations, unknown []string
+	for _, rc := range plan.Changes() {
+		if rc.Change.Actions.Delete() || (len(types) > 0 && !types[rc.Type]) {
+			continue
+		}
+		after := rc.Change.AfterValues()
+		tags, taggable := after["tags"]
+		if !taggable {
+			if isUnknown(rc.Change, "tags") {
+				unknown = append(unknown, rc.Address)
+			}
+			continue
+		}
+
+		checked++
+		// A resource that supports tags but sets none plans tags = null
+		if tags == nil {
+			violations = append(violations, fmt.Sprintf("%s: no tags, missing %s", rc.Address, strings.Join(required, ", ")))
+			continue
+		}
+		tagMap, _ := tags.(map[string]interface{})
+		var missing []string
+		for _, key := range required {
+			if _, ok := tagMap[key]; !ok {
+				missing = append(missing, key)
+			}
+		}
+		if len(missing) > 0 {
+			violations = append(violations, fmt.Sprintf("%s: missing %s", rc.Address, strings.Join(missing, ", ")))
+		}
+	}
+
+	details := map[string]interface{}{
+		"required_tags": required,
+		"checked":       checked,
+	}
+	if len(unknown) > 0 {
+		details["unknown_until_apply"] = unknown
+	}
+	if len(violations) > 0 {
+		details["violations"] = violations
+		return TestResult{
+			Success: false,
+			Message: fmt.Sprintf("%d resource(s) missing required tags", len(violations)),
+			Details: details,
+		}
+	}
+
+	return TestResult{
+		Success: true,
+		Message: fmt.Sprintf("All %d tagged resource(s) carry the required tags", checked),
+		Details: details,
+	}
+}
+
+// isUnknown reports whether a top-level attribute is only known after apply
+func isUnknown(change terraform.Change, attr string) bool {
+	for _, key := range change.AfterUnknownKeys() {
+		if key == attr {
+			return true
+		}
+	}
+	return false
+}
+
+// stringList converts a decoded JSON or YAML list of strings
+func stringList(value interface{}) ([]string, bool) {
+	items, ok := value.([]interface{})
+	if !ok {
+		return nil, false
+	}
+	list := make([]string, 0, len(items))
+	for _, item := range items {
+		s, ok := item.(string)
+		if !ok {
+			return nil, false
+		}
+		list = append(list, s)
+	}
+	return list, true
+}
@@ -0,0 +1,220 @@
+package tests
+
+import (
+	"context"
+	"os"
+	"reflect"
+	"testing"
+
+	"qa-test-app/internal/terraform"
+)
+
+// planTfvars are the tfvars testdata/plan.json was planned with
+func planTfvars() map[string]interface{} {
+	return map[string]interface{}{
+		"vpc_cidr":           "10.0.0.0/16",
+		"availability_zones": []interface{}{"eu-north-1a", "eu-north-1b"},
+		"common_tags":        map[string]interface{}{"Environment": "qa", "Owner": "ops"},
+	}
+}
+
+// loadPlan parses testdata/plan.json, a VPC with two public and two private subnets
+func loadPlan(t *testing.T) *terraform.Plan {
+	t.Helper()
+	data, err := os.ReadFile("testdata/plan.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	plan, err := terraform.ParsePlan(data)
+	if err != nil {
+		t.Fatalf("ParsePlan: %v", err)
+	}
+	return plan
+}
+
+// after returns the planned attributes of address for a test to change
+func after(t *testing.T, plan *terraform.Plan, address string) map[string]interface{} {
+	t.Helper()
+	rc, ok := plan.ResourceChange(address)
+	if !ok {
+		t.Fatalf("%s not in the plan", address)
+	}
+	return rc.Change.AfterValues()
+}
+
+// unknownAfterApply makes attr of address known only after apply
+func unknownAfterApply(t *testing.T, plan *terraform.Plan, address, attr string) {
+	t.Helper()
+	delete(after(t, plan, address), attr)
+	rc, _ := plan.ResourceChange(address)
+	rc.Change.AfterUnknown.(map[string]interface{})[attr] = true
+}
+
+// withoutChange removes address from the plan
+func withoutChange(plan *terraform.Plan, address string) {
+	var changes []terraform.ResourceChange
+	for _, rc := range plan.ResourceChanges {
+		if rc.Address != address {
+			changes = append(changes, rc)
+		}
+	}
+	plan.ResourceChanges = changes
+}
+
+func runPlanCheck(fn Function, plan *terraform.Plan, tfvars map[string]interface{}) TestResult {
+	return fn.(PlanTestFunction).ExecutePlan(context.Background(), plan, tfvars)
+}
+
+// assertUnknown fails the test unless the result lists exactly addresses as unknown until apply
+func assertUnknown(t *testing.T, result TestResult, addresses ...string) {
+	t.Helper()
+	if got, _ := result.Details["unknown_until_apply"].([]string); !reflect.DeepEqual(got, addresses) {
+		t.Errorf("unknown_until_apply = %q, want %q", got, addresses)
+	}
+}
+
+func TestPlanCIDRs(t *testing.T) {
+	t.Run("inside the VPC", func(t *testing.T) {
+		result := runPlanCheck(&PlanCIDRTest{}, loadPlan(t), planTfvars())
+		assertPasses(t, result)
+		if checks, _ := result.Details["checks"].([]string); len(checks) != 4 {
+			t.Errorf("checks = %q, want the 4 subnets", checks)
+		}
+		assertUnknown(t, result)
+	})
+
+	t.Run("outside the VPC", func(t *testing.T) {
+		plan := loadPlan(t)
+		after(t, plan, "module.vpc.aws_subnet.private[1]")["cidr_block"] = "10.1.2.0/24"
+		assertFails(t, runPlanCheck(&PlanCIDRTest{}, plan, planTfvars()), "violations",
+			"module.vpc.aws_subnet.private[1]: 10.1.2.0/24 is outside VPC CIDR 10.0.0.0/16")
+	})
+
+	t.Run("invalid subnet CIDR", func(t *testing.T) {
+		plan := loadPlan(t)
+		after(t, plan, "module.vpc.aws_subnet.public[0]")["cidr_block"] = "10.0.300.0/24"
+		assertFails(t, runPlanCheck(&PlanCIDRTest{}, plan, planTfvars()), "violations",
+			`module.vpc.aws_subnet.public[0]: invalid CIDR "10.0.300.0/24"`)
+	})
+
+	t.Run("unknown until apply", func(t *testing.T) {
+		plan := loadPlan(t)
+		unknownAfterApply(t, plan, "module.vpc.aws_subnet.private[0]", "cidr_block")
+		result := runPlanCheck(&PlanCIDRTest{}, plan, planTfvars())
+		assertPasses(t, result)
+		assertUnknown(t, result, "module.vpc.aws_subnet.private[0]")
+	})
+
+	t.Run("VPC CIDR from tfvars", func(t *testing.T) {
+		plan := loadPlan(t)
+		unknownAfterApply(t, plan, "module.vpc.aws_vpc.this", "cidr_block")
+		tfvars := planTfvars()
+		tfvars["vpc_cidr"] = "10.0.0.0/20"
+		assertFails(t, runPlanCheck(&PlanCIDRTest{}, plan, tfvars), "violations",
+			"module.vpc.aws_subnet.public[0]: 10.0.101.0/24 is outside VPC CIDR 10.0.0.0/20")
+	})
+
+	t.Run("no VPC CIDR", func(t *testing.T) {
+		plan := loadPlan(t)
+		withoutChange(plan, "module.vpc.aws_vpc.this")
+		result := runPlanCheck(&PlanCIDRTest{}, plan, map[string]interface{}{})
+		if result.Success || result.Message != "No VPC CIDR block found in plan or tfvars" {
+			t.Errorf("result = %+v, want no VPC CIDR found", result)
+		}
+	})
+}
+
+func TestPlanSubnetAZs(t *testing.T) {
+	t.Run("one subnet per zone", func(t *testing.T) {
+		result := runPlanCheck(&PlanSubnetAZTest{}, loadPlan(t), planTfvars())
+		assertPasses(t, result)
+		want := []string{
+			"module.vpc.aws_subnet.private: 2 subnet(s) across 2 zone(s)",
+			"module.vpc.aws_subnet.public: 2 subnet(s) across 2 zone(s)",
+		}
+		if checks := result.Details["checks"]; !reflect.DeepEqual(checks, want) {
+			t.Errorf("checks = %q, want %q", checks, want)
+		}
+	})
+
+	t.Run("shared zone", func(t *testing.T) {
+		plan := loadPlan(t)
+		after(t, plan, "module.vpc.aws_subnet.public[1]")["availability_zone"] = "eu-north-1a"
+		assertFails(t, runPlanCheck(&PlanSubnetAZTest{}, plan, planTfvars()), "violations",
+			"module.vpc.aws_subnet.public[1]: shares availability zone eu-north-1a with module.vpc.aws_subnet.public[0]")
+	})
+
+	t.Run("zone not in tfvars", func(t *testing.T) {
+		plan := loadPlan(t)
+		after(t, plan, "module.vpc.aws_subnet.private[1]")["availability_zone"] = "eu-north-1c"
+		assertFails(t, runPlanCheck(&PlanSubnetAZTest{}, plan, planTfvars()), "violations",
+			"module.vpc.aws_subnet.private[1]: availability zone eu-north-1c is not in [eu-north-1a eu-north-1b]")
+	})
+
+	t.Run("missing subnet", func(t *testing.T) {
+		plan := loadPlan(t)
+		withoutChange(plan, "module.vpc.aws_subnet.private[1]")
+		assertFails(t, runPlanCheck(&PlanSubnetAZTest{}, plan, planTfvars()), "violations",
+			"module.vpc.aws_subnet.private: 1 subnet(s) for 2 availability zone(s)")
+	})
+
+	t.Run("unknown until apply", func(t *testing.T) {
+		plan := loadPlan(t)
+		unknownAfterApply(t, plan, "module.vpc.aws_subnet.public[1]", "availability_zone")
+		result := runPlanCheck(&PlanSubnetAZTest{}, plan, planTfvars())
+		assertPasses(t, result)
+		assertUnknown(t, result, "module.vpc.aws_subnet.public[1]")
+	})
+
+	t.Run("no availability zones", func(t *testing.T) {
+		result := runPlanCheck(&PlanSubnetAZTest{}, loadPlan(t), map[string]interface{}{})
+		if result.Success || result.Message != "availability_zones not found in tfvars" {
+			t.Errorf("result = %+v, want availability_zones not found", result)
+		}
+	})
+}
+
+func TestPlanTags(t *testing.T) {
+	t.Run("tagged", func(t *testing.T) {
+		result := runPlanCheck(&PlanTagsTest{}, loadPlan(t), planTfvars())
+		assertPasses(t, result)
+		// The route table association has no tags argument and the data source isn't a change
+		if result.Details["checked"] != 6 {
+			t.Errorf("checked = %v, want the VPC, 4 subnets and the internet gateway", result.Details["checked"])
+		}
+		if required := result.Details["required_tags"]; !reflect.DeepEqual(required, []string{"Name", "Environment", "Owner"}) {
+			t.Errorf("required_tags = %q", required)
+		}
+	})
+
+	t.Run("missing common tag", func(t *testing.T) {
+		plan := loadPlan(t)
+		delete(after(t, plan, "module.vpc.aws_internet_gateway.this")["tags"].(map[string]interface{}), "Owner")
+		assertFails(t, runPlanCheck(&PlanTagsTest{}, plan, planTfvars()), "violations",
+			"module.vpc.aws_internet_gateway.this: missing Owner")
+	})
+
+	t.Run("tags = null", func(t *testing.T) {
+		plan := loadPlan(t)
+		after(t, plan, "module.vpc.aws_internet_gateway.this")["tags"] = nil
+		assertFails(t, runPlanCheck(&PlanTagsTest{}, plan, planTfvars()), "violations",
+			"module.vpc.aws_internet_gateway.this: no tags, missing Name, Environment, Owner")
+	})
+
+	t.Run("required_tags and resource_types", func(t *testing.T) {
+		fn := (&PlanTagsTest{}).WithParams(&PlanTagsParams{RequiredTags: []string{"Team"}, ResourceTypes: []string{"aws_vpc"}})
+		result := runPlanCheck(fn, loadPlan(t), planTfvars())
+		assertFails(t, result, "violations", "module.vpc.aws_vpc.this: missing Team")
+		if violations, _ := result.Details["violations"].([]string); len(violations) != 1 {
+			t.Errorf("violations = %q, want only the VPC", violations)
+		}
+	})
+
+	t.Run("unknown until apply", func(t *testing.T) {
+		plan := loadPlan(t)
+		unknownAfterApply(t, plan, "module.vpc.aws_subnet.public[0]", "tags")
+		result := runPlanCheck(&PlanTagsTest{}, plan, planTfvars())
+		assertPasses(t, result)
+		assertUnknown(t, result, "module.vpc.aws_subnet.public[0]")
+	})
+}
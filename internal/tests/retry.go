@@ -0,0 +1,104 @@
+package tests
+
+import (
+	"context"
+	"fmt"
+	"time"
+)
+
+// DefaultPollInterval is how often Eventually checks when no interval is given
+const DefaultPollInterval = 5 * time.Second
+
+// DefaultEventuallyTimeout bounds Eventually when ctx has no deadline
+const DefaultEventuallyTimeout = 2 * time.Minute
+
+// Attempt records one run of a retried test function or one Eventually check
+type Attempt struct {
+	Attempt  int           `json:"attempt"`
+	Success  bool          `json:"success"`
+	Message  string        `json:"message"`
+	Duration time.Duration `json:"duration"`
+	Time     time.Time     `json:"time"`
+}
+
+// Eventually calls check every interval until it succeeds or ctx is done, for
+// AWS describe calls that see stale data right after apply. It returns the
+// last result with every attempt recorded in Details["attempts"]. Without a
+// deadline on ctx, polling stops after DefaultEventuallyTimeout.
+func Eventually(ctx context.Context, interval time.Duration, check func(ctx context.Context) TestResult) TestResult {
+	if interval <= 0 {
+		interval = DefaultPollInterval
+	}
+	if _, ok := ctx.Deadline(); !ok {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, DefaultEventuallyTimeout)
+		defer cancel()
+	}
+
+	var attempts []Attempt
+	for {
+		start := time.Now()
+		result := check(ctx)
+		attempts = append(attempts, Attempt{
+			Attempt:  len(attempts) + 1,
+			Success:  result.Success,
+			Message:  result.Message,
+			Duration: time.Since(start),
+			Time:     start,
+		})
+		if result.Success || !sleep(ctx, interval) {
+			if !result.Success && ctx.Err() != nil {
+				result.Message = fmt.Sprintf("%s (still failing after %d attempt(s): %v)", result.Message, len(attempts), ctx.Err())
+			}
+			return withAttempts(result, attempts)
+		}
+	}
+}
+
+// withAttempts adds the attempts to a result's details
+func withAttempts(result TestResult, attempts []Attempt) TestResult {
+	details := make(map[string]interface{}, len(result.Details)+1)
+	for key, value := range result.Details {
+		details[key] = value
+	}
+	details["attempts"] = attempts
+	result.Details = details
+	return result
+}
+
+// sleep waits for d and reports false if ctx was done first
+func sleep(ctx context.Context, d time.Duration) bool {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return true
+	case <-ctx.Done():
+		return false
+	}
+}
+
+// executeWithDeadline runs fn but returns as soon as ctx is done, so a
+// function that ignores its context can't hold up the run. The function keeps
+// running in the background until it returns on its own.
+func executeWithDeadline(ctx context.Context, fn TestFunction, tfOutputs map[string]interface{}) TestResult {
+	start := time.Now()
+	done := make(chan TestResult, 1)
+	go func() {
+		done <- fn.Execute(ctx, tfOutputs)
+	}()
+
+	select {
+	case result := <-done:
+		return result
+	case <-ctx.Done():
+		message := "Cancelled"
+		if ctx.Err() == context.DeadlineExceeded {
+			message = fmt.Sprintf("Timed out after %v", time.Since(start).Round(time.Millisecond))
+		}
+		return TestResult{
+			Success: false,
+			Message: message,
+		}
+	}
+}
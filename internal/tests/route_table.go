@@ -3,15 +3,44 @@ package tests
 import (
 	"context"
 	"fmt"
-	"strings"
+
+	"qa-test-app/internal/network"
 
 	"github.com/aws/aws-sdk-go/aws"
-	"github.com/aws/aws-sdk-go/aws/session"
-	"github.com/aws/aws-sdk-go/service/ec2"
 )
 
+func init() {
+	RegisterBuiltin(&RouteTableTest{})
+}
+
 // RouteTableTest verifies route table configuration
-type RouteTableTest struct{}
+type RouteTableTest struct {
+	Params RouteTableParams
+}
+
+// RouteTableParams configures verify_route_tables
+type RouteTableParams struct {
+	// ExpectInternetRoute requires an internet gateway route when true and forbids one when false
+	ExpectInternetRoute *bool `yaml:"expect_internet_route"`
+	MinTables           int   `yaml:"min_tables"`
+	// AllowUnassociated accepts subnets that fall back to the main route table
+	AllowUnassociated bool `yaml:"allow_unassociated"`
+}
+
+func (p *RouteTableParams) Validate() error {
+	if p.MinTables < 0 {
+		return fmt.Errorf("min_tables must not be negative")
+	}
+	return nil
+}
+
+func (t *RouteTableTest) NewParams() interface{} {
+	return &RouteTableParams{MinTables: 1}
+}
+
+func (t *RouteTableTest) WithParams(params interface{}) Function {
+	return &RouteTableTest{Params: *params.(*RouteTableParams)}
+}
 
 func (t *RouteTableTest) Name() string {
 	return "verify_route_tables"
@@ -30,72 +59,148 @@ func (t *RouteTableTest) Execute(ctx context.Context, tfOutputs map[string]inter
 		}
 	}
 
-	sess, err := session.NewSession(&aws.Config{
-		Region: aws.String("eu-north-1"),
-	})
-	if err != nil {
-		return TestResult{
-			Success: false,
-			Message: fmt.Sprintf("AWS session creation failed: %v", err),
+	// Without AWS access, fall back to what the state of the applied workspace records
+	if env := EnvironmentFrom(ctx); env.AWS == nil && env.State != nil {
+		inventory, err := network.FromState(env.State)
+		if err != nil {
+			return TestResult{
+				Success: false,
+				Message: fmt.Sprintf("Failed to model the VPC from state: %v", err),
+			}
 		}
+		return t.result(inventory.Model(vpcID), tfOutputs, "state")
 	}
 
-	ec2Svc := ec2.New(sess)
-
-	// Get route tables
-	routeTables, err := ec2Svc.DescribeRouteTables(&ec2.DescribeRouteTablesInput{
-		Filters: []*ec2.Filter{
-			{
-				Name:   aws.String("vpc-id"),
-				Values: []*string{aws.String(vpcID)},
-			},
-		},
-	})
-	if err != nil {
-		return TestResult{
-			Success: false,
-			Message: fmt.Sprintf("Failed to describe route tables: %v", err),
-		}
+	model, attempts, failed := loadNetwork(ctx, vpcID, tfOutputs)
+	if failed != nil {
+		return *failed
 	}
-
-	details := map[string]interface{}{
-		"vpc_id":           vpcID,
-		"route_table_count": len(routeTables.RouteTables),
-		"route_tables":     []map[string]interface{}{},
+	result := t.result(model, tfOutputs, "aws")
+	if len(attempts) > 1 {
+		result = withAttempts(result, attempts)
 	}
+	return result
+}
 
+// result checks the route tables found in a VPC, and the route table each
+// public and private subnet uses, against the params
+func (t *RouteTableTest) result(model *network.Model, tfOutputs map[string]interface{}, source string) TestResult {
+	var problems []string
 	routeTableInfo := []map[string]interface{}{}
 	hasInternetRoute := false
 
-	for _, rt := range routeTables.RouteTables {
-		rtInfo := map[string]interface{}{
-			"id":           *rt.RouteTableId,
-			"associations": len(rt.Associations),
-			"routes":       []string{},
-		}
-
+	for _, rt := range model.RouteTables {
+		id := aws.StringValue(rt.RouteTableId)
 		routes := []string{}
 		for _, route := range rt.Routes {
-			if route.DestinationCidrBlock != nil {
-				routeStr := *route.DestinationCidrBlock
-				if route.GatewayId != nil && strings.Contains(*route.GatewayId, "igw-") {
-					routeStr += " -> IGW"
-					hasInternetRoute = true
-				}
-				routes = append(routes, routeStr)
+			if route.DestinationCidrBlock == nil {
+				continue
+			}
+			target := network.RouteTarget(route)
+			routeStr := fmt.Sprintf("%s -> %s", *route.DestinationCidrBlock, target)
+			if network.IsBlackhole(route) {
+				routeStr += " (blackhole)"
+				problems = append(problems, fmt.Sprintf("route table %s: %s", id, routeStr))
+			} else if network.IsInternetGateway(target) {
+				hasInternetRoute = true
+			}
+			routes = append(routes, routeStr)
+		}
+
+		associations, main := 0, false
+		for _, assoc := range rt.Associations {
+			if aws.BoolValue(assoc.Main) {
+				main = true
+			} else if assoc.SubnetId != nil {
+				associations++
+			}
+		}
+		routeTableInfo = append(routeTableInfo, map[string]interface{}{
+			"id":           id,
+			"main":         main,
+			"associations": associations,
+			"routes":       routes,
+		})
+	}
+
+	// Every subnet of the VPC should say which route table it uses
+	subnetInfo := []map[string]interface{}{}
+	kinds := map[string]string{}
+	for _, kind := range []string{"public", "private"} {
+		ids, _ := stringList(tfOutputs[kind+"_subnet_ids"])
+		for _, id := range ids {
+			kinds[id] = kind
+			if _, ok := model.Subnet(id); !ok {
+				problems = append(problems, fmt.Sprintf("%s subnet %s not found in VPC %s", kind, id, model.VpcID))
 			}
 		}
-		rtInfo["routes"] = routes
-		routeTableInfo = append(routeTableInfo, rtInfo)
 	}
+	for _, subnet := range model.Subnets {
+		subnetID := aws.StringValue(subnet.SubnetId)
+		kind := kinds[subnetID]
+		info := map[string]interface{}{"id": subnetID}
+		if kind != "" {
+			info["type"] = kind
+		}
+		subnetInfo = append(subnetInfo, info)
+
+		rt, explicit := model.RouteTable(subnetID)
+		if rt == nil {
+			problems = append(problems, fmt.Sprintf("subnet %s has no route table", subnetID))
+			continue
+		}
+		info["route_table"] = aws.StringValue(rt.RouteTableId)
+		info["explicit_association"] = explicit
+		if !explicit && !t.Params.AllowUnassociated {
+			problems = append(problems, fmt.Sprintf("subnet %s is not associated with a route table and falls back to main table %s",
+				subnetID, aws.StringValue(rt.RouteTableId)))
+		}
 
-	details["route_tables"] = routeTableInfo
-	details["has_internet_route"] = hasInternetRoute
+		// The default route decides whether instances can reach the internet directly
+		defaultRoute := ""
+		igwRoute := false
+		if route := network.LookupRoute(rt, network.Internet); route != nil && !network.IsBlackhole(route) {
+			defaultRoute = network.RouteTarget(route)
+			igwRoute = network.IsInternetGateway(defaultRoute)
+			info["default_route"] = defaultRoute
+		}
+		switch {
+		case kind == "public" && !igwRoute:
+			problems = append(problems, fmt.Sprintf("public subnet %s: route table %s has no default route to an internet gateway",
+				subnetID, aws.StringValue(rt.RouteTableId)))
+		case kind == "private" && igwRoute:
+			problems = append(problems, fmt.Sprintf("private subnet %s: route table %s has a default route to internet gateway %s",
+				subnetID, aws.StringValue(rt.RouteTableId), defaultRoute))
+		}
+	}
+
+	details := map[string]interface{}{
+		"vpc_id":             model.VpcID,
+		"route_table_count":  len(routeTableInfo),
+		"route_tables":       routeTableInfo,
+		"subnets":            subnetInfo,
+		"has_internet_route": hasInternetRoute,
+		"source":             source,
+	}
+
+	if len(routeTableInfo) < t.Params.MinTables {
+		problems = append(problems, fmt.Sprintf("expected at least %d route tables", t.Params.MinTables))
+	}
+	if expect := t.Params.ExpectInternetRoute; expect != nil && *expect != hasInternetRoute {
+		if *expect {
+			problems = append(problems, "expected an internet gateway route")
+		} else {
+			problems = append(problems, "expected no internet gateway route")
+		}
+	}
+	if len(problems) > 0 {
+		details["problems"] = problems
+	}
 
 	return TestResult{
-		Success: len(routeTables.RouteTables) > 0,
-		Message: fmt.Sprintf("Found %d route tables, internet route: %v", 
-			len(routeTables.RouteTables), hasInternetRoute),
+		Success: len(problems) == 0,
+		Message: fmt.Sprintf("Found %d route tables for %d subnets, internet route: %v",
+			len(routeTableInfo), len(subnetInfo), hasInternetRoute),
 		Details: details,
 	}
 }
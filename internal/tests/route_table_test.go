@@ -0,0 +1,114 @@
+package tests
+
+import (
+	"context"
+	"testing"
+
+	"qa-test-app/internal/fakeaws"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/ec2"
+)
+
+func TestRouteTablesFixture(t *testing.T) {
+	fake, outputs := loadFixture(t)
+	fn := (&RouteTableTest{}).WithParams(&RouteTableParams{ExpectInternetRoute: aws.Bool(true), MinTables: 2})
+	result := runAgainst(fake, nil, fn, outputs)
+	assertPasses(t, result)
+	if got := result.Details["source"]; got != "aws" {
+		t.Errorf("source = %v, want aws", got)
+	}
+}
+
+func TestRouteTablesProblems(t *testing.T) {
+	for _, tc := range []struct {
+		name   string
+		params RouteTableParams
+		mutate func(t *testing.T, fake *fakeaws.EC2)
+		want   string
+	}{
+		{
+			name: "missing internet gateway route",
+			mutate: func(t *testing.T, fake *fakeaws.EC2) {
+				withoutDefaultRoute(routeTable(t, fake, "rtb-public"))
+			},
+			want: "public subnet subnet-public-1: route table rtb-public has no default route to an internet gateway",
+		},
+		{
+			name:   "internet gateway route expected",
+			params: RouteTableParams{ExpectInternetRoute: aws.Bool(true)},
+			mutate: func(t *testing.T, fake *fakeaws.EC2) {
+				withoutDefaultRoute(routeTable(t, fake, "rtb-public"))
+			},
+			want: "expected an internet gateway route",
+		},
+		{
+			name: "blackhole route",
+			mutate: func(t *testing.T, fake *fakeaws.EC2) {
+				rt := routeTable(t, fake, "rtb-private")
+				rt.Routes = append(rt.Routes, &ec2.Route{
+					DestinationCidrBlock: aws.String("0.0.0.0/0"),
+					NatGatewayId:         aws.String("nat-deleted"),
+					State:                aws.String(ec2.RouteStateBlackhole),
+				})
+			},
+			want: "route table rtb-private: 0.0.0.0/0 -> nat-deleted (blackhole)",
+		},
+		{
+			name: "private subnet routed to the internet gateway",
+			mutate: func(t *testing.T, fake *fakeaws.EC2) {
+				rt := routeTable(t, fake, "rtb-private")
+				rt.Routes = append(rt.Routes, &ec2.Route{
+					DestinationCidrBlock: aws.String("0.0.0.0/0"),
+					GatewayId:            aws.String("igw-fixture"),
+					State:                aws.String(ec2.RouteStateActive),
+				})
+			},
+			want: "private subnet subnet-private-1: route table rtb-private has a default route to internet gateway igw-fixture",
+		},
+		{
+			name: "unassociated subnet",
+			mutate: func(t *testing.T, fake *fakeaws.EC2) {
+				rt := routeTable(t, fake, "rtb-private")
+				rt.Associations = rt.Associations[1:]
+			},
+			want: "subnet subnet-private-1 is not associated with a route table and falls back to main table",
+		},
+		{
+			name:   "too few tables",
+			params: RouteTableParams{MinTables: 5},
+			want:   "expected at least 5 route tables",
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			fake, outputs := loadFixture(t)
+			if tc.mutate != nil {
+				tc.mutate(t, fake)
+			}
+			fn := (&RouteTableTest{}).WithParams(&tc.params)
+			assertFails(t, runAgainst(fake, nil, fn, outputs), "problems", tc.want)
+		})
+	}
+}
+
+func TestRouteTablesAllowUnassociated(t *testing.T) {
+	fake, outputs := loadFixture(t)
+	rt := routeTable(t, fake, "rtb-private")
+	rt.Associations = rt.Associations[1:]
+	result := runAgainst(fake, nil, (&RouteTableTest{}).WithParams(&RouteTableParams{AllowUnassociated: true}), outputs)
+	assertPasses(t, result)
+}
+
+func TestRouteTablesFromState(t *testing.T) {
+	state, err := fakeaws.LoadState("../../fixtures/vpc.yaml")
+	if err != nil {
+		t.Fatalf("LoadState: %v", err)
+	}
+	// Without AWS access the state of the workspace is all there is to check
+	ctx := WithEnvironment(context.Background(), &Environment{State: state})
+	result := (&RouteTableTest{Params: RouteTableParams{MinTables: 2}}).Execute(ctx, state.Outputs().Values())
+	assertPasses(t, result)
+	if got := result.Details["source"]; got != "state" {
+		t.Errorf("source = %v, want state", got)
+	}
+}
@@ -4,13 +4,76 @@ import (
 	"context"
 	"fmt"
 
+	"qa-test-app/internal/network"
+
 	"github.com/aws/aws-sdk-go/aws"
-	"github.com/aws/aws-sdk-go/aws/session"
 	"github.com/aws/aws-sdk-go/service/ec2"
 )
 
+func init() {
+	RegisterBuiltin(&SubnetConnectivityTest{})
+}
+
+// InternetTarget is the `to` of an expectation about traffic leaving the VPC
+const InternetTarget = "internet"
+
 // SubnetConnectivityTest tests subnet reachability
-type SubnetConnectivityTest struct{}
+type SubnetConnectivityTest struct {
+	Params SubnetConnectivityParams
+}
+
+// SubnetConnectivityParams configures test_subnet_connectivity
+type SubnetConnectivityParams struct {
+	MinSubnets int `yaml:"min_subnets"`
+	// Protocol and Port are the traffic checked: tcp, udp or icmp, which has no port
+	Protocol string `yaml:"protocol"`
+	Port     int64  `yaml:"port"`
+	// SecurityGroups are the groups of the instances on both ends; the VPC's default group when empty
+	SecurityGroups []string `yaml:"security_groups"`
+	// Expect lists the reachability the VPC must have; other pairs are only reported
+	Expect []ReachabilityExpectation `yaml:"expect"`
+}
+
+// ReachabilityExpectation is whether traffic from some subnets reaches
+// others. From and To are a subnet ID, public or private for the subnets in
+// the public_subnet_ids or private_subnet_ids output, or * for every subnet;
+// To can also be internet.
+type ReachabilityExpectation struct {
+	From      string `yaml:"from"`
+	To        string `yaml:"to"`
+	Reachable *bool  `yaml:"reachable"`
+}
+
+func (p *SubnetConnectivityParams) Validate() error {
+	if p.MinSubnets < 0 {
+		return fmt.Errorf("min_subnets must not be negative")
+	}
+	if _, ok := network.Protocols[p.Protocol]; !ok {
+		return fmt.Errorf("protocol must be tcp, udp or icmp, got %q", p.Protocol)
+	}
+	if p.Protocol != "icmp" && (p.Port < 1 || p.Port > 65535) {
+		return fmt.Errorf("port must be between 1 and 65535")
+	}
+	for i, expect := range p.Expect {
+		switch {
+		case expect.From == "" || expect.To == "":
+			return fmt.Errorf("expect[%d]: from and to are required", i)
+		case expect.From == InternetTarget:
+			return fmt.Errorf("expect[%d]: only to can be %s", i, InternetTarget)
+		case expect.Reachable == nil:
+			return fmt.Errorf("expect[%d]: reachable is required", i)
+		}
+	}
+	return nil
+}
+
+func (t *SubnetConnectivityTest) NewParams() interface{} {
+	return &SubnetConnectivityParams{MinSubnets: 1, Protocol: "tcp", Port: 443}
+}
+
+func (t *SubnetConnectivityTest) WithParams(params interface{}) Function {
+	return &SubnetConnectivityTest{Params: *params.(*SubnetConnectivityParams)}
+}
 
 func (t *SubnetConnectivityTest) Name() string {
 	return "test_subnet_connectivity"
@@ -29,55 +92,180 @@ func (t *SubnetConnectivityTest) Execute(ctx context.Context, tfOutputs map[stri
 		}
 	}
 
-	sess, err := session.NewSession(&aws.Config{
-		Region: aws.String("eu-north-1"),
-	})
-	if err != nil {
-		return TestResult{
-			Success: false,
-			Message: fmt.Sprintf("AWS session creation failed: %v", err),
+	// Without AWS access, fall back to what the state of the applied workspace records
+	if env := EnvironmentFrom(ctx); env.AWS == nil && env.State != nil {
+		inventory, err := network.FromState(env.State)
+		if err != nil {
+			return TestResult{
+				Success: false,
+				Message: fmt.Sprintf("Failed to model the VPC from state: %v", err),
+			}
 		}
+		return t.result(inventory.Model(vpcID), tfOutputs, "state")
 	}
 
-	ec2Svc := ec2.New(sess)
+	model, attempts, failed := loadNetwork(ctx, vpcID, tfOutputs)
+	if failed != nil {
+		return *failed
+	}
+	result := t.result(model, tfOutputs, "aws")
+	if len(attempts) > 1 {
+		result = withAttempts(result, attempts)
+	}
+	return result
+}
 
-	// Get subnets
-	subnets, err := ec2Svc.DescribeSubnets(&ec2.DescribeSubnetsInput{
-		Filters: []*ec2.Filter{
-			{
-				Name:   aws.String("vpc-id"),
-				Values: []*string{aws.String(vpcID)},
-			},
-		},
-	})
+// result reports the subnets found in a VPC and the reachability between
+// them, and checks it against the expectations
+func (t *SubnetConnectivityTest) result(model *network.Model, tfOutputs map[string]interface{}, source string) TestResult {
+	subnetInfo := []map[string]string{}
+	for _, subnet := range model.Subnets {
+		subnetInfo = append(subnetInfo, map[string]string{
+			"id":   aws.StringValue(subnet.SubnetId),
+			"cidr": aws.StringValue(subnet.CidrBlock),
+			"az":   aws.StringValue(subnet.AvailabilityZone),
+		})
+	}
+	subnetCount := len(subnetInfo)
+	details := map[string]interface{}{
+		"vpc_id":       model.VpcID,
+		"subnet_count": subnetCount,
+		"subnets":      subnetInfo,
+		"source":       source,
+	}
+
+	groups, err := t.securityGroups(model)
 	if err != nil {
 		return TestResult{
 			Success: false,
-			Message: fmt.Sprintf("Failed to describe subnets: %v", err),
+			Message: err.Error(),
+			Details: details,
 		}
 	}
+	flow := network.Flow{Protocol: t.Params.Protocol, Port: t.Params.Port}
+	details["flow"] = flow.String()
 
-	subnetCount := len(subnets.Subnets)
-	details := map[string]interface{}{
-		"vpc_id":       vpcID,
-		"subnet_count": subnetCount,
-		"subnets":      []map[string]string{},
+	// Every pair is evaluated and reported, whether an expectation covers it or not
+	verdicts := make(map[[2]string]network.Verdict)
+	pairs := []map[string]interface{}{}
+	for _, from := range model.Subnets {
+		fromID := aws.StringValue(from.SubnetId)
+		targets := []string{}
+		for _, to := range model.Subnets {
+			if to != from {
+				targets = append(targets, aws.StringValue(to.SubnetId))
+			}
+		}
+		for _, toID := range append(targets, InternetTarget) {
+			var verdict network.Verdict
+			if toID == InternetTarget {
+				verdict, err = model.SubnetToInternet(fromID, flow, groups)
+			} else {
+				verdict, err = model.SubnetToSubnet(fromID, toID, flow, groups)
+			}
+			if err != nil {
+				return TestResult{
+					Success: false,
+					Message: err.Error(),
+					Details: details,
+				}
+			}
+			verdicts[[2]string{fromID, toID}] = verdict
+			pairs = append(pairs, map[string]interface{}{
+				"from":      fromID,
+				"to":        toID,
+				"reachable": verdict.Reachable,
+				"rule":      verdict.Rule,
+				"hops":      verdict.Hops,
+			})
+		}
 	}
+	details["reachability"] = pairs
 
-	subnetInfo := []map[string]string{}
-	for _, subnet := range subnets.Subnets {
-		info := map[string]string{
-			"id":   *subnet.SubnetId,
-			"cidr": *subnet.CidrBlock,
-			"az":   *subnet.AvailabilityZone,
+	var problems []string
+	if subnetCount < t.Params.MinSubnets {
+		problems = append(problems, fmt.Sprintf("expected at least %d subnets", t.Params.MinSubnets))
+	}
+	checked := 0
+	for i, expect := range t.Params.Expect {
+		froms := selectSubnets(model, tfOutputs, expect.From)
+		tos := []string{InternetTarget}
+		if expect.To != InternetTarget {
+			tos = selectSubnets(model, tfOutputs, expect.To)
+		}
+		if len(froms) == 0 || len(tos) == 0 {
+			problems = append(problems, fmt.Sprintf("expect[%d]: %s -> %s matches no subnets", i, expect.From, expect.To))
+			continue
 		}
-		subnetInfo = append(subnetInfo, info)
+		for _, fromID := range froms {
+			for _, toID := range tos {
+				verdict, ok := verdicts[[2]string{fromID, toID}]
+				if !ok {
+					continue
+				}
+				checked++
+				switch {
+				case *expect.Reachable && !verdict.Reachable:
+					problems = append(problems, fmt.Sprintf("%s -> %s: expected reachable, blocked by %s", fromID, toID, verdict.Rule))
+				case !*expect.Reachable && verdict.Reachable:
+					problems = append(problems, fmt.Sprintf("%s -> %s: expected blocked, allowed through %s", fromID, toID, verdict.Rule))
+				}
+			}
+		}
+	}
+	if len(problems) > 0 {
+		details["problems"] = problems
 	}
-	details["subnets"] = subnetInfo
 
+	message := fmt.Sprintf("Found %d subnets in VPC", subnetCount)
+	if checked > 0 {
+		message += fmt.Sprintf("; checked %d expected path(s) for %s", checked, flow)
+	}
 	return TestResult{
-		Success: subnetCount > 0,
-		Message: fmt.Sprintf("Found %d subnets in VPC", subnetCount),
+		Success: len(problems) == 0,
+		Message: message,
 		Details: details,
 	}
 }
+
+// securityGroups returns the groups named in the params, or the VPC's default group
+func (t *SubnetConnectivityTest) securityGroups(model *network.Model) ([]*ec2.SecurityGroup, error) {
+	if len(t.Params.SecurityGroups) == 0 {
+		if sg := model.DefaultSecurityGroup(); sg != nil {
+			return []*ec2.SecurityGroup{sg}, nil
+		}
+		return nil, nil
+	}
+	groups := make([]*ec2.SecurityGroup, 0, len(t.Params.SecurityGroups))
+	for _, id := range t.Params.SecurityGroups {
+		sg, ok := model.SecurityGroup(id)
+		if !ok {
+			return nil, fmt.Errorf("security group %s not found in VPC %s", id, model.VpcID)
+		}
+		groups = append(groups, sg)
+	}
+	return groups, nil
+}
+
+// selectSubnets returns the IDs of the VPC's subnets a selector names
+func selectSubnets(model *network.Model, tfOutputs map[string]interface{}, selector string) []string {
+	var ids []string
+	switch selector {
+	case "*":
+		for _, subnet := range model.Subnets {
+			ids = append(ids, aws.StringValue(subnet.SubnetId))
+		}
+	case "public", "private":
+		ids, _ = stringList(tfOutputs[selector+"_subnet_ids"])
+	default:
+		ids = []string{selector}
+	}
+
+	var found []string
+	for _, id := range ids {
+		if _, ok := model.Subnet(id); ok {
+			found = append(found, id)
+		}
+	}
+	return found
+}
@@ -0,0 +1,133 @@
+package tests
+
+import (
+	"testing"
+
+	"qa-test-app/internal/fakeaws"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/ec2"
+)
+
+// fixtureExpectations is the reachability the fixture's layout is meant to have
+func fixtureExpectations() []ReachabilityExpectation {
+	return []ReachabilityExpectation{
+		{From: "public", To: InternetTarget, Reachable: aws.Bool(true)},
+		{From: "private", To: InternetTarget, Reachable: aws.Bool(false)},
+		{From: "public", To: "private", Reachable: aws.Bool(true)},
+		{From: "private", To: "public", Reachable: aws.Bool(true)},
+	}
+}
+
+func connectivityTest(groups []string, expect []ReachabilityExpectation) Function {
+	return (&SubnetConnectivityTest{}).WithParams(&SubnetConnectivityParams{
+		MinSubnets:     4,
+		Protocol:       "tcp",
+		Port:           443,
+		SecurityGroups: groups,
+		Expect:         expect,
+	})
+}
+
+func TestSubnetConnectivityFixture(t *testing.T) {
+	for _, groups := range [][]string{nil, {"sg-web"}} {
+		fake, outputs := loadFixture(t)
+		result := runAgainst(fake, nil, connectivityTest(groups, fixtureExpectations()), outputs)
+		assertPasses(t, result)
+		if got := result.Details["subnet_count"]; got != 4 {
+			t.Errorf("groups %v: subnet_count = %v, want 4", groups, got)
+		}
+	}
+}
+
+func TestSubnetConnectivityProblems(t *testing.T) {
+	for _, tc := range []struct {
+		name   string
+		mutate func(t *testing.T, fake *fakeaws.EC2)
+		groups []string
+		want   string
+	}{
+		{
+			name: "network ACL deny",
+			mutate: func(t *testing.T, fake *fakeaws.EC2) {
+				acl := networkAcl(t, fake, "acl-private")
+				acl.Entries = append(acl.Entries, &ec2.NetworkAclEntry{
+					RuleNumber: aws.Int64(50),
+					Egress:     aws.Bool(false),
+					Protocol:   aws.String("6"),
+					RuleAction: aws.String(ec2.RuleActionDeny),
+					CidrBlock:  aws.String("10.0.100.0/22"),
+					PortRange:  &ec2.PortRange{From: aws.Int64(443), To: aws.Int64(443)},
+				})
+			},
+			want: "subnet-public-1 -> subnet-private-1: expected reachable, blocked by network ACL acl-private",
+		},
+		{
+			name: "missing internet gateway route",
+			mutate: func(t *testing.T, fake *fakeaws.EC2) {
+				withoutDefaultRoute(routeTable(t, fake, "rtb-public"))
+			},
+			want: "subnet-public-1 -> internet: expected reachable, blocked by route table rtb-public",
+		},
+		{
+			name: "blackhole route",
+			mutate: func(t *testing.T, fake *fakeaws.EC2) {
+				rt := routeTable(t, fake, "rtb-public")
+				for _, route := range rt.Routes {
+					if aws.StringValue(route.GatewayId) == "igw-fixture" {
+						route.State = aws.String(ec2.RouteStateBlackhole)
+					}
+				}
+			},
+			want: "subnet-public-1 -> internet: expected reachable, blocked by route table rtb-public",
+		},
+		{
+			name: "private subnet routed to the internet gateway",
+			mutate: func(t *testing.T, fake *fakeaws.EC2) {
+				rt := routeTable(t, fake, "rtb-private")
+				rt.Routes = append(rt.Routes, &ec2.Route{
+					DestinationCidrBlock: aws.String("0.0.0.0/0"),
+					GatewayId:            aws.String("igw-fixture"),
+					State:                aws.String(ec2.RouteStateActive),
+				})
+				// Replies from the internet are otherwise dropped by the private subnets' ACL
+				acl := networkAcl(t, fake, "acl-private")
+				acl.Entries = append(acl.Entries, &ec2.NetworkAclEntry{
+					RuleNumber: aws.Int64(200),
+					Egress:     aws.Bool(false),
+					Protocol:   aws.String("-1"),
+					RuleAction: aws.String(ec2.RuleActionAllow),
+					CidrBlock:  aws.String("0.0.0.0/0"),
+				})
+			},
+			want: "subnet-private-1 -> internet: expected blocked, allowed through",
+		},
+		{
+			name:   "security group without the port",
+			groups: []string{"sg-web"},
+			mutate: func(t *testing.T, fake *fakeaws.EC2) {
+				for _, sg := range fake.SecurityGroups {
+					if aws.StringValue(sg.GroupId) == "sg-web" {
+						sg.IpPermissions[0].FromPort = aws.Int64(80)
+						sg.IpPermissions[0].ToPort = aws.Int64(80)
+					}
+				}
+			},
+			want: "subnet-public-1 -> subnet-private-1: expected reachable, blocked by security groups",
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			fake, outputs := loadFixture(t)
+			tc.mutate(t, fake)
+			result := runAgainst(fake, nil, connectivityTest(tc.groups, fixtureExpectations()), outputs)
+			assertFails(t, result, "problems", tc.want)
+		})
+	}
+}
+
+func TestSubnetConnectivityUnmatchedExpectation(t *testing.T) {
+	fake, outputs := loadFixture(t)
+	expect := []ReachabilityExpectation{{From: "subnet-missing", To: "*", Reachable: aws.Bool(true)}}
+	result := runAgainst(fake, nil, connectivityTest(nil, expect), outputs)
+	assertFails(t, result, "problems", "expect[0]: subnet-missing -> * matches no subnets")
+}
@@ -0,0 +1,4 @@
+#!/bin/sh
+# Reports the input it got on stdin and in $QA_TEST_INPUT
+stdin=$(cat)
+printf '{"success": true, "message": "ok", "details": {"stdin": %s, "file": %s}}\n' "$stdin" "$(cat "$QA_TEST_INPUT")"
@@ -0,0 +1,3 @@
+#!/bin/sh
+echo '{"success": true, "message": "all good"}'
+exit 3
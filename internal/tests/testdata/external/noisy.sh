@@ -0,0 +1,6 @@
+#!/bin/sh
+# Writes more stderr than a result keeps, ending with a marker
+echo "first line" >&2
+head -c 5000 /dev/zero | tr '\0' x >&2
+echo " last line" >&2
+echo '{"success": true}'
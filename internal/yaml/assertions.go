@@ -0,0 +1,56 @@
+package yaml
+
+import (
+	"fmt"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Assertion is an entry of assertions: either a plain expression or a mapping
+// with an expression and a name to report it under
+type Assertion struct {
+	Name string `yaml:"name"`
+	Expr string `yaml:"expr"`
+
+	// node keeps the position for errors found after parsing
+	node *yaml.Node
+	path string
+}
+
+// UnmarshalYAML accepts "- expression" as well as "- name: ..., expr: ..."
+func (a *Assertion) UnmarshalYAML(node *yaml.Node) error {
+	a.node = node
+	switch node.Kind {
+	case yaml.ScalarNode:
+		a.Expr = node.Value
+		return nil
+	case yaml.MappingNode:
+		type plain Assertion
+		if err := node.Decode((*plain)(a)); err != nil {
+			return err
+		}
+		a.node = node
+		return nil
+	default:
+		return &yaml.TypeError{Errors: []string{
+			fmt.Sprintf("line %d: assertion must be an expression or a mapping with name and expr", node.Line),
+		}}
+	}
+}
+
+// Errorf returns a field error positioned at the entry
+func (a Assertion) Errorf(format string, args ...interface{}) FieldError {
+	fe := FieldError{Path: a.path, Msg: fmt.Sprintf(format, args...)}
+	if a.node != nil {
+		fe.Line, fe.Column = a.node.Line, a.node.Column
+	}
+	return fe
+}
+
+// String returns the name, or the expression when the entry has no name
+func (a Assertion) String() string {
+	if a.Name != "" {
+		return a.Name
+	}
+	return a.Expr
+}
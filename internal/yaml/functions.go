@@ -0,0 +1,87 @@
+package yaml
+
+import (
+	"fmt"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+// TestFunctionRef is an entry of test_functions: either a plain name or a
+// mapping with a name, the params passed to the function and how to run it
+type TestFunctionRef struct {
+	Name   string                 `yaml:"name"`
+	Params map[string]interface{} `yaml:"params"`
+	// Timeout bounds each attempt; Retries reruns a failing function after RetryInterval
+	Timeout       time.Duration `yaml:"timeout"`
+	Retries       int           `yaml:"retries"`
+	RetryInterval time.Duration `yaml:"retry_interval"`
+
+	// node and params keep positions for errors found after parsing
+	node   *yaml.Node
+	params *yaml.Node
+	path   string
+}
+
+// UnmarshalYAML accepts "- name" as well as "- name: ..., params: {...}, retries: ..."
+func (r *TestFunctionRef) UnmarshalYAML(node *yaml.Node) error {
+	r.node = node
+	switch node.Kind {
+	case yaml.ScalarNode:
+		r.Name = node.Value
+		return nil
+	case yaml.MappingNode:
+		type plain TestFunctionRef
+		if err := node.Decode((*plain)(r)); err != nil {
+			return err
+		}
+		r.node = node
+		_, r.params = lookup(node, "params")
+		return nil
+	default:
+		return &yaml.TypeError{Errors: []string{
+			fmt.Sprintf("line %d: test function must be a name or a mapping with name and params", node.Line),
+		}}
+	}
+}
+
+// HasParams reports whether the entry sets any params
+func (r TestFunctionRef) HasParams() bool {
+	return len(r.Params) > 0
+}
+
+// DecodeParams decodes the entry's params into v, a pointer to the function's
+// params struct. Keys without a matching field and values of the wrong type
+// are reported with their position in the test case file. Fields of v that
+// the entry doesn't set keep their values, so v can carry defaults.
+func (r TestFunctionRef) DecodeParams(v interface{}) []FieldError {
+	if r.params == nil {
+		return nil
+	}
+
+	verr := &ValidationError{}
+	path := joinPath(r.path, "params")
+	checkKnownFields(r.params, path, typeOf(v), verr)
+	if err := r.params.Decode(v); err != nil {
+		verr.addDecodeError(err)
+		for i := range verr.Errors {
+			if verr.Errors[i].Path == "" {
+				verr.Errors[i].Path = path
+			}
+		}
+	}
+	return verr.Errors
+}
+
+// Errorf returns a field error positioned at the entry
+func (r TestFunctionRef) Errorf(format string, args ...interface{}) FieldError {
+	fe := FieldError{Path: r.path, Msg: fmt.Sprintf(format, args...)}
+	if r.node != nil {
+		fe.Line, fe.Column = r.node.Line, r.node.Column
+	}
+	return fe
+}
+
+func (r TestFunctionRef) String() string {
+	return r.Name
+}
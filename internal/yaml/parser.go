@@ -1,32 +1,74 @@
 package yaml
 
 import (
-    "gopkg.in/yaml.v3"
-    "io/ioutil"
+	"fmt"
+	"gopkg.in/yaml.v3"
+	"io/ioutil"
+	"time"
 )
 
 type TestCase struct {
-    Metadata struct {
-        Name           string `yaml:"name"`
-        Type           string `yaml:"type"`
-        Priority       string `yaml:"priority"`
-        Severity       string `yaml:"severity"`
-        ExpectedResult string `yaml:"expected_result"`
-        Description    string `yaml:"description"`
-    } `yaml:"metadata"`
-    Terraform struct {
-        TfVars map[string]interface{} `yaml:"tfvars"`
-    } `yaml:"terraform"`
-    TestFunctions []string `yaml:"test_functions"`
+	Metadata struct {
+		Name           string `yaml:"name"`
+		Type           string `yaml:"type"`
+		Priority       string `yaml:"priority"`
+		Severity       string `yaml:"severity"`
+		ExpectedResult string `yaml:"expected_result"`
+		Description    string `yaml:"description"`
+	} `yaml:"metadata"`
+	Terraform struct {
+		TfVars       map[string]interface{} `yaml:"tfvars"`
+		TfvarsFormat string                 `yaml:"tfvars_format"`
+		Timeouts     struct {
+			Init    time.Duration `yaml:"init"`
+			Plan    time.Duration `yaml:"plan"`
+			Apply   time.Duration `yaml:"apply"`
+			Destroy time.Duration `yaml:"destroy"`
+		} `yaml:"timeouts"`
+	} `yaml:"terraform"`
+	TestFunctions []TestFunctionRef `yaml:"test_functions"`
+	Assertions    []Assertion       `yaml:"assertions"`
+	// Execution overrides how the test functions are run; unset fields keep the command line defaults
+	Execution struct {
+		Concurrency int           `yaml:"concurrency"`
+		TestTimeout time.Duration `yaml:"test_timeout"`
+		FailFast    *bool         `yaml:"fail_fast"`
+	} `yaml:"execution"`
+
+	// Source is the file the test case was loaded from
+	Source string `yaml:"-"`
 }
 
+// ParseTestCase reads a test case file, rejecting unknown keys and checking
+// required fields. All problems found are returned together as a *ValidationError.
 func ParseTestCase(filepath string) (*TestCase, error) {
-    data, err := ioutil.ReadFile(filepath)
-    if err != nil {
-        return nil, err
-    }
+	data, err := ioutil.ReadFile(filepath)
+	if err != nil {
+		return nil, err
+	}
+
+	var root yaml.Node
+	if err := yaml.Unmarshal(data, &root); err != nil {
+		return nil, fmt.Errorf("%s: %w", filepath, err)
+	}
+	if len(root.Content) == 0 {
+		return nil, fmt.Errorf("%s: empty test case file", filepath)
+	}
+	doc := root.Content[0]
+
+	var tc TestCase
+	verr := &ValidationError{File: filepath}
+	checkKnownFields(doc, "", typeOf(&tc), verr)
+	if err := doc.Decode(&tc); err != nil {
+		verr.addDecodeError(err)
+	} else {
+		tc.validate(doc, verr)
+	}
 
-    var tc TestCase
-    err = yaml.Unmarshal(data, &tc)
-    return &tc, err
+	if len(verr.Errors) > 0 {
+		verr.sort()
+		return nil, verr
+	}
+	tc.Source = filepath
+	return &tc, nil
 }
@@ -0,0 +1,97 @@
+package yaml
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// LoadSuite parses every test case matched by targets. Each target may be a
+// directory (searched recursively for *.yaml and *.yml), a glob pattern or a
+// single file. Files keep the order of targets, sorted within each target, and
+// a file matched by more than one target is loaded once; parse errors from all
+// files are joined so a broken suite is reported in one go.
+func LoadSuite(targets ...string) ([]*TestCase, error) {
+	var files []string
+	seen := make(map[string]bool)
+
+	for _, target := range targets {
+		matches, err := resolveTarget(target)
+		if err != nil {
+			return nil, err
+		}
+		for _, path := range matches {
+			// "cases/a.yaml" and "./cases/a.yaml" are the same file
+			path = filepath.Clean(path)
+			if !seen[path] {
+				seen[path] = true
+				files = append(files, path)
+			}
+		}
+	}
+
+	if len(files) == 0 {
+		return nil, fmt.Errorf("no test case files found in %s", strings.Join(targets, ", "))
+	}
+
+	var cases []*TestCase
+	var errs []error
+	for _, path := range files {
+		tc, err := ParseTestCase(path)
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		cases = append(cases, tc)
+	}
+
+	if len(errs) > 0 {
+		return nil, errors.Join(errs...)
+	}
+	return cases, nil
+}
+
+// resolveTarget expands a single directory, glob or file into test case paths
+func resolveTarget(target string) ([]string, error) {
+	if strings.ContainsAny(target, "*?[") {
+		matches, err := filepath.Glob(target)
+		if err != nil {
+			return nil, fmt.Errorf("invalid pattern %q: %w", target, err)
+		}
+		sort.Strings(matches)
+		return matches, nil
+	}
+
+	info, err := os.Stat(target)
+	if err != nil {
+		return nil, err
+	}
+	if !info.IsDir() {
+		return []string{target}, nil
+	}
+
+	var matches []string
+	err = filepath.WalkDir(target, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if !d.IsDir() && isYAMLFile(path) {
+			matches = append(matches, path)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	sort.Strings(matches)
+	return matches, nil
+}
+
+func isYAMLFile(path string) bool {
+	ext := strings.ToLower(filepath.Ext(path))
+	return ext == ".yaml" || ext == ".yml"
+}
@@ -0,0 +1,95 @@
+package yaml
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// writeSuite creates a test case file for each name under a temporary directory
+func writeSuite(t *testing.T, names ...string) string {
+	t.Helper()
+	dir := t.TempDir()
+	for _, name := range names {
+		path := filepath.Join(dir, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		src := validMetadata + "test_functions: [a]\n"
+		if !isYAMLFile(name) {
+			src = "not a test case"
+		}
+		if err := os.WriteFile(path, []byte(src), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return dir
+}
+
+// sources returns the files the test cases were loaded from, relative to dir
+func sources(t *testing.T, dir string, cases []*TestCase) []string {
+	t.Helper()
+	var rel []string
+	for _, tc := range cases {
+		path, err := filepath.Rel(dir, tc.Source)
+		if err != nil {
+			t.Fatal(err)
+		}
+		rel = append(rel, filepath.ToSlash(path))
+	}
+	return rel
+}
+
+func TestLoadSuite(t *testing.T) {
+	dir := writeSuite(t, "b.yaml", "a.yml", "nested/c.yaml", "README.md", "nested/notes.txt")
+	join := func(elem ...string) string { return filepath.Join(append([]string{dir}, elem...)...) }
+
+	for _, tc := range []struct {
+		name    string
+		targets []string
+		want    []string
+	}{
+		{"directory", []string{dir}, []string{"a.yml", "b.yaml", "nested/c.yaml"}},
+		{"glob", []string{join("*.yaml")}, []string{"b.yaml"}},
+		{"file", []string{join("nested", "c.yaml")}, []string{"nested/c.yaml"}},
+		{"target order", []string{join("nested"), join("b.yaml")}, []string{"nested/c.yaml", "b.yaml"}},
+		{"duplicates", []string{join("b.yaml"), dir, join("*.yaml"), dir + "/./b.yaml"}, []string{"b.yaml", "a.yml", "nested/c.yaml"}},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			cases, err := LoadSuite(tc.targets...)
+			if err != nil {
+				t.Fatalf("LoadSuite: %v", err)
+			}
+			if got := sources(t, dir, cases); !reflect.DeepEqual(got, tc.want) {
+				t.Errorf("loaded %q, want %q", got, tc.want)
+			}
+		})
+	}
+}
+
+func TestLoadSuiteErrors(t *testing.T) {
+	dir := writeSuite(t, "README.md")
+
+	if _, err := LoadSuite(dir, filepath.Join(dir, "*.yml")); err == nil || !strings.Contains(err.Error(), "no test case files found") {
+		t.Errorf("empty suite: error = %v", err)
+	}
+	if _, err := LoadSuite(filepath.Join(dir, "missing")); !os.IsNotExist(err) {
+		t.Errorf("missing target: error = %v", err)
+	}
+	if _, err := LoadSuite(filepath.Join(dir, "[")); err == nil || !strings.Contains(err.Error(), "invalid pattern") {
+		t.Errorf("bad pattern: error = %v", err)
+	}
+
+	// Every broken file is reported, not just the first
+	for _, name := range []string{"a.yaml", "b.yaml"} {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte("metadata: {}\n"), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	_, err := LoadSuite(dir)
+	if err == nil || !strings.Contains(err.Error(), "a.yaml") || !strings.Contains(err.Error(), "b.yaml") {
+		t.Errorf("broken files: error = %v", err)
+	}
+}
@@ -0,0 +1,290 @@
+package yaml
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Allowed values for metadata.priority, metadata.severity and terraform.tfvars_format
+var (
+	Priorities    = []string{"low", "medium", "high", "critical"}
+	Severities    = []string{"trivial", "minor", "major", "critical", "blocker"}
+	TfvarsFormats = []string{"hcl", "json"}
+)
+
+// FieldError describes a single problem at a position in a test case file
+type FieldError struct {
+	Path   string
+	Line   int
+	Column int
+	Msg    string
+}
+
+func (e FieldError) Error() string {
+	pos := fmt.Sprintf("%d:%d", e.Line, e.Column)
+	if e.Path == "" {
+		return fmt.Sprintf("%s: %s", pos, e.Msg)
+	}
+	return fmt.Sprintf("%s: %s: %s", pos, e.Path, e.Msg)
+}
+
+// ValidationError collects every problem found while parsing a test case file
+type ValidationError struct {
+	File   string
+	Errors []FieldError
+}
+
+func (e *ValidationError) Error() string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "%s: %d validation error(s)", e.File, len(e.Errors))
+	for _, fe := range e.Errors {
+		fmt.Fprintf(&b, "\n  %s:%s", e.File, fe.Error())
+	}
+	return b.String()
+}
+
+// Unwrap exposes the individual field errors to errors.As
+func (e *ValidationError) Unwrap() []error {
+	errs := make([]error, len(e.Errors))
+	for i, fe := range e.Errors {
+		errs[i] = fe
+	}
+	return errs
+}
+
+func (e *ValidationError) add(node *yaml.Node, path, format string, args ...interface{}) {
+	fe := FieldError{Path: path, Msg: fmt.Sprintf(format, args...)}
+	if node != nil {
+		fe.Line, fe.Column = node.Line, node.Column
+	}
+	e.Errors = append(e.Errors, fe)
+}
+
+var decodeLinePattern = regexp.MustCompile(`^line (\d+): (.*)$`)
+
+// addDecodeError converts a yaml.TypeError into field errors, keeping line numbers
+func (e *ValidationError) addDecodeError(err error) {
+	var typeErr *yaml.TypeError
+	if !errors.As(err, &typeErr) {
+		e.Errors = append(e.Errors, FieldError{Msg: err.Error()})
+		return
+	}
+	for _, msg := range typeErr.Errors {
+		fe := FieldError{Msg: msg}
+		if m := decodeLinePattern.FindStringSubmatch(msg); m != nil {
+			fe.Line, _ = strconv.Atoi(m[1])
+			fe.Msg = m[2]
+		}
+		e.Errors = append(e.Errors, fe)
+	}
+}
+
+func (e *ValidationError) sort() {
+	sort.SliceStable(e.Errors, func(i, j int) bool {
+		a, b := e.Errors[i], e.Errors[j]
+		if a.Line != b.Line {
+			return a.Line < b.Line
+		}
+		return a.Column < b.Column
+	})
+}
+
+// validate checks required fields and enum values against the decoded node tree
+func (tc *TestCase) validate(doc *yaml.Node, verr *ValidationError) {
+	required := []struct {
+		key   string
+		value string
+	}{
+		{"name", tc.Metadata.Name},
+		{"type", tc.Metadata.Type},
+		{"priority", tc.Metadata.Priority},
+		{"severity", tc.Metadata.Severity},
+		{"expected_result", tc.Metadata.ExpectedResult},
+	}
+
+	metaKey, meta := lookup(doc, "metadata")
+	if meta == nil {
+		verr.add(doc, "metadata", "required section is missing")
+	} else {
+		for _, r := range required {
+			path := "metadata." + r.key
+			key, value := lookup(meta, r.key)
+			switch {
+			case key == nil:
+				verr.add(metaKey, path, "required field is missing")
+			case strings.TrimSpace(r.value) == "":
+				verr.add(value, path, "required field is empty")
+			}
+		}
+		checkEnum(meta, "metadata", "priority", tc.Metadata.Priority, Priorities, verr)
+		checkEnum(meta, "metadata", "severity", tc.Metadata.Severity, Severities, verr)
+	}
+
+	_, terraform := lookup(doc, "terraform")
+	checkEnum(terraform, "terraform", "tfvars_format", tc.Terraform.TfvarsFormat, TfvarsFormats, verr)
+
+	_, timeouts := lookup(terraform, "timeouts")
+	for key, d := range map[string]time.Duration{
+		"init":    tc.Terraform.Timeouts.Init,
+		"plan":    tc.Terraform.Timeouts.Plan,
+		"apply":   tc.Terraform.Timeouts.Apply,
+		"destroy": tc.Terraform.Timeouts.Destroy,
+	} {
+		if d < 0 {
+			_, node := lookup(timeouts, key)
+			verr.add(node, "terraform.timeouts."+key, "timeout must not be negative")
+		}
+	}
+
+	_, execution := lookup(doc, "execution")
+	if tc.Execution.Concurrency < 0 {
+		_, node := lookup(execution, "concurrency")
+		verr.add(node, "execution.concurrency", "concurrency must not be negative")
+	}
+	if tc.Execution.TestTimeout < 0 {
+		_, node := lookup(execution, "test_timeout")
+		verr.add(node, "execution.test_timeout", "timeout must not be negative")
+	}
+
+	if len(tc.TestFunctions) == 0 && len(tc.Assertions) == 0 {
+		key, _ := lookup(doc, "test_functions")
+		if key == nil {
+			key = doc
+		}
+		verr.add(key, "test_functions", "at least one test function or assertion is required")
+	}
+	for i := range tc.TestFunctions {
+		ref := &tc.TestFunctions[i]
+		ref.path = fmt.Sprintf("test_functions[%d]", i)
+		if strings.TrimSpace(ref.Name) == "" {
+			verr.Errors = append(verr.Errors, ref.Errorf("test function name is empty"))
+		}
+		for key, negative := range map[string]bool{
+			"timeout":        ref.Timeout < 0,
+			"retries":        ref.Retries < 0,
+			"retry_interval": ref.RetryInterval < 0,
+		} {
+			if negative {
+				_, node := lookup(ref.node, key)
+				verr.add(node, ref.path+"."+key, "%s must not be negative", key)
+			}
+		}
+	}
+	for i := range tc.Assertions {
+		assertion := &tc.Assertions[i]
+		assertion.path = fmt.Sprintf("assertions[%d]", i)
+		if strings.TrimSpace(assertion.Expr) == "" {
+			verr.Errors = append(verr.Errors, assertion.Errorf("assertion expression is empty"))
+		}
+	}
+}
+
+// checkEnum reports value if it is set but not one of allowed
+func checkEnum(parent *yaml.Node, section, key, value string, allowed []string, verr *ValidationError) {
+	if value == "" {
+		return
+	}
+	for _, a := range allowed {
+		if value == a {
+			return
+		}
+	}
+	_, node := lookup(parent, key)
+	verr.add(node, section+"."+key, "invalid value %q, must be one of: %s", value, strings.Join(allowed, ", "))
+}
+
+// lookup returns the key and value nodes for key in a mapping node
+func lookup(mapping *yaml.Node, key string) (*yaml.Node, *yaml.Node) {
+	if mapping == nil || mapping.Kind != yaml.MappingNode {
+		return nil, nil
+	}
+	for i := 0; i+1 < len(mapping.Content); i += 2 {
+		if mapping.Content[i].Value == key {
+			return mapping.Content[i], mapping.Content[i+1]
+		}
+	}
+	return nil, nil
+}
+
+func typeOf(v interface{}) reflect.Type {
+	return reflect.TypeOf(v).Elem()
+}
+
+// checkKnownFields walks node alongside the Go type it will be decoded into and
+// reports every mapping key that has no matching yaml-tagged struct field
+func checkKnownFields(node *yaml.Node, path string, t reflect.Type, verr *ValidationError) {
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	if node.Kind == yaml.AliasNode {
+		node = node.Alias
+	}
+
+	switch t.Kind() {
+	case reflect.Struct:
+		if node.Kind != yaml.MappingNode {
+			return
+		}
+		fields := yamlFields(t)
+		for i := 0; i+1 < len(node.Content); i += 2 {
+			key, value := node.Content[i], node.Content[i+1]
+			fieldPath := joinPath(path, key.Value)
+			ft, ok := fields[key.Value]
+			if !ok {
+				verr.add(key, fieldPath, "unknown field")
+				continue
+			}
+			checkKnownFields(value, fieldPath, ft, verr)
+		}
+	case reflect.Map:
+		if node.Kind != yaml.MappingNode {
+			return
+		}
+		for i := 0; i+1 < len(node.Content); i += 2 {
+			checkKnownFields(node.Content[i+1], joinPath(path, node.Content[i].Value), t.Elem(), verr)
+		}
+	case reflect.Slice, reflect.Array:
+		if node.Kind != yaml.SequenceNode {
+			return
+		}
+		for i, item := range node.Content {
+			checkKnownFields(item, fmt.Sprintf("%s[%d]", path, i), t.Elem(), verr)
+		}
+	}
+}
+
+// yamlFields maps yaml key names to field types for a struct type
+func yamlFields(t reflect.Type) map[string]reflect.Type {
+	fields := make(map[string]reflect.Type, t.NumField())
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		if f.PkgPath != "" {
+			continue
+		}
+		tag := strings.Split(f.Tag.Get("yaml"), ",")
+		name := tag[0]
+		if name == "-" {
+			continue
+		}
+		if name == "" {
+			name = strings.ToLower(f.Name)
+		}
+		fields[name] = f.Type
+	}
+	return fields
+}
+
+func joinPath(path, key string) string {
+	if path == "" {
+		return key
+	}
+	return path + "." + key
+}
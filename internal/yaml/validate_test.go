@@ -0,0 +1,238 @@
+package yaml
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// validMetadata is a metadata section with every required field set
+const validMetadata = `metadata:
+  name: "VPC test"
+  type: "network"
+  priority: "high"
+  severity: "critical"
+  expected_result: "works"
+`
+
+// parseString parses src as a test case file
+func parseString(t *testing.T, src string) (*TestCase, error) {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "case.yaml")
+	if err := os.WriteFile(path, []byte(src), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	return ParseTestCase(path)
+}
+
+// fieldErrors returns the field errors of a *ValidationError
+func fieldErrors(t *testing.T, err error) []FieldError {
+	t.Helper()
+	var verr *ValidationError
+	if !errors.As(err, &verr) {
+		t.Fatalf("error = %v, want a *ValidationError", err)
+	}
+	return verr.Errors
+}
+
+func TestParseTestCaseValid(t *testing.T) {
+	tc, err := parseString(t, validMetadata+`
+terraform:
+  tfvars:
+    any_name: {nested: [1, 2]}
+  tfvars_format: json
+  timeouts:
+    apply: 10m
+test_functions:
+  - validate_cidr_ranges
+  - name: test_subnet_connectivity
+    params: {min_subnets: 2}
+    retries: 2
+    retry_interval: 5s
+assertions:
+  - outputs.vpc_id
+  - name: two subnets
+    expr: outputs.public_subnet_ids | length == 2
+execution:
+  concurrency: 2
+  fail_fast: false
+`)
+	if err != nil {
+		t.Fatalf("ParseTestCase: %v", err)
+	}
+	if tc.Terraform.TfvarsFormat != "json" || tc.Terraform.Timeouts.Apply.Minutes() != 10 {
+		t.Errorf("terraform = %+v", tc.Terraform)
+	}
+	if len(tc.TestFunctions) != 2 || tc.TestFunctions[1].Retries != 2 || tc.TestFunctions[1].Params["min_subnets"] != 2 {
+		t.Errorf("test_functions = %+v", tc.TestFunctions)
+	}
+	if len(tc.Assertions) != 2 || tc.Assertions[1].String() != "two subnets" {
+		t.Errorf("assertions = %+v", tc.Assertions)
+	}
+	if tc.Execution.FailFast == nil || *tc.Execution.FailFast {
+		t.Errorf("execution.fail_fast = %v, want false", tc.Execution.FailFast)
+	}
+	if !strings.HasSuffix(tc.Source, "case.yaml") {
+		t.Errorf("Source = %q", tc.Source)
+	}
+}
+
+func TestParseTestCaseErrors(t *testing.T) {
+	for _, tc := range []struct {
+		name string
+		src  string
+		// want is in file order, the order errors are reported in
+		want []FieldError
+	}{
+		{
+			name: "unknown top-level field",
+			src:  validMetadata + "test_function:\n  - a\n",
+			want: []FieldError{
+				{Path: "test_functions", Line: 1, Column: 1, Msg: "at least one test function or assertion is required"},
+				{Path: "test_function", Line: 7, Column: 1, Msg: "unknown field"},
+			},
+		},
+		{
+			name: "unknown nested fields",
+			src: validMetadata + `  owner: ops
+terraform:
+  tfvar: {}
+  timeouts:
+    teardown: 1m
+test_functions:
+  - name: a
+    param: {}
+execution:
+  parallel: 2
+`,
+			want: []FieldError{
+				{Path: "metadata.owner", Line: 7, Column: 3, Msg: "unknown field"},
+				{Path: "terraform.tfvar", Line: 9, Column: 3, Msg: "unknown field"},
+				{Path: "terraform.timeouts.teardown", Line: 11, Column: 5, Msg: "unknown field"},
+				{Path: "test_functions[0].param", Line: 14, Column: 5, Msg: "unknown field"},
+				{Path: "execution.parallel", Line: 16, Column: 3, Msg: "unknown field"},
+			},
+		},
+		{
+			name: "unknown assertion field",
+			src:  validMetadata + "assertions:\n  - name: x\n    expression: outputs.a\n",
+			want: []FieldError{
+				{Path: "assertions[0]", Line: 8, Column: 5, Msg: "assertion expression is empty"},
+				{Path: "assertions[0].expression", Line: 9, Column: 5, Msg: "unknown field"},
+			},
+		},
+		{
+			name: "enums",
+			src: `metadata:
+  name: x
+  type: network
+  priority: urgent
+  severity: Critical
+  expected_result: works
+terraform:
+  tfvars_format: toml
+test_functions: [a]
+`,
+			want: []FieldError{
+				{Path: "metadata.priority", Line: 4, Column: 13, Msg: `invalid value "urgent", must be one of: low, medium, high, critical`},
+				{Path: "metadata.severity", Line: 5, Column: 13, Msg: `invalid value "Critical", must be one of: trivial, minor, major, critical, blocker`},
+				{Path: "terraform.tfvars_format", Line: 8, Column: 18, Msg: `invalid value "toml", must be one of: hcl, json`},
+			},
+		},
+		{
+			name: "negative values",
+			src: validMetadata + `terraform:
+  timeouts:
+    apply: -1m
+test_functions:
+  - name: a
+    timeout: -5s
+    retries: -1
+    retry_interval: -1s
+execution:
+  concurrency: -2
+  test_timeout: -1m
+`,
+			want: []FieldError{
+				{Path: "terraform.timeouts.apply", Line: 9, Column: 12, Msg: "timeout must not be negative"},
+				{Path: "test_functions[0].timeout", Line: 12, Column: 14, Msg: "timeout must not be negative"},
+				{Path: "test_functions[0].retries", Line: 13, Column: 14, Msg: "retries must not be negative"},
+				{Path: "test_functions[0].retry_interval", Line: 14, Column: 21, Msg: "retry_interval must not be negative"},
+				{Path: "execution.concurrency", Line: 16, Column: 16, Msg: "concurrency must not be negative"},
+				{Path: "execution.test_timeout", Line: 17, Column: 17, Msg: "timeout must not be negative"},
+			},
+		},
+		{
+			name: "missing and empty required fields",
+			src: `metadata:
+  name: "  "
+  priority: low
+  severity: minor
+  expected_result: ""
+test_functions: [""]
+`,
+			want: []FieldError{
+				{Path: "metadata.type", Line: 1, Column: 1, Msg: "required field is missing"},
+				{Path: "metadata.name", Line: 2, Column: 9, Msg: "required field is empty"},
+				{Path: "metadata.expected_result", Line: 5, Column: 20, Msg: "required field is empty"},
+				{Path: "test_functions[0]", Line: 6, Column: 18, Msg: "test function name is empty"},
+			},
+		},
+		{
+			name: "missing metadata",
+			src:  "test_functions: [a]\n",
+			want: []FieldError{
+				{Path: "metadata", Line: 1, Column: 1, Msg: "required section is missing"},
+			},
+		},
+		{
+			name: "wrong types",
+			src:  validMetadata + "test_functions:\n  - name: a\n    retries: many\nexecution:\n  fail_fast: sometimes\n",
+			want: []FieldError{
+				{Line: 9, Msg: "cannot unmarshal !!str `many` into int"},
+				{Line: 11, Msg: "cannot unmarshal !!str `sometimes` into bool"},
+			},
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			_, err := parseString(t, tc.src)
+			got := fieldErrors(t, err)
+			if len(got) != len(tc.want) {
+				t.Fatalf("got %d errors, want %d:\n%v", len(got), len(tc.want), err)
+			}
+			for i, want := range tc.want {
+				if got[i] != want {
+					t.Errorf("error %d = %+v, want %+v", i, got[i], want)
+				}
+			}
+		})
+	}
+}
+
+func TestValidationErrorMessage(t *testing.T) {
+	_, err := parseString(t, validMetadata+"test_functions: [a]\nextra: 1\n")
+	file := err.(*ValidationError).File
+	want := file + ": 1 validation error(s)\n  " + file + ":8:1: extra: unknown field"
+	if err.Error() != want {
+		t.Errorf("Error() = %q, want %q", err.Error(), want)
+	}
+
+	var fe FieldError
+	if !errors.As(err, &fe) || fe.Path != "extra" {
+		t.Errorf("errors.As(FieldError) = %+v", fe)
+	}
+}
+
+func TestParseTestCaseUnreadable(t *testing.T) {
+	if _, err := ParseTestCase(filepath.Join(t.TempDir(), "missing.yaml")); !errors.Is(err, os.ErrNotExist) {
+		t.Errorf("missing file: error = %v", err)
+	}
+	if _, err := parseString(t, ""); err == nil || !strings.Contains(err.Error(), "empty test case file") {
+		t.Errorf("empty file: error = %v", err)
+	}
+	if _, err := parseString(t, "metadata: [\n"); err == nil {
+		t.Error("invalid YAML parsed")
+	}
+}
@@ -0,0 +1,36 @@
+#!/usr/bin/env python3
+"""Example external test function.
+
+Reads the test input as JSON from stdin (the same JSON is in the file named by
+$QA_TEST_INPUT) and prints a JSON result on stdout. Diagnostics go to stderr,
+which qa-test-app keeps in the result details.
+"""
+import json
+import sys
+
+
+def main():
+    data = json.load(sys.stdin)
+    outputs = data.get("outputs") or {}
+    tfvars = data.get("tfvars") or {}
+
+    violations = []
+    vpc_id = outputs.get("vpc_id", "")
+    if not str(vpc_id).startswith("vpc-"):
+        violations.append("vpc_id %r does not look like a VPC ID" % vpc_id)
+
+    expected = tfvars.get("vpc_cidr")
+    actual = outputs.get("vpc_cidr_block")
+    if expected and actual != expected:
+        violations.append("vpc_cidr_block is %r, tfvars asked for %r" % (actual, expected))
+
+    print("checked outputs of %s" % (data.get("workspace") or "no workspace"), file=sys.stderr)
+    json.dump({
+        "success": not violations,
+        "message": "; ".join(violations) or "VPC outputs match the tfvars",
+        "details": {"vpc_id": vpc_id, "vpc_cidr_block": actual},
+    }, sys.stdout)
+
+
+if __name__ == "__main__":
+    main()